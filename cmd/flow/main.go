@@ -13,6 +13,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"encoding/pem"
+	"filippo.io/age"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -25,6 +26,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/eks"
 	"github.com/aws/aws-sdk-go/service/kafka"
 	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/sns"
@@ -32,20 +34,27 @@ import (
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/sts"
 	flowbase64 "github.com/flow-lab/flow/internal/base64"
+	flowcloudwatch "github.com/flow-lab/flow/internal/cloudwatch"
 	"github.com/flow-lab/flow/internal/creds"
+	flowcrypto "github.com/flow-lab/flow/internal/crypto"
 	flowdynamo "github.com/flow-lab/flow/internal/dynamodb"
+	flowenv "github.com/flow-lab/flow/internal/env"
 	flowkafka "github.com/flow-lab/flow/internal/kafka"
 	"github.com/flow-lab/flow/internal/logs"
 	"github.com/flow-lab/flow/internal/msk"
 	flowpubsub "github.com/flow-lab/flow/internal/pubsub"
 	"github.com/flow-lab/flow/internal/reader"
+	flowsecretsmanager "github.com/flow-lab/flow/internal/secretsmanager"
 	"github.com/flow-lab/flow/internal/session"
+	flowsns "github.com/flow-lab/flow/internal/sns"
 	flowsqs "github.com/flow-lab/flow/internal/sqs"
+	flowssm "github.com/flow-lab/flow/internal/ssm"
 	flowsts "github.com/flow-lab/flow/internal/sts"
 	"github.com/google/go-github/v32/github"
 	"github.com/pkg/errors"
 	vegeta "github.com/tsenart/vegeta/lib"
 	"golang.org/x/oauth2"
+	"golang.org/x/term"
 	"io"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -61,10 +70,14 @@ import (
 	"os/user"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sigs.k8s.io/aws-iam-authenticator/pkg/token"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"text/tabwriter"
 	"text/template"
 	"time"
 
@@ -77,29 +90,6 @@ var (
 	date    = "unknown"
 )
 
-type RSAValue struct {
-	PrivateKey string `json:"private_key"`
-	PublicKey  string `json:"public_key"`
-}
-
-type Secret struct {
-	Id       string
-	Name     string
-	Type     string
-	Value    string
-	RSAValue RSAValue `json:"rsaValue,omitempty"`
-}
-
-type SecretOutput struct {
-	Entry *secretsmanager.SecretListEntry
-	Value *secretsmanager.GetSecretValueOutput
-}
-
-type Parameter struct {
-	ParameterMetadata *ssm.ParameterMetadata
-	ParameterValue    *ssm.Parameter
-}
-
 func main() {
 	app := cli.NewApp()
 	app.Name = "flow"
@@ -1277,32 +1267,40 @@ func main() {
 							return client.Delete(context.Background(), queueName, receiptHandles)
 						},
 					},
-				},
-			}
-		}(),
-		func() *cli.Command {
-			return &cli.Command{
-				Name:  "sns",
-				Usage: "AWS SNS",
-				Subcommands: []*cli.Command{
 					{
-						Name:  "publish",
-						Usage: "publish many messages",
+						Name:  "delete-matching",
+						Usage: "deletes only messages matching the filter, the rest become visible again",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:  "topic-name",
-								Value: "",
+								Name:     "queue-name",
+								Required: true,
 							},
 							&cli.StringFlag{
-								Name: "message",
+								Name:  "body-regex",
+								Usage: "regular expression matched against message body",
 							},
-							&cli.StringFlag{
-								Name:  "times",
-								Value: "1",
+							&cli.StringSliceFlag{
+								Name:  "attribute",
+								Usage: "name=value matched against message and system attributes, e.g. eventType=STATUS_UPDATED",
 							},
-							&cli.StringFlag{
-								Name:  "delay",
-								Value: "0",
+							&cli.IntFlag{
+								Name:  "max-messages",
+								Value: 100,
+								Usage: "max number of messages to inspect",
+							},
+							&cli.Int64Flag{
+								Name:  "visibility-timeout",
+								Value: 60,
+								Usage: "visibility timeout in seconds for inspected messages, must cover the whole run",
+							},
+							&cli.Int64Flag{
+								Name:  "wait-time",
+								Value: 2,
+								Usage: "receive wait time in seconds",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "only count matching messages",
 							},
 							&cli.StringFlag{
 								Name:  "profile",
@@ -1311,172 +1309,138 @@ func main() {
 						},
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
-							topicName := c.String("topic-name")
-							message := c.String("message")
-							times, err := strconv.Atoi(c.String("times"))
-							if err != nil {
-								return err
+							queueName := c.String("queue-name")
+
+							filter := flowsqs.MessageFilter{}
+							if bodyRegex := c.String("body-regex"); bodyRegex != "" {
+								re, err := regexp.Compile(bodyRegex)
+								if err != nil {
+									return errors.Wrap(err, "body-regex")
+								}
+								filter.Body = re
 							}
-							delay, err := strconv.ParseInt(c.String("delay"), 10, 0)
-							if err != nil {
-								return err
+							for _, attr := range c.StringSlice("attribute") {
+								kv := strings.SplitN(attr, "=", 2)
+								if len(kv) != 2 {
+									return fmt.Errorf("attribute %s is not in name=value format", attr)
+								}
+								if filter.Attributes == nil {
+									filter.Attributes = map[string]string{}
+								}
+								filter.Attributes[kv[0]] = kv[1]
+							}
+							if filter.Body == nil && filter.Attributes == nil && !c.Bool("dry-run") {
+								return fmt.Errorf("body-regex or attribute is required, use sqs purge to delete all messages")
 							}
-							sess := session.NewSessionWithSharedProfile(profile)
-
-							sqsc := sns.New(sess)
 
-							var wg sync.WaitGroup
-							listTopicsParams := sns.ListTopicsInput{}
-							out, err := sqsc.ListTopics(&listTopicsParams)
+							sess := session.NewSessionWithSharedProfile(profile)
+							client, err := flowsqs.NewSQSClient(sqs.New(sess))
 							if err != nil {
 								return err
 							}
-							for _, topic := range out.Topics {
-								if strings.Contains(*topic.TopicArn, topicName) {
-									for i := 0; i < times; i++ {
-										wg.Add(1)
-										go func(topicArn string) {
-											defer wg.Done()
-											params := sns.PublishInput{
-												Message:  &message,
-												TopicArn: &topicArn,
-											}
-											fmt.Println(params)
-											_, err := sqsc.Publish(&params)
-
-											if err != nil {
-												fmt.Println("Unable to send to sns", err.Error())
-											}
-
-											fmt.Print(".")
 
-											time.Sleep(time.Duration(delay) * time.Millisecond)
-										}(*topic.TopicArn)
-									}
-								}
+							result, err := client.DeleteMatching(c.Context, queueName, filter, flowsqs.DeleteMatchingOptions{
+								MaxMessages:       c.Int("max-messages"),
+								VisibilityTimeout: c.Int64("visibility-timeout"),
+								WaitTimeSeconds:   c.Int64("wait-time"),
+								DryRun:            c.Bool("dry-run"),
+							})
+							if result != nil {
+								fmt.Printf("received: %d, matched: %d, deleted: %d, released: %d\n", result.Received, result.Matched, result.Deleted, result.Released)
 							}
 
-							wg.Wait()
-							return nil
+							return err
 						},
 					},
-				},
-			}
-		}(),
-		func() *cli.Command {
-			return &cli.Command{
-				Name:  "cloudwatch",
-				Usage: "AWS CloudWatch",
-				Subcommands: []*cli.Command{
 					{
-						Name:  "delete-alarm",
-						Usage: "deletes cloudwatch alarm(s)",
-						Flags: []cli.Flag{
-							&cli.StringSliceFlag{
-								Name: "name",
+						Name:  "create",
+						Usage: "create standard or fifo queue with optional dead-letter queue",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:     "queue-name",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "fifo",
+								Usage: "create fifo queue, queue-name must end with .fifo",
 							},
 							&cli.StringFlag{
 								Name:  "profile",
 								Value: "",
 							},
-						},
+						}, queueConfigFlags()...),
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
-							var alarmNames []*string
-							for _, elem := range c.StringSlice("name") {
-								alarmNames = append(alarmNames, &elem)
+							queueName := c.String("queue-name")
+							cfg, err := queueConfig(c)
+							if err != nil {
+								return err
 							}
-							sess := session.NewSessionWithSharedProfile(profile)
+							cfg.FIFO = c.Bool("fifo")
 
-							cwlc := cloudwatch.New(sess)
-
-							params := cloudwatch.DeleteAlarmsInput{
-								AlarmNames: alarmNames,
+							sess := session.NewSessionWithSharedProfile(profile)
+							client, err := flowsqs.NewSQSClient(sqs.New(sess))
+							if err != nil {
+								return err
 							}
-							_, err := cwlc.DeleteAlarms(&params)
+
+							qUrl, err := client.Create(c.Context, queueName, cfg)
 							if err != nil {
 								return err
 							}
-							fmt.Printf("ok")
+							fmt.Printf("created %s\n", qUrl)
 
 							return nil
 						},
 					},
-				},
-			}
-		}(),
-		func() *cli.Command {
-			const logGroupDefault = "aws-waf-logs-"
-			return &cli.Command{
-				Name:  "cloudwatchlogs",
-				Usage: "AWS CloudWatch Logs",
-				Subcommands: []*cli.Command{
 					{
-						Name:  "retention",
-						Usage: "set log group retention in days",
-						Flags: []cli.Flag{
+						Name:  "set-attributes",
+						Usage: "update attributes, dead-letter queue and tags of existing queue",
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
-								Name:  "log-group-name",
-								Value: "",
-							},
-							&cli.StringFlag{
-								Name:  "days",
-								Usage: "retention in days",
-								Value: "",
+								Name:     "queue-name",
+								Required: true,
 							},
 							&cli.StringFlag{
 								Name:  "profile",
 								Value: "",
 							},
-						},
+						}, queueConfigFlags()...),
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
-							logGroupName := c.String("log-group-name")
-							days := c.String("days")
-							if days == "" {
-								return fmt.Errorf("days is required")
+							queueName := c.String("queue-name")
+							cfg, err := queueConfig(c)
+							if err != nil {
+								return err
 							}
+							cfg.FIFO = strings.HasSuffix(queueName, ".fifo")
 
-							retention, err := strconv.ParseInt(days, 10, 0)
+							sess := session.NewSessionWithSharedProfile(profile)
+							client, err := flowsqs.NewSQSClient(sqs.New(sess))
 							if err != nil {
 								return err
 							}
-							if logGroupName == "" {
-								return fmt.Errorf("log-group-name is required")
+
+							if err := client.Update(c.Context, queueName, cfg); err != nil {
+								return err
 							}
+							fmt.Printf("updated %s\n", queueName)
 
-							sess := session.NewSessionWithSharedProfile(profile)
-							cwlc := cloudwatchlogs.New(sess)
-							return logs.SetRetention(logGroupName, retention, cwlc)
+							return nil
 						},
 					},
 					{
-						Name:  "write-to-file",
-						Usage: "writes events to file in json format",
+						Name:  "delete",
+						Usage: "delete queue",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:  "log-group-name",
-								Value: "",
-							},
-							&cli.StringFlag{
-								Name:  "file-name",
-								Usage: "output file name",
-								Value: "output",
-							},
-							&cli.StringFlag{
-								Name:  "filter-pattern",
-								Usage: "the filter pattern to use. If not provided, all the events are matched",
-								Value: "",
-							},
-							&cli.StringFlag{
-								Name:  "region",
-								Usage: "AWS region",
-								Value: "eu-north-1",
+								Name:     "queue-name",
+								Required: true,
 							},
-							&cli.Int64Flag{
-								Name:  "start-time",
-								Usage: "start time in unix format",
-								Value: 0,
+							&cli.BoolFlag{
+								Name:    "yes",
+								Usage:   "do not ask for confirmation",
+								Aliases: []string{"y"},
 							},
 							&cli.StringFlag{
 								Name:  "profile",
@@ -1485,53 +1449,59 @@ func main() {
 						},
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
-							logGroupName := c.String("log-group-name")
-							fileName := c.String("file-name")
-							filterPattern := c.String("filter-pattern")
-							startTime := c.Int64("start-time")
-
-							sess := session.NewSessionWithSharedProfile(profile)
-							reg := c.String("region")
-							sess.Config.Region = aws.String(reg)
-
-							cwlc := cloudwatchlogs.New(sess)
+							queueName := c.String("queue-name")
 
-							params := cloudwatchlogs.FilterLogEventsInput{
-								LogGroupName:  &logGroupName,
-								FilterPattern: &filterPattern,
-								StartTime:     &startTime,
+							if !c.Bool("yes") && !confirm(fmt.Sprintf("delete queue %s?", queueName)) {
+								fmt.Println("aborted")
+								return nil
 							}
 
-							pageNum := 0
-							var logEvents []*cloudwatchlogs.FilteredLogEvent
-							err := cwlc.FilterLogEventsPages(&params, func(page *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
-								pageNum++
-								for _, event := range page.Events {
-									logEvents = append(logEvents, event)
-								}
-								return lastPage == false
-							})
-
-							b, _ := json.Marshal(logEvents)
-							err = os.WriteFile(fileName, b, 0644)
+							sess := session.NewSessionWithSharedProfile(profile)
+							client, err := flowsqs.NewSQSClient(sqs.New(sess))
 							if err != nil {
 								return err
 							}
 
+							if err := client.Remove(c.Context, queueName); err != nil {
+								return err
+							}
+							fmt.Printf("deleted %s\n", queueName)
+
 							return nil
 						},
 					},
 					{
-						Name:  "delete-subscription-filter",
-						Usage: "delete subscription for log group",
+						Name:  "watch",
+						Usage: "live table of queue depth, exits with code 2 when a threshold is crossed",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:  "log-group-name",
-								Value: "",
+								Name:     "prefix",
+								Usage:    "queue name prefix",
+								Required: true,
 							},
-							&cli.StringFlag{
-								Name:  "filter-name",
-								Value: "",
+							&cli.DurationFlag{
+								Name:  "interval",
+								Value: 5 * time.Second,
+							},
+							&cli.IntFlag{
+								Name:  "count",
+								Usage: "number of refreshes, 0 runs until interrupted",
+							},
+							&cli.Int64Flag{
+								Name:  "max-visible",
+								Usage: "threshold for visible messages",
+							},
+							&cli.Int64Flag{
+								Name:  "max-in-flight",
+								Usage: "threshold for in flight messages",
+							},
+							&cli.DurationFlag{
+								Name:  "max-age",
+								Usage: "threshold for age of oldest message, e.g. 5m",
+							},
+							&cli.BoolFlag{
+								Name:  "no-clear",
+								Usage: "do not clear the terminal between refreshes",
 							},
 							&cli.StringFlag{
 								Name:  "profile",
@@ -1540,119 +1510,257 @@ func main() {
 						},
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
-							logGroupName := c.String("log-group-name")
-							if logGroupName == "" {
-								return fmt.Errorf("log-group-name is required")
-							}
-							filterName := c.String("filter-name")
-							if filterName == "" {
-								return fmt.Errorf("filter-name is required")
+							prefix := c.String("prefix")
+							thresholds := flowsqs.Thresholds{
+								MaxVisible:          c.Int64("max-visible"),
+								MaxInFlight:         c.Int64("max-in-flight"),
+								MaxOldestMessageAge: c.Duration("max-age").Seconds(),
 							}
 
 							sess := session.NewSessionWithSharedProfile(profile)
-							cwlc := cloudwatchlogs.New(sess)
-
-							params := cloudwatchlogs.DeleteSubscriptionFilterInput{
-								LogGroupName: &logGroupName,
-								FilterName:   &filterName,
-							}
-
-							_, err := cwlc.DeleteSubscriptionFilter(&params)
+							client, err := flowsqs.NewSQSClient(sqs.New(sess))
 							if err != nil {
-								return fmt.Errorf("DeleteSubscriptionFilter failed: %s", err)
+								return err
 							}
+							cwc := cloudwatch.New(sess)
 
-							return nil
-						},
-					},
-					{
-						Name:  "delete-all-subscription-filters",
-						Usage: "delete all subscriptions for log group",
-						Flags: []cli.Flag{
-							&cli.StringFlag{
-								Name:  "log-group-name",
-								Value: "",
-							},
-							&cli.StringFlag{
-								Name:  "profile",
-								Value: "",
-							},
-						},
-						Action: func(c *cli.Context) error {
-							logGroupName := c.String("log-group-name")
-							if logGroupName == "" {
-								return fmt.Errorf("log-group-name is required")
-							}
-							profile := c.String("profile")
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
 
-							sess := session.NewSessionWithSharedProfile(profile)
-							cwlc := cloudwatchlogs.New(sess)
+							ticker := time.NewTicker(c.Duration("interval"))
+							defer ticker.Stop()
 
-							describeSubscriptionFiltersInput := cloudwatchlogs.DescribeSubscriptionFiltersInput{
-								LogGroupName: &logGroupName,
-							}
+							prev := map[string]flowsqs.QueueStats{}
+							for i := 0; c.Int("count") == 0 || i < c.Int("count"); i++ {
+								if i > 0 {
+									select {
+									case <-ctx.Done():
+										return nil
+									case <-ticker.C:
+									}
+								}
 
-							describeSubscriptionFiltersOutput, err := cwlc.DescribeSubscriptionFilters(&describeSubscriptionFiltersInput)
-							if err != nil {
-								return fmt.Errorf("DescribeSubscriptionFilters failed: %s", err)
-							}
+								stats, err := client.Stats(ctx, prefix)
+								if err != nil {
+									return err
+								}
+								if err := flowsqs.SetOldestMessageAge(ctx, cwc, stats); err != nil {
+									return err
+								}
 
-							for _, sf := range describeSubscriptionFiltersOutput.SubscriptionFilters {
-								params := cloudwatchlogs.DeleteSubscriptionFilterInput{
-									LogGroupName: &logGroupName,
-									FilterName:   sf.FilterName,
+								if !c.Bool("no-clear") {
+									fmt.Print("\033[H\033[2J")
+								}
+								fmt.Printf("%s queues: %s*\n\n", time.Now().Format(time.RFC3339), prefix)
+								if err := flowsqs.WriteStatsTable(os.Stdout, prev, stats); err != nil {
+									return err
 								}
 
-								_, err := cwlc.DeleteSubscriptionFilter(&params)
-								if err != nil {
-									return fmt.Errorf("DeleteSubscriptionFilter failed: %s", err)
+								var exceeded []string
+								for _, s := range stats {
+									exceeded = append(exceeded, thresholds.Exceeded(s)...)
+									prev[s.Name] = s
+								}
+								if len(exceeded) > 0 {
+									return cli.Exit(fmt.Sprintf("threshold exceeded:\n%s", strings.Join(exceeded, "\n")), 2)
 								}
 							}
 
 							return nil
 						},
 					},
+				},
+			}
+		}(),
+		func() *cli.Command {
+			return &cli.Command{
+				Name:  "sns",
+				Usage: "AWS SNS",
+				Subcommands: []*cli.Command{
 					{
-						Name:  "describe",
-						Usage: "describe log groups",
+						Name:  "publish",
+						Usage: "publish message(s) to topic",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:  "log-group-name-prefix",
-								Value: "",
+								Name:     "topic-name",
+								Usage:    "topic name or arn",
+								Required: true,
 							},
 							&cli.StringFlag{
-								Name:  "profile",
-								Value: "",
+								Name: "message",
 							},
-						},
-						Action: func(c *cli.Context) error {
-							profile := c.String("profile")
-							logGroupNamePrefix := c.String("log-group-name-prefix")
-							sess := session.NewSessionWithSharedProfile(profile)
-							cwlc := cloudwatchlogs.New(sess)
-
-							logGroups, err := logs.Describe(c.Context, &logGroupNamePrefix, cwlc)
+							&cli.StringFlag{
+								Name:  "input-file-name",
+								Usage: "jsonl file, one message per line",
+							},
+							&cli.StringFlag{
+								Name:  "subject",
+								Usage: "with input-file-name the default for lines without one",
+							},
+							&cli.StringFlag{
+								Name:  "message-attributes",
+								Usage: `e.g. '{"eventType":{"DataType":"String","StringValue":"STATUS_UPDATED"}}', with input-file-name added to the attributes of each line`,
+							},
+							&cli.StringFlag{
+								Name:  "message-group-id",
+								Usage: "fifo topics only, with input-file-name the default for lines without one",
+							},
+							&cli.StringFlag{
+								Name:  "deduplication-id",
+								Usage: "fifo topics only, suffixed with message number when times > 1",
+							},
+							&cli.IntFlag{
+								Name:  "times",
+								Value: 1,
+								Usage: "number of times message is published",
+							},
+							&cli.Float64Flag{
+								Name:  "rate",
+								Usage: "max messages per second, 0 means no limit",
+							},
+							&cli.IntFlag{
+								Name:  "concurrency",
+								Value: 10,
+							},
+							&cli.IntFlag{
+								Name:  "batch-size",
+								Value: 10,
+								Usage: "messages per PublishBatch request, 1 uses Publish",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							message := c.String("message")
+							inFileName := c.String("input-file-name")
+							if (message == "") == (inFileName == "") {
+								return fmt.Errorf("message or input-file-name is required")
+							}
+
+							var messageAttributes map[string]*sns.MessageAttributeValue
+							if msgAttributes := c.String("message-attributes"); msgAttributes != "" {
+								if err := json.Unmarshal([]byte(msgAttributes), &messageAttributes); err != nil {
+									return errors.Wrap(err, "message-attributes")
+								}
+							}
+
+							var entries []flowsns.PublishEntry
+							if inFileName != "" {
+								if c.IsSet("times") || c.IsSet("deduplication-id") {
+									return fmt.Errorf("times and deduplication-id can not be used with input-file-name")
+								}
+								f, err := os.Open(inFileName)
+								if err != nil {
+									return fmt.Errorf("error when opening %s", inFileName)
+								}
+								defer f.Close()
+								entries, err = flowsns.ReadEntries(f)
+								if err != nil {
+									return errors.Wrapf(err, "read %s", inFileName)
+								}
+								entries = flowsns.WithDefaults(entries, flowsns.PublishEntry{
+									Subject:           c.String("subject"),
+									MessageAttributes: messageAttributes,
+									MessageGroupId:    c.String("message-group-id"),
+								})
+							} else {
+								times := c.Int("times")
+								for i := 0; i < times; i++ {
+									e := flowsns.PublishEntry{
+										Message:           message,
+										Subject:           c.String("subject"),
+										MessageAttributes: messageAttributes,
+										MessageGroupId:    c.String("message-group-id"),
+									}
+									if dedupId := c.String("deduplication-id"); dedupId != "" {
+										e.MessageDeduplicationId = dedupId
+										if times > 1 {
+											e.MessageDeduplicationId = fmt.Sprintf("%s-%d", dedupId, i)
+										}
+									}
+									entries = append(entries, e)
+								}
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							client, err := flowsns.NewSNSClient(sns.New(sess))
 							if err != nil {
 								return err
 							}
 
-							jsonBytes, err := json.Marshal(logGroups)
+							topicArn, err := client.TopicArn(c.Context, c.String("topic-name"))
 							if err != nil {
 								return err
 							}
 
-							fmt.Print(string(jsonBytes))
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							result := client.Publish(ctx, topicArn, entries, flowsns.PublishOptions{
+								Rate:        c.Float64("rate"),
+								Concurrency: c.Int("concurrency"),
+								BatchSize:   c.Int("batch-size"),
+							})
+							for _, e := range result.Errors {
+								fmt.Fprintln(os.Stderr, e)
+							}
+							fmt.Printf("published: %d, failed: %d to %s\n", result.Published, result.Failed, topicArn)
+							if result.Failed > 0 {
+								return fmt.Errorf("failed to publish %d message(s)", result.Failed)
+							}
 
 							return nil
 						},
 					},
 					{
-						Name:  "summary",
-						Usage: "summary log groups",
+						Name:  "capture",
+						Usage: "subscribes to topic and prints or saves notifications as jsonl, unsubscribes on exit",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:  "log-group-name-prefix",
-								Value: "",
+								Name:     "topic-name",
+								Usage:    "topic name or arn",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "mode",
+								Value: "http",
+								Usage: "http subscribes local listener, sqs subscribes temporary queue",
+							},
+							&cli.StringFlag{
+								Name:  "listen",
+								Value: ":8080",
+								Usage: "http mode, address of local listener",
+							},
+							&cli.StringFlag{
+								Name:  "endpoint",
+								Usage: "http mode, public url reaching the listener, e.g. tunnel url",
+							},
+							&cli.StringFlag{
+								Name:  "cert-file",
+								Usage: "http mode, serve https with certificate",
+							},
+							&cli.StringFlag{
+								Name:  "key-file",
+								Usage: "http mode, serve https with private key",
+							},
+							&cli.BoolFlag{
+								Name:  "no-verify",
+								Usage: "http mode, do not verify message signatures",
+							},
+							&cli.StringFlag{
+								Name:  "queue-name",
+								Usage: "sqs mode, name of temporary queue, it must not exist",
+							},
+							&cli.StringFlag{
+								Name:  "output-file-name",
+								Usage: "append notifications to file instead of stdout",
+							},
+							&cli.DurationFlag{
+								Name:  "duration",
+								Usage: "stop capturing after duration, by default runs until interrupted",
 							},
 							&cli.StringFlag{
 								Name:  "profile",
@@ -1661,43 +1769,124 @@ func main() {
 						},
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
+							mode := c.String("mode")
+							if mode != "http" && mode != "sqs" {
+								return fmt.Errorf("mode must be http or sqs")
+							}
+							if mode == "http" && c.String("endpoint") == "" {
+								return fmt.Errorf("endpoint is required in http mode")
+							}
+
+							var w io.Writer = os.Stdout
+							if outFileName := c.String("output-file-name"); outFileName != "" {
+								f, err := os.OpenFile(outFileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+								if err != nil {
+									return errors.Wrap(err, "open output file")
+								}
+								defer f.Close()
+								w = f
+							}
+							var mu sync.Mutex
+							out := func(m flowsns.Message) error {
+								b, err := json.Marshal(m)
+								if err != nil {
+									return err
+								}
+								mu.Lock()
+								defer mu.Unlock()
+								_, err = fmt.Fprintln(w, string(b))
+								return err
+							}
+
 							sess := session.NewSessionWithSharedProfile(profile)
-							cwlc := cloudwatchlogs.New(sess)
-							logGroupNamePrefix := c.String("log-group-name-prefix")
-							logGroups, err := logs.Describe(c.Context, &logGroupNamePrefix, cwlc)
+							snsc := sns.New(sess)
+							client, err := flowsns.NewSNSClient(snsc)
 							if err != nil {
 								return err
 							}
-
-							summary := logs.Summary(logGroups)
-							jsonBytes, err := json.Marshal(summary)
+							topicArn, err := client.TopicArn(c.Context, c.String("topic-name"))
 							if err != nil {
 								return err
 							}
-							fmt.Print(string(jsonBytes))
 
-							return nil
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+							if d := c.Duration("duration"); d > 0 {
+								var cancel context.CancelFunc
+								ctx, cancel = context.WithTimeout(ctx, d)
+								defer cancel()
+							}
+
+							if mode == "sqs" {
+								queueName := c.String("queue-name")
+								if queueName == "" {
+									queueName = fmt.Sprintf("flow-capture-%d", time.Now().Unix())
+								}
+								log.Printf("capturing %s with queue %s\n", topicArn, queueName)
+								return flowsns.CaptureSQS(ctx, snsc, sqs.New(sess), topicArn, queueName, out)
+							}
+
+							var fetch flowsns.CertFetcher
+							if !c.Bool("no-verify") {
+								fetch = flowsns.NewCertFetcher(&http.Client{Timeout: 10 * time.Second})
+							}
+							capture := flowsns.NewCapture(snsc, topicArn, fetch, out)
+							server := &http.Server{Addr: c.String("listen"), Handler: capture}
+							serverErr := make(chan error, 1)
+							go func() {
+								if c.String("cert-file") != "" {
+									serverErr <- server.ListenAndServeTLS(c.String("cert-file"), c.String("key-file"))
+								} else {
+									serverErr <- server.ListenAndServe()
+								}
+							}()
+							defer server.Close()
+
+							endpoint := c.String("endpoint")
+							protocol := "http"
+							if strings.HasPrefix(endpoint, "https://") {
+								protocol = "https"
+							}
+							if err := capture.Subscribe(ctx, protocol, endpoint); err != nil {
+								return err
+							}
+							defer func() {
+								if err := capture.Unsubscribe(context.Background()); err != nil {
+									log.Println(err)
+								}
+							}()
+							log.Printf("capturing %s at %s, waiting for subscription confirmation\n", topicArn, endpoint)
+
+							confirmed := capture.Confirmed()
+							for {
+								select {
+								case <-confirmed:
+									log.Println("subscription confirmed")
+									confirmed = nil
+								case err := <-serverErr:
+									return errors.Wrap(err, "listener")
+								case <-ctx.Done():
+									return nil
+								}
+							}
 						},
 					},
 					{
-						Name:  "get-log-events",
-						Usage: "get log events",
+						Name:  "describe",
+						Usage: "list topic subscriptions with protocol, endpoint, filter policy, raw delivery and dlq",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:     "log-group-name-prefix",
-								Required: false,
-								Value:    logGroupDefault,
-								Aliases:  []string{"l"},
+								Name:  "topic-name",
+								Usage: "topic name or arn",
 							},
 							&cli.StringFlag{
-								Name:    "file-name-prefix",
-								Value:   "logs",
-								Aliases: []string{"fp"},
+								Name:  "prefix",
+								Usage: "describe all topics with name prefix",
 							},
-							&cli.Int64Flag{
-								Name:        "hours",
-								DefaultText: "Number of hours to retrieve logs.",
-								Value:       1,
+							&cli.StringFlag{
+								Name:  "output",
+								Value: "json",
+								Usage: "json, table, dot or mermaid",
 							},
 							&cli.StringFlag{
 								Name:  "profile",
@@ -1706,72 +1895,131 @@ func main() {
 						},
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
+							topicName := c.String("topic-name")
+							prefix := c.String("prefix")
+							if (topicName == "") == (prefix == "") {
+								return fmt.Errorf("topic-name or prefix is required")
+							}
+
 							sess := session.NewSessionWithSharedProfile(profile)
-							client := cloudwatchlogs.New(sess)
-							logGroupNamePrefix := c.String("log-group-name-prefix")
-							if logGroupNamePrefix == logGroupDefault {
-								describe, err := logs.Describe(c.Context, &logGroupNamePrefix, client)
+							client, err := flowsns.NewSNSClient(sns.New(sess))
+							if err != nil {
+								return err
+							}
+
+							topics, err := client.Describe(c.Context, topicName, prefix)
+							if err != nil {
+								return err
+							}
+
+							switch c.String("output") {
+							case "json":
+								jsonBytes, err := json.Marshal(topics)
 								if err != nil {
-									return errors.Wrap(err, "Describe failed")
+									return err
 								}
+								fmt.Println(string(jsonBytes))
+								return nil
+							case "table":
+								return flowsns.WriteTopicsTable(os.Stdout, topics)
+							case "dot":
+								return flowsns.WriteTopicsDot(os.Stdout, topics)
+							case "mermaid":
+								return flowsns.WriteTopicsMermaid(os.Stdout, topics)
+							default:
+								return fmt.Errorf("unsupported output %s", c.String("output"))
+							}
+						},
+					},
+					{
+						Name:  "test-filter",
+						Usage: "evaluates message against filter policy of every subscription to show who would receive it",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "topic-name",
+								Usage:    "topic name or arn",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name: "message",
+							},
+							&cli.StringFlag{
+								Name:  "message-attributes",
+								Usage: `e.g. '{"eventType":{"DataType":"String","StringValue":"STATUS_UPDATED"}}'`,
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
 
-								for _, logGroup := range describe {
-									// find first matching the prefix
-									if strings.Contains(*logGroup.LogGroupName, logGroupNamePrefix) {
-										logGroupNamePrefix = *logGroup.LogGroupName
-									}
-									break
+							var messageAttributes map[string]*sns.MessageAttributeValue
+							if msgAttributes := c.String("message-attributes"); msgAttributes != "" {
+								if err := json.Unmarshal([]byte(msgAttributes), &messageAttributes); err != nil {
+									return errors.Wrap(err, "message-attributes")
+								}
+							}
+							attributes := map[string]flowsns.MessageAttribute{}
+							for name, attr := range messageAttributes {
+								attributes[name] = flowsns.MessageAttribute{
+									Type:  aws.StringValue(attr.DataType),
+									Value: aws.StringValue(attr.StringValue),
 								}
 							}
 
-							endTime := time.Now()
-							startTime := endTime.Add(-time.Duration(c.Int64("hours")) * time.Hour)
-
-							// create file name with start and end time in UTC
-							start := startTime.UTC().Format("2006-01-02T15:04:05")
-							start = strings.Replace(start, ":", "", -1)
-							start = strings.Replace(start, "-", "", -1)
-							end := endTime.UTC().Format("2006-01-02T15:04:05")
-							end = strings.Replace(end, ":", "", -1)
-							end = strings.Replace(end, "-", "", -1)
-							fileName := fmt.Sprintf("%s-%sZ-%sZ.csv", c.String("file-name-prefix"), start, end)
-							file, err := os.Create(fileName)
+							sess := session.NewSessionWithSharedProfile(profile)
+							client, err := flowsns.NewSNSClient(sns.New(sess))
 							if err != nil {
-								return errors.Wrap(err, "failed to create file")
+								return err
 							}
-							defer file.Close()
-
-							writer := csv.NewWriter(file)
-							defer writer.Flush()
 
-							if err := writer.Write([]string{"timestamp", "message", "logStreamName"}); err != nil {
-								return errors.Wrap(err, "failed to write csv header")
+							topics, err := client.Describe(c.Context, c.String("topic-name"), "")
+							if err != nil {
+								return err
 							}
 
-							if err := logs.WriteLogEvents(c.Context, logGroupNamePrefix, startTime, endTime, client, writer); err != nil {
-								return errors.Wrap(err, "failed to get log events")
+							tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+							fmt.Fprintln(tw, "PROTOCOL\tENDPOINT\tRECEIVES\tFILTER POLICY")
+							for _, t := range topics {
+								for _, sub := range t.Subscriptions {
+									match, err := flowsns.MatchFilterPolicy(sub.FilterPolicy, sub.FilterPolicyScope, c.String("message"), attributes)
+									receives := strconv.FormatBool(match)
+									if err != nil {
+										receives = err.Error()
+									}
+									fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", sub.Protocol, sub.Endpoint, receives, sub.FilterPolicy)
+								}
 							}
 
-							log.Printf("wrote log events to %s from %s\n", fileName, logGroupNamePrefix)
-
-							return nil
+							return tw.Flush()
 						},
 					},
-				},
-			}
-		}(),
-		func() *cli.Command {
-			return &cli.Command{
-				Name:  "ssm",
-				Usage: "AWS SSM",
-				Subcommands: []*cli.Command{
 					{
-						Name:  "export",
-						Usage: "exports all ssm parameters and their values to json",
+						Name:  "subscribe-queue",
+						Usage: "subscribes queue to topic and allows the topic to send messages in queue policy",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
-								Name:  "output-file-name",
-								Value: "ssm.json",
+								Name:     "topic-name",
+								Usage:    "topic name or arn",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "queue-name",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "raw",
+								Usage: "enable raw message delivery",
+							},
+							&cli.StringFlag{
+								Name:  "filter-policy",
+								Usage: `e.g. '{"eventType":["order_placed"]}'`,
+							},
+							&cli.StringFlag{
+								Name:  "filter-policy-scope",
+								Usage: "MessageAttributes or MessageBody",
 							},
 							&cli.StringFlag{
 								Name:  "profile",
@@ -1780,42 +2028,2645 @@ func main() {
 						},
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
-							outFileName := c.String("output-file-name")
-							sess := session.NewSessionWithSharedProfile(profile)
-
-							ssmc := ssm.New(sess)
-
-							listTopicsParams := ssm.DescribeParametersInput{}
-							var parameters []Parameter
-							err := ssmc.DescribeParametersPages(&listTopicsParams, func(output *ssm.DescribeParametersOutput, lastPage bool) bool {
-								fmt.Println(output.Parameters)
-								for _, elem := range output.Parameters {
-									param := ssm.GetParameterInput{
-										Name:           elem.Name,
-										WithDecryption: aws.Bool(true),
-									}
-									out, _ := ssmc.GetParameter(&param)
+							queueName := c.String("queue-name")
+							filterPolicy := c.String("filter-policy")
+							if filterPolicy != "" && !json.Valid([]byte(filterPolicy)) {
+								return fmt.Errorf("filter-policy is not valid json")
+							}
 
-									parameters = append(parameters, Parameter{
-										ParameterMetadata: elem,
-										ParameterValue:    out.Parameter,
-									})
-								}
-								return lastPage == false
-							})
+							sess := session.NewSessionWithSharedProfile(profile)
+							snsClient, err := flowsns.NewSNSClient(sns.New(sess))
 							if err != nil {
 								return err
 							}
+							sqsClient, err := flowsqs.NewSQSClient(sqs.New(sess))
+							if err != nil {
+								return err
+							}
+
+							topicArn, err := snsClient.TopicArn(c.Context, c.String("topic-name"))
+							if err != nil {
+								return err
+							}
+							queueArn, err := sqsClient.AllowSendMessage(c.Context, queueName, topicArn)
+							if err != nil {
+								return err
+							}
+							subscriptionArn, err := snsClient.SubscribeQueue(c.Context, topicArn, queueArn, flowsns.QueueSubscription{
+								RawMessageDelivery: c.Bool("raw"),
+								FilterPolicy:       filterPolicy,
+								FilterPolicyScope:  c.String("filter-policy-scope"),
+							})
+							if err != nil {
+								return err
+							}
+							fmt.Printf("subscribed %s\n", subscriptionArn)
+
+							return nil
+						},
+					},
+					{
+						Name:  "unsubscribe-queue",
+						Usage: "removes queue subscriptions to topic and the topic statement added by subscribe-queue from queue policy",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "topic-name",
+								Usage:    "topic name or arn",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "queue-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							queueName := c.String("queue-name")
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							snsClient, err := flowsns.NewSNSClient(sns.New(sess))
+							if err != nil {
+								return err
+							}
+							sqsClient, err := flowsqs.NewSQSClient(sqs.New(sess))
+							if err != nil {
+								return err
+							}
+
+							topicArn, err := snsClient.TopicArn(c.Context, c.String("topic-name"))
+							if err != nil {
+								return err
+							}
+							queueArn, err := sqsClient.QueueArn(c.Context, queueName)
+							if err != nil {
+								return err
+							}
+							// unsubscribe first, a subscription left behind must still be able to deliver
+							subscriptionArns, err := snsClient.UnsubscribeQueue(c.Context, topicArn, queueArn)
+							if err != nil {
+								return err
+							}
+							for _, arn := range subscriptionArns {
+								fmt.Printf("unsubscribed %s\n", arn)
+							}
+							_, err = sqsClient.RevokeSendMessage(c.Context, queueName, topicArn)
+							return err
+						},
+					},
+				},
+			}
+		}(),
+		func() *cli.Command {
+			return &cli.Command{
+				Name:  "cloudwatch",
+				Usage: "AWS CloudWatch",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list-alarms",
+						Usage: "list metric and composite alarms with their state",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "prefix",
+								Usage: "only alarms with names starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:  "state",
+								Usage: "only alarms in the state, 'OK', 'ALARM' or 'INSUFFICIENT_DATA'",
+							},
+							&cli.StringFlag{
+								Name:  "namespace",
+								Usage: "only metric alarms of the namespace, e.g. AWS/SQS",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwc := cloudwatch.New(sess)
+
+							alarms, err := flowcloudwatch.Alarms(c.Context, flowcloudwatch.AlarmFilter{
+								Prefix:    c.String("prefix"),
+								State:     c.String("state"),
+								Namespace: c.String("namespace"),
+							}, cwc)
+							if err != nil {
+								return err
+							}
+							return flowcloudwatch.WriteAlarms(os.Stdout, alarms, c.String("output"))
+						},
+					},
+					{
+						Name:  "history",
+						Usage: "print alarm state transitions, newest first",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "alarm name, all alarms when not set",
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "start time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "1d",
+							},
+							&cli.StringFlag{
+								Name:  "end",
+								Usage: "end time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "now",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							now := time.Now()
+							startTime, err := logs.ParseTime(c.String("start"), now)
+							if err != nil {
+								return err
+							}
+							endTime, err := logs.ParseTime(c.String("end"), now)
+							if err != nil {
+								return err
+							}
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwc := cloudwatch.New(sess)
+
+							items, err := flowcloudwatch.History(c.Context, c.String("name"), startTime, endTime, cwc)
+							if err != nil {
+								return err
+							}
+							return flowcloudwatch.WriteHistory(os.Stdout, items, c.String("output"))
+						},
+					},
+					{
+						Name:  "get-metrics",
+						Usage: "get metric data for metric queries, expressions or a preset as a chart, csv or json",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "metric",
+								Usage: "metric query of key=value pairs, e.g. namespace=AWS/SQS,metric=NumberOfMessagesSent,dim.QueueName=orders,stat=Sum; ids default to m1, m2 and so on, can be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "expression",
+								Usage: "metric math expression with an optional id, e.g. errorRate=100*m2/m1, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "preset",
+								Usage: fmt.Sprintf("metrics of a common resource, one of %s", strings.Join(flowcloudwatch.Presets, ", ")),
+							},
+							&cli.StringFlag{
+								Name:  "resource",
+								Usage: "table, queue or function name for the preset",
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "start time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "1h",
+							},
+							&cli.StringFlag{
+								Name:  "end",
+								Usage: "end time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "now",
+							},
+							&cli.Int64Flag{
+								Name:  "period",
+								Usage: "period in seconds of metric queries without one",
+								Value: flowcloudwatch.DefaultPeriod,
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'chart', 'csv' or 'json'",
+								Value: "chart",
+							},
+							&cli.IntFlag{
+								Name:  "width",
+								Usage: "max chart width in characters",
+								Value: 60,
+							},
+							&cli.DurationFlag{
+								Name:  "watch",
+								Usage: "get the metrics again every interval, e.g. 1m, until interrupted",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							var queries []flowcloudwatch.MetricQuery
+							for _, s := range c.StringSlice("metric") {
+								q, err := flowcloudwatch.ParseMetricQuery(s, c.Int64("period"))
+								if err != nil {
+									return err
+								}
+								queries = append(queries, q)
+							}
+							for _, s := range c.StringSlice("expression") {
+								q, err := flowcloudwatch.ParseMetricExpression(s)
+								if err != nil {
+									return err
+								}
+								queries = append(queries, q)
+							}
+							// m1, m2 and so on refer to the --metric and --expression queries only
+							queries = flowcloudwatch.WithDefaultIds(queries)
+							if preset := c.String("preset"); preset != "" {
+								q, err := flowcloudwatch.Preset(preset, c.String("resource"), c.Int64("period"))
+								if err != nil {
+									return err
+								}
+								queries = append(q, queries...)
+							}
+							if len(queries) == 0 {
+								return fmt.Errorf("metric, expression or preset is required")
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwc := cloudwatch.New(sess)
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							for {
+								now := time.Now()
+								startTime, err := logs.ParseTime(c.String("start"), now)
+								if err != nil {
+									return err
+								}
+								endTime, err := logs.ParseTime(c.String("end"), now)
+								if err != nil {
+									return err
+								}
+								series, err := flowcloudwatch.GetMetrics(ctx, queries, startTime, endTime, cwc)
+								if err != nil {
+									if ctx.Err() != nil {
+										return nil
+									}
+									return err
+								}
+								if err := flowcloudwatch.WriteMetrics(os.Stdout, series, c.String("output"), c.Int("width")); err != nil {
+									return err
+								}
+								if c.Duration("watch") <= 0 {
+									return nil
+								}
+								select {
+								case <-ctx.Done():
+									return nil
+								case <-time.After(c.Duration("watch")):
+								}
+							}
+						},
+					},
+					{
+						Name:  "disable-actions",
+						Usage: "disable the actions of alarms, the alarms still change state",
+						Flags: alarmFlags(),
+						Action: func(c *cli.Context) error {
+							cwc := cloudwatch.New(session.NewSessionWithSharedProfile(c.String("profile")))
+							alarms, err := selectAlarms(c, cwc)
+							if err != nil {
+								return err
+							}
+							if err := flowcloudwatch.DisableActions(c.Context, flowcloudwatch.AlarmNames(alarms), cwc); err != nil {
+								return err
+							}
+							log.Printf("disabled actions of %d alarms", len(alarms))
+							return nil
+						},
+					},
+					{
+						Name:  "enable-actions",
+						Usage: "enable the actions of alarms",
+						Flags: alarmFlags(),
+						Action: func(c *cli.Context) error {
+							cwc := cloudwatch.New(session.NewSessionWithSharedProfile(c.String("profile")))
+							alarms, err := selectAlarms(c, cwc)
+							if err != nil {
+								return err
+							}
+							if err := flowcloudwatch.EnableActions(c.Context, flowcloudwatch.AlarmNames(alarms), cwc); err != nil {
+								return err
+							}
+							log.Printf("enabled actions of %d alarms", len(alarms))
+							return nil
+						},
+					},
+					{
+						Name:  "silence",
+						Usage: "disable the actions of alarms for a while, e.g. during a load test, and enable them again when the time is up or on Ctrl-C",
+						Flags: append([]cli.Flag{
+							&cli.DurationFlag{
+								Name:     "for",
+								Usage:    "how long to silence the alarms, e.g. 2h",
+								Required: true,
+							},
+						}, alarmFlags()...),
+						Action: func(c *cli.Context) error {
+							cwc := cloudwatch.New(session.NewSessionWithSharedProfile(c.String("profile")))
+							alarms, err := selectAlarms(c, cwc)
+							if err != nil {
+								return err
+							}
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							d := c.Duration("for")
+							log.Printf("silencing alarms until %s, keep this running or the actions stay disabled", time.Now().Add(d).Format(time.RFC3339))
+							names, err := flowcloudwatch.Silence(ctx, alarms, d, cwc)
+							if err != nil {
+								return err
+							}
+							log.Printf("enabled actions of %d alarms again", len(names))
+							return nil
+						},
+					},
+					{
+						Name:  "delete-alarm",
+						Usage: "deletes cloudwatch alarm(s) by name or prefix",
+						Flags: append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the alarms without deleting them",
+							},
+							&cli.BoolFlag{
+								Name:  "yes",
+								Usage: "do not ask for confirmation of alarms selected by prefix, names are deleted without it",
+							},
+						}, alarmFlags()...),
+						Action: func(c *cli.Context) error {
+							cwc := cloudwatch.New(session.NewSessionWithSharedProfile(c.String("profile")))
+							if names := c.StringSlice("name"); len(names) > 0 && c.String("prefix") == "" && !c.Bool("dry-run") {
+								// exact names are deleted as before, without a prompt
+								if err := flowcloudwatch.DeleteAlarms(c.Context, names, cwc); err != nil {
+									return err
+								}
+								log.Printf("deleted %d alarms", len(names))
+								return nil
+							}
+
+							alarms, err := selectAlarms(c, cwc)
+							if err != nil {
+								return err
+							}
+							if err := flowcloudwatch.WriteAlarms(os.Stdout, alarms, "table"); err != nil {
+								return err
+							}
+							if c.Bool("dry-run") {
+								log.Printf("dry run, %d alarms would be deleted", len(alarms))
+								return nil
+							}
+							if !c.Bool("yes") && !confirm(fmt.Sprintf("delete %d alarms?", len(alarms))) {
+								log.Printf("not confirmed, nothing was deleted")
+								return nil
+							}
+							if err := flowcloudwatch.DeleteAlarms(c.Context, flowcloudwatch.AlarmNames(alarms), cwc); err != nil {
+								return err
+							}
+							log.Printf("deleted %d alarms", len(alarms))
+							return nil
+						},
+					},
+					{
+						Name:  "dashboard",
+						Usage: "export, import and compare dashboards as json files",
+						Subcommands: []*cli.Command{
+							{
+								Name:  "export",
+								Usage: "save dashboard bodies to <dir>/<name>.json",
+								Flags: []cli.Flag{
+									&cli.StringSliceFlag{
+										Name:  "name",
+										Usage: "dashboard name, can be repeated",
+									},
+									&cli.StringFlag{
+										Name:  "prefix",
+										Usage: "all dashboards with names starting with the prefix",
+									},
+									&cli.StringFlag{
+										Name:  "dir",
+										Usage: "directory for the dashboard files",
+										Value: "dashboards",
+									},
+									&cli.BoolFlag{
+										Name:  "templatize",
+										Usage: "replace the region, env and var values with {{.Region}}, {{.Env}} and {{.<var>}}",
+									},
+									&cli.StringFlag{
+										Name:  "env",
+										Usage: "value of {{.Env}}",
+									},
+									&cli.StringSliceFlag{
+										Name:  "var",
+										Usage: "template variable key=value, can be repeated",
+									},
+									&cli.StringFlag{
+										Name:  "profile",
+										Value: "",
+									},
+								},
+								Action: func(c *cli.Context) error {
+									profile := c.String("profile")
+									names := c.StringSlice("name")
+									prefix := c.String("prefix")
+									if len(names) == 0 && prefix == "" {
+										return fmt.Errorf("name or prefix is required")
+									}
+									sess := session.NewSessionWithSharedProfile(profile)
+									cwc := cloudwatch.New(sess)
+									// without templatize only the existing {{ are escaped, import renders every file
+									var vars map[string]string
+									if c.Bool("templatize") {
+										var err error
+										vars, err = dashboardVars(c, sess)
+										if err != nil {
+											return err
+										}
+									}
+
+									if prefix != "" {
+										found, err := flowcloudwatch.Dashboards(c.Context, prefix, cwc)
+										if err != nil {
+											return err
+										}
+										names = append(names, found...)
+									}
+									if len(names) == 0 {
+										return fmt.Errorf("no dashboards found")
+									}
+									if err := os.MkdirAll(c.String("dir"), 0755); err != nil {
+										return err
+									}
+									for _, name := range names {
+										body, err := flowcloudwatch.GetDashboard(c.Context, name, cwc)
+										if err != nil {
+											return err
+										}
+										body = flowcloudwatch.Templatize(body, vars)
+										fileName := filepath.Join(c.String("dir"), name+".json")
+										if err := os.WriteFile(fileName, []byte(body), 0644); err != nil {
+											return err
+										}
+										log.Printf("exported %s to %s", name, fileName)
+									}
+									return nil
+								},
+							},
+							{
+								Name:  "import",
+								Usage: "create or update dashboards from files, bodies are text/template with {{.Env}}, {{.Region}} and --var variables",
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:     "file",
+										Usage:    "dashboard file or directory with <name>.json files",
+										Required: true,
+									},
+									&cli.StringFlag{
+										Name:  "name",
+										Usage: "dashboard name for a single file, defaults to the file name",
+									},
+									&cli.StringFlag{
+										Name:  "env",
+										Usage: "value of {{.Env}}",
+									},
+									&cli.StringSliceFlag{
+										Name:  "var",
+										Usage: "template variable key=value, can be repeated",
+									},
+									&cli.BoolFlag{
+										Name:  "dry-run",
+										Usage: "print the rendered dashboards without importing them",
+									},
+									&cli.StringFlag{
+										Name:  "profile",
+										Value: "",
+									},
+								},
+								Action: func(c *cli.Context) error {
+									profile := c.String("profile")
+									sess := session.NewSessionWithSharedProfile(profile)
+									cwc := cloudwatch.New(sess)
+									dashboards, err := renderDashboards(c, sess)
+									if err != nil {
+										return err
+									}
+									for _, d := range dashboards {
+										if c.Bool("dry-run") {
+											fmt.Printf("# %s\n%s", d.name, d.body)
+											continue
+										}
+										messages, err := flowcloudwatch.PutDashboard(c.Context, d.name, d.body, cwc)
+										if err != nil {
+											return err
+										}
+										for _, m := range messages {
+											log.Printf("%s: %s", d.name, m)
+										}
+										log.Printf("imported %s from %s", d.name, d.file)
+									}
+									return nil
+								},
+							},
+							{
+								Name:  "diff",
+								Usage: "compare dashboard files with the live dashboards, exits with 1 when they differ",
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:     "file",
+										Usage:    "dashboard file or directory with <name>.json files",
+										Required: true,
+									},
+									&cli.StringFlag{
+										Name:  "name",
+										Usage: "dashboard name for a single file, defaults to the file name",
+									},
+									&cli.StringFlag{
+										Name:  "env",
+										Usage: "value of {{.Env}}",
+									},
+									&cli.StringSliceFlag{
+										Name:  "var",
+										Usage: "template variable key=value, can be repeated",
+									},
+									&cli.StringFlag{
+										Name:  "profile",
+										Value: "",
+									},
+								},
+								Action: func(c *cli.Context) error {
+									profile := c.String("profile")
+									sess := session.NewSessionWithSharedProfile(profile)
+									cwc := cloudwatch.New(sess)
+									dashboards, err := renderDashboards(c, sess)
+									if err != nil {
+										return err
+									}
+									differ := 0
+									for _, d := range dashboards {
+										live, err := flowcloudwatch.GetDashboard(c.Context, d.name, cwc)
+										if err == flowcloudwatch.ErrDashboardNotFound {
+											fmt.Printf("+++ %s does not exist\n", d.name)
+											differ++
+											continue
+										}
+										if err != nil {
+											return err
+										}
+										if diff := flowcloudwatch.Diff(live, d.body); diff != "" {
+											fmt.Printf("--- %s (live)\n+++ %s\n%s", d.name, d.file, diff)
+											differ++
+										}
+									}
+									if differ > 0 {
+										return cli.Exit(fmt.Sprintf("%d of %d dashboards differ", differ, len(dashboards)), 1)
+									}
+									log.Printf("%d dashboards are up to date", len(dashboards))
+									return nil
+								},
+							},
+						},
+					},
+				},
+			}
+		}(),
+		func() *cli.Command {
+			const logGroupDefault = "aws-waf-logs-"
+			return &cli.Command{
+				Name:  "cloudwatchlogs",
+				Usage: "AWS CloudWatch Logs",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "retention",
+						Usage: "set log group retention in days for a log group, a prefix or all log groups",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "log-group-name",
+								Value: "",
+							},
+							&cli.StringFlag{
+								Name:  "prefix",
+								Usage: "set retention for all log groups starting with the prefix",
+							},
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "set retention for all log groups",
+							},
+							&cli.StringFlag{
+								Name:  "days",
+								Usage: "retention in days",
+								Value: "",
+							},
+							&cli.BoolFlag{
+								Name:  "only-if-unset",
+								Usage: "skip log groups that already have a retention",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the changes without applying them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							logGroupName := c.String("log-group-name")
+							prefix := c.String("prefix")
+							days := c.String("days")
+							if days == "" {
+								return fmt.Errorf("days is required")
+							}
+
+							retention, err := strconv.ParseInt(days, 10, 0)
+							if err != nil {
+								return err
+							}
+							if !logs.ValidRetention(retention) {
+								return fmt.Errorf("days must be one of %v", logs.RetentionDays)
+							}
+							if logGroupName == "" && prefix == "" && !c.Bool("all") {
+								return fmt.Errorf("log-group-name, prefix or all is required")
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+							if logGroupName != "" && !c.Bool("only-if-unset") && !c.Bool("dry-run") {
+								return logs.SetRetention(logGroupName, retention, cwlc)
+							}
+
+							var logGroups []*cloudwatchlogs.LogGroup
+							if c.Bool("all") {
+								logGroups, err = logs.Describe(c.Context, nil, cwlc)
+							} else {
+								var names []string
+								if logGroupName != "" {
+									names = append(names, logGroupName)
+								}
+								logGroups, err = logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							}
+							if err != nil {
+								return err
+							}
+
+							changes := logs.PlanRetention(logGroups, retention, c.Bool("only-if-unset"))
+							for _, change := range changes {
+								from := "never expire"
+								if change.From != nil {
+									from = fmt.Sprintf("%d days", *change.From)
+								}
+								fmt.Printf("%s: %s -> %d days\n", change.LogGroupName, from, change.To)
+								if c.Bool("dry-run") {
+									continue
+								}
+								if err := logs.SetRetention(change.LogGroupName, change.To, cwlc); err != nil {
+									return errors.Wrapf(err, "failed to set retention for %s", change.LogGroupName)
+								}
+							}
+							log.Printf("%d of %d log groups changed", len(changes), len(logGroups))
+							if c.Bool("dry-run") {
+								log.Printf("dry run, nothing changed")
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "prune",
+						Usage: "delete empty or stale log groups and streams",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "prefix",
+								Usage: "prune log groups starting with the prefix",
+							},
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "prune all log groups",
+							},
+							&cli.BoolFlag{
+								Name:  "empty",
+								Usage: "only delete log groups and streams without any events",
+							},
+							&cli.StringFlag{
+								Name:  "older-than",
+								Usage: "only delete log groups and streams created before, and without events since, e.g. 30d",
+							},
+							&cli.BoolFlag{
+								Name:  "streams",
+								Usage: "also delete empty or stale streams of log groups that are kept",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print what would be deleted",
+							},
+							&cli.BoolFlag{
+								Name:  "yes",
+								Usage: "do not ask for confirmation",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							prefix := c.String("prefix")
+							if prefix == "" && !c.Bool("all") {
+								return fmt.Errorf("prefix or all is required")
+							}
+							if !c.Bool("empty") && c.String("older-than") == "" {
+								return fmt.Errorf("empty or older-than is required")
+							}
+							before := time.Now()
+							if s := c.String("older-than"); s != "" {
+								d, err := logs.ParseDuration(s)
+								if err != nil {
+									return err
+								}
+								before = before.Add(-d)
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.Describe(c.Context, &prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							candidates, err := logs.PlanPrune(c.Context, logGroups, logs.PruneOptions{
+								Before:  before,
+								Empty:   c.Bool("empty"),
+								Streams: c.Bool("streams"),
+							}, cwlc)
+							if err != nil {
+								return err
+							}
+							if len(candidates) == 0 {
+								log.Printf("nothing to prune in %d log groups", len(logGroups))
+								return nil
+							}
+							if err := logs.WritePruneTable(os.Stdout, candidates); err != nil {
+								return err
+							}
+							if c.Bool("dry-run") {
+								log.Printf("dry run, %d log groups and streams would be deleted", len(candidates))
+								return nil
+							}
+							if !c.Bool("yes") && !confirm(fmt.Sprintf("delete %d log groups and streams?", len(candidates))) {
+								log.Printf("not confirmed, nothing was deleted")
+								return nil
+							}
+
+							if err := logs.Prune(c.Context, candidates, cwlc); err != nil {
+								return err
+							}
+							log.Printf("deleted %d log groups and streams", len(candidates))
+
+							return nil
+						},
+					},
+					{
+						Name:  "cost",
+						Usage: "estimated monthly storage cost per log group, most expensive first",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Value: "",
+							},
+							&cli.Float64Flag{
+								Name:  "price-per-gb",
+								Usage: "monthly storage price per GB in the region",
+								Value: logs.DefaultPricePerGB,
+							},
+							&cli.IntFlag{
+								Name:  "top",
+								Usage: "only the most expensive log groups, 0 prints all",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+							logGroupNamePrefix := c.String("log-group-name-prefix")
+							logGroups, err := logs.Describe(c.Context, &logGroupNamePrefix, cwlc)
+							if err != nil {
+								return err
+							}
+
+							costs := logs.Cost(logGroups, c.Float64("price-per-gb"))
+							if top := c.Int("top"); top > 0 && top < len(costs) {
+								costs = costs[:top]
+							}
+							return logs.WriteCost(os.Stdout, costs, c.String("output"))
+						},
+					},
+					{
+						Name:  "write-to-file",
+						Usage: "streams events to jsonl, csv or text files",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "log-group-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "file-name",
+								Usage: "output file name, defaults to output with the format as extension",
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "'jsonl', 'csv' or 'text'",
+								Value: "jsonl",
+							},
+							&cli.StringFlag{
+								Name:  "filter-pattern",
+								Usage: "the filter pattern to use. If not provided, all the events are matched",
+								Value: "",
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "start time, RFC3339 or relative like 15m, 2h or 7d. If not provided, events from the beginning are written",
+							},
+							&cli.StringFlag{
+								Name:  "end",
+								Usage: "end time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "now",
+							},
+							&cli.StringFlag{
+								Name:  "max-file-size",
+								Usage: "split output into files of at most this size, e.g. 100MB or 1GB",
+							},
+							&cli.BoolFlag{
+								Name:  "resume",
+								Usage: "continue an interrupted export from its resume token",
+							},
+							&cli.StringFlag{
+								Name:  "region",
+								Usage: "AWS region, overrides the profile region",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							format := c.String("format")
+							fileName := c.String("file-name")
+							if fileName == "" {
+								fileName = "output." + format
+							}
+							maxFileSize, err := parseSize(c.String("max-file-size"))
+							if err != nil {
+								return err
+							}
+
+							now := time.Now()
+							var startTime time.Time
+							if c.String("start") != "" {
+								startTime, err = logs.ParseTime(c.String("start"), now)
+								if err != nil {
+									return err
+								}
+							}
+							endTime, err := logs.ParseTime(c.String("end"), now)
+							if err != nil {
+								return err
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							if reg := c.String("region"); reg != "" {
+								sess.Config.Region = aws.String(reg)
+							}
+							cwlc := cloudwatchlogs.New(sess)
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							resumeFileName := fileName + ".resume"
+							result, err := logs.Export(ctx, logs.ExportOptions{
+								LogGroupName:   c.String("log-group-name"),
+								FilterPattern:  c.String("filter-pattern"),
+								StartTime:      startTime,
+								EndTime:        endTime,
+								Format:         format,
+								FileName:       fileName,
+								MaxFileSize:    maxFileSize,
+								ResumeFileName: resumeFileName,
+								Resume:         c.Bool("resume"),
+							}, cwlc)
+							if err != nil {
+								if _, statErr := os.Stat(resumeFileName); statErr == nil {
+									return errors.Wrapf(err, "export interrupted, run again with --resume to continue from %s", resumeFileName)
+								}
+								return err
+							}
+
+							log.Printf("wrote %d events to %s\n", result.Events, strings.Join(result.Files, ", "))
+
+							return nil
+						},
+					},
+					{
+						Name:  "delete-subscription-filter",
+						Usage: "delete subscription for log group",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "log-group-name",
+								Value: "",
+							},
+							&cli.StringFlag{
+								Name:  "filter-name",
+								Value: "",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							logGroupName := c.String("log-group-name")
+							if logGroupName == "" {
+								return fmt.Errorf("log-group-name is required")
+							}
+							filterName := c.String("filter-name")
+							if filterName == "" {
+								return fmt.Errorf("filter-name is required")
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							params := cloudwatchlogs.DeleteSubscriptionFilterInput{
+								LogGroupName: &logGroupName,
+								FilterName:   &filterName,
+							}
+
+							_, err := cwlc.DeleteSubscriptionFilter(&params)
+							if err != nil {
+								return fmt.Errorf("DeleteSubscriptionFilter failed: %s", err)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "delete-all-subscription-filters",
+						Usage: "delete all subscriptions for log groups",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							profile := c.String("profile")
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							for _, lg := range logGroups {
+								deleted, err := logs.DeleteSubscriptionFilters(c.Context, *lg.LogGroupName, cwlc)
+								for _, name := range deleted {
+									log.Printf("deleted subscription filter %s of %s", name, *lg.LogGroupName)
+								}
+								if err != nil {
+									return err
+								}
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "put-events",
+						Usage: "put events from a file of plain lines, jsonl or csv exported by flow into a log stream",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "log-group-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "log-stream-name",
+								Usage: "log stream name, defaults to flow- followed by the current time",
+							},
+							&cli.StringFlag{
+								Name:     "input-file",
+								Usage:    "file with one message per line, jsonl or csv exported by write-to-file or get-log-events",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "'text', 'jsonl' or 'csv', defaults to the file extension",
+							},
+							&cli.BoolFlag{
+								Name:  "rebase",
+								Usage: "shift timestamps so the latest event is now, keeping the time between events",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							logGroupName := c.String("log-group-name")
+							logStreamName := c.String("log-stream-name")
+							if logStreamName == "" {
+								logStreamName = "flow-" + time.Now().UTC().Format("20060102T150405Z")
+							}
+							fileName := c.String("input-file")
+							format := c.String("format")
+							if format == "" {
+								format = logs.FileFormat(fileName)
+							}
+
+							f, err := os.Open(fileName)
+							if err != nil {
+								return err
+							}
+							defer f.Close()
+							var records []*logs.Record
+							err = logs.ReadRecords(f, format, func(r *logs.Record) error {
+								records = append(records, r)
+								return nil
+							})
+							if err != nil {
+								return err
+							}
+							events := logs.InputEvents(records, c.Bool("rebase"), time.Now())
+							if len(events) == 0 {
+								return fmt.Errorf("no events in %s", fileName)
+							}
+
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+
+							if err := logs.EnsureLogStream(c.Context, logGroupName, logStreamName, cwlc); err != nil {
+								return err
+							}
+							result, err := logs.PutEvents(c.Context, logGroupName, logStreamName, events, cwlc)
+							if err != nil {
+								return err
+							}
+
+							log.Printf("put %d events in %d batches to %s %s", result.Sent, result.Batches, logGroupName, logStreamName)
+							if result.Rejected > 0 {
+								log.Printf("warning: %d events were rejected, %d too old, %d expired and %d too new, use --rebase to move them to now", result.Rejected, result.TooOld, result.Expired, result.TooNew)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "list-subscription-filters",
+						Usage: "list subscription filters of log groups",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							filters := []*cloudwatchlogs.SubscriptionFilter{}
+							for _, lg := range logGroups {
+								lgFilters, err := logs.SubscriptionFilters(c.Context, *lg.LogGroupName, cwlc)
+								if err != nil {
+									return err
+								}
+								filters = append(filters, lgFilters...)
+							}
+
+							if c.String("output") == "json" {
+								jsonBytes, err := json.Marshal(filters)
+								if err != nil {
+									return err
+								}
+								fmt.Println(string(jsonBytes))
+								return nil
+							}
+							return logs.WriteSubscriptionFiltersTable(os.Stdout, filters)
+						},
+					},
+					{
+						Name:  "put-subscription-filter",
+						Usage: "create or update a subscription filter to a Lambda, Kinesis or Firehose destination",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:     "filter-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "filter-pattern",
+								Usage: "the filter pattern to use. If not provided, all the events are matched",
+							},
+							&cli.StringFlag{
+								Name:     "destination-arn",
+								Usage:    "arn of the Lambda function, Kinesis stream or Firehose delivery stream",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "role-arn",
+								Usage: "role that allows CloudWatch Logs to put records to a Kinesis or Firehose destination",
+							},
+							&cli.StringFlag{
+								Name:  "distribution",
+								Usage: "'ByLogStream' or 'Random', Kinesis destinations only",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the log groups without changing them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							filter := logs.SubscriptionFilter{
+								FilterName:     c.String("filter-name"),
+								FilterPattern:  c.String("filter-pattern"),
+								DestinationArn: c.String("destination-arn"),
+								RoleArn:        c.String("role-arn"),
+								Distribution:   c.String("distribution"),
+							}
+							service, err := filter.DestinationService()
+							if err != nil {
+								return err
+							}
+
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+							lc := lambda.New(sess)
+
+							logGroups, err := logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							for _, lg := range logGroups {
+								fmt.Printf("%s: %s -> %s\n", *lg.LogGroupName, filter.FilterName, filter.DestinationArn)
+								if c.Bool("dry-run") {
+									continue
+								}
+								if service == "lambda" {
+									if err := logs.AllowLogsToInvoke(c.Context, filter.DestinationArn, aws.StringValue(lg.Arn), lc); err != nil {
+										return err
+									}
+								}
+								if err := logs.PutSubscriptionFilter(c.Context, *lg.LogGroupName, filter, cwlc); err != nil {
+									return err
+								}
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "list-metric-filters",
+						Usage: "list metric filters of log groups",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							filters := []*cloudwatchlogs.MetricFilter{}
+							for _, lg := range logGroups {
+								lgFilters, err := logs.MetricFilters(c.Context, *lg.LogGroupName, cwlc)
+								if err != nil {
+									return err
+								}
+								filters = append(filters, lgFilters...)
+							}
+
+							if c.String("output") == "json" {
+								jsonBytes, err := json.Marshal(filters)
+								if err != nil {
+									return err
+								}
+								fmt.Println(string(jsonBytes))
+								return nil
+							}
+							return logs.WriteMetricFiltersTable(os.Stdout, filters)
+						},
+					},
+					{
+						Name:  "put-metric-filter",
+						Usage: "create or update a metric filter",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:     "filter-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "filter-pattern",
+								Usage:    "e.g. '{ $.level = \"ERROR\" }'",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "metric-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "metric-namespace",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "metric-value",
+								Usage: "number or field selector like $.latency",
+								Value: "1",
+							},
+							&cli.StringFlag{
+								Name:  "default-value",
+								Usage: "value published when no event matches, can not be used with dimensions",
+							},
+							&cli.StringFlag{
+								Name:  "unit",
+								Usage: "metric unit, e.g. Count or Milliseconds",
+							},
+							&cli.StringSliceFlag{
+								Name:  "dimension",
+								Usage: "dimension in name=selector format, e.g. Service=$.service, can be repeated",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the log groups without changing them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							dimensions, err := parseTags(c.StringSlice("dimension"))
+							if err != nil {
+								return err
+							}
+							filter := logs.MetricFilter{
+								FilterName:      c.String("filter-name"),
+								FilterPattern:   c.String("filter-pattern"),
+								MetricName:      c.String("metric-name"),
+								MetricNamespace: c.String("metric-namespace"),
+								MetricValue:     c.String("metric-value"),
+								Unit:            c.String("unit"),
+								Dimensions:      dimensions,
+							}
+							if s := c.String("default-value"); s != "" {
+								v, err := strconv.ParseFloat(s, 64)
+								if err != nil {
+									return errors.Wrap(err, "invalid default-value")
+								}
+								filter.DefaultValue = &v
+							}
+
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							for _, lg := range logGroups {
+								fmt.Printf("%s: %s -> %s/%s\n", *lg.LogGroupName, filter.FilterName, filter.MetricNamespace, filter.MetricName)
+								if c.Bool("dry-run") {
+									continue
+								}
+								if err := logs.PutMetricFilter(c.Context, *lg.LogGroupName, filter, cwlc); err != nil {
+									return err
+								}
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "delete-metric-filter",
+						Usage: "delete a metric filter from log groups",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:     "filter-name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.ResolveLogGroups(c.Context, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+							for _, lg := range logGroups {
+								deleted, err := logs.DeleteMetricFilter(c.Context, *lg.LogGroupName, c.String("filter-name"), cwlc)
+								if err != nil {
+									return err
+								}
+								if deleted {
+									log.Printf("deleted metric filter %s of %s", c.String("filter-name"), *lg.LogGroupName)
+								}
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:  "test-metric-filter",
+						Usage: "test a filter pattern against sample lines or an exported log file",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "filter-pattern",
+								Required: true,
+							},
+							&cli.StringSliceFlag{
+								Name:  "line",
+								Usage: "sample log line, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "input-file",
+								Usage: "file exported by write-to-file or get-log-events, or one message per line",
+							},
+							&cli.BoolFlag{
+								Name:  "show-values",
+								Usage: "print the extracted values of each match",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							messages := c.StringSlice("line")
+							if fileName := c.String("input-file"); fileName != "" {
+								f, err := os.Open(fileName)
+								if err != nil {
+									return err
+								}
+								defer f.Close()
+								err = logs.ReadRecords(f, logs.FileFormat(fileName), func(r *logs.Record) error {
+									messages = append(messages, r.Message)
+									return nil
+								})
+								if err != nil {
+									return err
+								}
+							}
+							if len(messages) == 0 {
+								return fmt.Errorf("line or input-file is required")
+							}
+
+							sess := session.NewSessionWithSharedProfile(c.String("profile"))
+							cwlc := cloudwatchlogs.New(sess)
+
+							matches, err := logs.TestMetricFilter(c.Context, c.String("filter-pattern"), messages, cwlc)
+							if err != nil {
+								return err
+							}
+							for _, m := range matches {
+								fmt.Printf("%d: %s\n", aws.Int64Value(m.EventNumber), strings.TrimRight(aws.StringValue(m.EventMessage), "\r\n"))
+								if c.Bool("show-values") {
+									var keys []string
+									for k := range m.ExtractedValues {
+										keys = append(keys, k)
+									}
+									sort.Strings(keys)
+									for _, k := range keys {
+										fmt.Printf("    %s = %s\n", k, aws.StringValue(m.ExtractedValues[k]))
+									}
+								}
+							}
+							log.Printf("%d of %d lines matched", len(matches), len(messages))
+
+							return nil
+						},
+					},
+					{
+						Name:  "describe",
+						Usage: "describe log groups",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Value: "",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							logGroupNamePrefix := c.String("log-group-name-prefix")
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							logGroups, err := logs.Describe(c.Context, &logGroupNamePrefix, cwlc)
+							if err != nil {
+								return err
+							}
+
+							jsonBytes, err := json.Marshal(logGroups)
+							if err != nil {
+								return err
+							}
+
+							fmt.Print(string(jsonBytes))
+
+							return nil
+						},
+					},
+					{
+						Name:  "summary",
+						Usage: "summary log groups",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Value: "",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+							logGroupNamePrefix := c.String("log-group-name-prefix")
+							logGroups, err := logs.Describe(c.Context, &logGroupNamePrefix, cwlc)
+							if err != nil {
+								return err
+							}
+
+							summary := logs.Summary(logGroups)
+							jsonBytes, err := json.Marshal(summary)
+							if err != nil {
+								return err
+							}
+							fmt.Print(string(jsonBytes))
+
+							return nil
+						},
+					},
+					{
+						Name:  "get-log-events",
+						Usage: "get log events",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "log-group-name-prefix",
+								Required: false,
+								Value:    logGroupDefault,
+								Aliases:  []string{"l"},
+							},
+							&cli.StringFlag{
+								Name:    "file-name-prefix",
+								Value:   "logs",
+								Aliases: []string{"fp"},
+							},
+							&cli.Int64Flag{
+								Name:        "hours",
+								DefaultText: "Number of hours to retrieve logs.",
+								Value:       1,
+							},
+							&cli.IntFlag{
+								Name:  "concurrency",
+								Usage: "number of log streams read at the same time",
+								Value: logs.DefaultConcurrency,
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							sess := session.NewSessionWithSharedProfile(profile)
+							client := cloudwatchlogs.New(sess)
+							logGroupNamePrefix := c.String("log-group-name-prefix")
+							if logGroupNamePrefix == logGroupDefault {
+								describe, err := logs.Describe(c.Context, &logGroupNamePrefix, client)
+								if err != nil {
+									return errors.Wrap(err, "Describe failed")
+								}
+
+								for _, logGroup := range describe {
+									// find first matching the prefix
+									if strings.Contains(*logGroup.LogGroupName, logGroupNamePrefix) {
+										logGroupNamePrefix = *logGroup.LogGroupName
+									}
+									break
+								}
+							}
+
+							endTime := time.Now()
+							startTime := endTime.Add(-time.Duration(c.Int64("hours")) * time.Hour)
+
+							// create file name with start and end time in UTC
+							start := startTime.UTC().Format("2006-01-02T15:04:05")
+							start = strings.Replace(start, ":", "", -1)
+							start = strings.Replace(start, "-", "", -1)
+							end := endTime.UTC().Format("2006-01-02T15:04:05")
+							end = strings.Replace(end, ":", "", -1)
+							end = strings.Replace(end, "-", "", -1)
+							fileName := fmt.Sprintf("%s-%sZ-%sZ.csv", c.String("file-name-prefix"), start, end)
+							file, err := os.Create(fileName)
+							if err != nil {
+								return errors.Wrap(err, "failed to create file")
+							}
+							defer file.Close()
+
+							writer := csv.NewWriter(file)
+							defer writer.Flush()
+
+							if err := writer.Write([]string{"timestamp", "message", "logStreamName"}); err != nil {
+								return errors.Wrap(err, "failed to write csv header")
+							}
+
+							if err := logs.WriteLogEvents(c.Context, logGroupNamePrefix, startTime, endTime, c.Int("concurrency"), client, writer); err != nil {
+								return errors.Wrap(err, "failed to get log events")
+							}
+
+							log.Printf("wrote log events to %s from %s\n", fileName, logGroupNamePrefix)
+
+							return nil
+						},
+					},
+					{
+						Name:  "tail",
+						Usage: "print log events from one or more log groups, optionally following new events",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "tail all log groups starting with the prefix",
+							},
+							&cli.BoolFlag{
+								Name:    "follow",
+								Aliases: []string{"f"},
+								Usage:   "keep printing new events until interrupted",
+							},
+							&cli.DurationFlag{
+								Name:  "since",
+								Usage: "print events newer than the duration, e.g. 10m",
+								Value: 10 * time.Minute,
+							},
+							&cli.StringFlag{
+								Name:  "filter-pattern",
+								Usage: "the filter pattern to use. If not provided, all the events are matched",
+							},
+							&cli.StringSliceFlag{
+								Name:  "log-stream-name",
+								Usage: "only events from the log stream, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-stream-name-prefix",
+								Usage: "only events from log streams starting with the prefix",
+							},
+							&cli.BoolFlag{
+								Name:  "poll",
+								Usage: "poll FilterLogEvents instead of using live tail",
+							},
+							&cli.BoolFlag{
+								Name:  "no-color",
+								Usage: "do not colour log stream names",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							if len(names) == 0 && prefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							if len(c.StringSlice("log-stream-name")) > 0 && c.String("log-stream-name-prefix") != "" {
+								return fmt.Errorf("log-stream-name and log-stream-name-prefix can not be used together")
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							logGroups, err := logs.ResolveLogGroups(ctx, names, prefix, cwlc)
+							if err != nil {
+								return err
+							}
+
+							color := false
+							if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+								color = os.Getenv("NO_COLOR") == "" && !c.Bool("no-color")
+							}
+							printer := &logs.EventPrinter{
+								W:         os.Stdout,
+								Color:     color,
+								ShowGroup: len(logGroups) > 1,
+							}
+
+							opts := logs.TailOptions{
+								StartTime:           time.Now().Add(-c.Duration("since")),
+								Follow:              c.Bool("follow"),
+								Poll:                c.Bool("poll"),
+								FilterPattern:       c.String("filter-pattern"),
+								LogStreamNames:      c.StringSlice("log-stream-name"),
+								LogStreamNamePrefix: c.String("log-stream-name-prefix"),
+							}
+							return logs.Tail(ctx, logGroups, opts, cwlc, printer.Print)
+						},
+					},
+					{
+						Name:      "grep",
+						Usage:     "filter JSON log events on fields, e.g. 'level=ERROR and latency_ms>500', from log groups or exported files",
+						ArgsUsage: "[expression]",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "search all log groups starting with the prefix",
+							},
+							&cli.StringSliceFlag{
+								Name:  "input-file",
+								Usage: "file exported by write-to-file or get-log-events, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "start time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "1h",
+							},
+							&cli.StringFlag{
+								Name:  "end",
+								Usage: "end time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "now",
+							},
+							&cli.StringFlag{
+								Name:  "filter-pattern",
+								Usage: "CloudWatch Logs filter pattern applied before the expression, reduces the events read",
+							},
+							&cli.StringSliceFlag{
+								Name:  "fields",
+								Usage: "fields to print as columns, e.g. @timestamp,level,msg",
+							},
+							&cli.StringFlag{
+								Name:    "count-by",
+								Aliases: []string{"group-by"},
+								Usage:   "print the number of matching events per value of the field",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table', 'csv' or 'jsonl', defaults to table with fields and jsonl without",
+							},
+							&cli.IntFlag{
+								Name:  "limit",
+								Usage: "stop after the number of matching events, 0 is no limit",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							expression, err := logs.ParseExpression(strings.Join(c.Args().Slice(), " "))
+							if err != nil {
+								return err
+							}
+							names := c.StringSlice("log-group-name")
+							prefix := c.String("log-group-name-prefix")
+							inputFiles := c.StringSlice("input-file")
+							if len(names) == 0 && prefix == "" && len(inputFiles) == 0 {
+								return fmt.Errorf("log-group-name, log-group-name-prefix or input-file is required")
+							}
+
+							fields := c.StringSlice("fields")
+							output := c.String("output")
+							if output == "" {
+								output = "jsonl"
+								if len(fields) > 0 || c.String("count-by") != "" {
+									output = "table"
+								}
+							}
+							if (output == "table" || output == "csv") && len(fields) == 0 && c.String("count-by") == "" {
+								return fmt.Errorf("fields are required for %s output", output)
+							}
+
+							var counter *logs.Counter
+							var writer *logs.RecordWriter
+							if field := c.String("count-by"); field != "" {
+								counter = &logs.Counter{Field: field}
+							} else {
+								writer, err = logs.NewRecordWriter(os.Stdout, output, fields)
+								if err != nil {
+									return err
+								}
+							}
+
+							errLimit := fmt.Errorf("limit reached")
+							matched := 0
+							match := func(r *logs.Record) error {
+								if !expression.Match(r) {
+									return nil
+								}
+								matched++
+								if counter != nil {
+									counter.Add(r)
+								} else if err := writer.Write(r); err != nil {
+									return err
+								}
+								if c.Int("limit") > 0 && matched >= c.Int("limit") {
+									return errLimit
+								}
+								return nil
+							}
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							err = func() error {
+								for _, fileName := range inputFiles {
+									f, err := os.Open(fileName)
+									if err != nil {
+										return err
+									}
+									err = logs.ReadRecords(f, logs.FileFormat(fileName), match)
+									f.Close()
+									if err != nil {
+										return err
+									}
+								}
+								if len(names) == 0 && prefix == "" {
+									return nil
+								}
+
+								now := time.Now()
+								startTime, err := logs.ParseTime(c.String("start"), now)
+								if err != nil {
+									return err
+								}
+								endTime, err := logs.ParseTime(c.String("end"), now)
+								if err != nil {
+									return err
+								}
+								sess := session.NewSessionWithSharedProfile(profile)
+								cwlc := cloudwatchlogs.New(sess)
+								logGroups, err := logs.ResolveLogGroups(ctx, names, prefix, cwlc)
+								if err != nil {
+									return err
+								}
+								for _, lg := range logGroups {
+									if err := logs.ReadLogGroupRecords(ctx, *lg.LogGroupName, c.String("filter-pattern"), startTime, endTime, cwlc, match); err != nil {
+										return err
+									}
+								}
+								return nil
+							}()
+							if err != nil && err != errLimit && ctx.Err() == nil {
+								return err
+							}
+
+							if counter != nil {
+								return counter.WriteCounts(os.Stdout, output)
+							}
+							return writer.Flush()
+						},
+					},
+					{
+						Name:  "patterns",
+						Usage: "group log events into templates with variable parts masked, optionally compared with an earlier window",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "log-group-name",
+								Aliases:  []string{"l"},
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "start time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "1h",
+							},
+							&cli.StringFlag{
+								Name:  "end",
+								Usage: "end time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "now",
+							},
+							&cli.StringFlag{
+								Name:  "compare-start",
+								Usage: "start of the baseline window, e.g. before a deploy, enables the comparison",
+							},
+							&cli.StringFlag{
+								Name:  "compare-end",
+								Usage: "end of the baseline window, defaults to start",
+							},
+							&cli.BoolFlag{
+								Name:  "new-only",
+								Usage: "only print patterns not in the baseline window",
+							},
+							&cli.IntFlag{
+								Name:  "top",
+								Usage: "print only the number of most frequent patterns, 0 is all",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.IntFlag{
+								Name:  "concurrency",
+								Usage: "number of log streams read at the same time",
+								Value: logs.DefaultConcurrency,
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							logGroupName := c.String("log-group-name")
+							if c.Bool("new-only") && c.String("compare-start") == "" {
+								return fmt.Errorf("compare-start is required with new-only")
+							}
+
+							now := time.Now()
+							startTime, err := logs.ParseTime(c.String("start"), now)
+							if err != nil {
+								return err
+							}
+							endTime, err := logs.ParseTime(c.String("end"), now)
+							if err != nil {
+								return err
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							read := func(start, end time.Time) ([]logs.Pattern, error) {
+								patterns := &logs.Patterns{}
+								err := logs.ReadLogEvents(ctx, logGroupName, start, end, c.Int("concurrency"), cwlc, func(e *logs.LogEvent) error {
+									patterns.Add(aws.Int64Value(e.Timestamp), aws.StringValue(e.Message))
+									return nil
+								})
+								if err != nil {
+									return nil, err
+								}
+								return patterns.List(), nil
+							}
+
+							current, err := read(startTime, endTime)
+							if err != nil {
+								return err
+							}
+
+							if c.String("compare-start") == "" {
+								if top := c.Int("top"); top > 0 && len(current) > top {
+									current = current[:top]
+								}
+								return logs.WritePatterns(os.Stdout, current, c.String("output"))
+							}
+
+							compareStart, err := logs.ParseTime(c.String("compare-start"), now)
+							if err != nil {
+								return err
+							}
+							compareEnd := startTime
+							if c.String("compare-end") != "" {
+								if compareEnd, err = logs.ParseTime(c.String("compare-end"), now); err != nil {
+									return err
+								}
+							}
+							baseline, err := read(compareStart, compareEnd)
+							if err != nil {
+								return err
+							}
+
+							changes := logs.ComparePatterns(baseline, current, compareEnd.Sub(compareStart), endTime.Sub(startTime))
+							if c.Bool("new-only") {
+								var added []logs.PatternChange
+								for _, change := range changes {
+									if change.Status == logs.PatternNew {
+										added = append(added, change)
+									}
+								}
+								changes = added
+							}
+							if top := c.Int("top"); top > 0 && len(changes) > top {
+								changes = changes[:top]
+							}
+							return logs.WritePatternChanges(os.Stdout, changes, c.String("output"))
+						},
+					},
+					{
+						Name:  "query",
+						Usage: "run a Logs Insights query and print the results",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "query-string",
+								Usage: "Logs Insights query",
+							},
+							&cli.StringFlag{
+								Name:  "query-file",
+								Usage: "file with the Logs Insights query",
+							},
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "run the saved query with the name, other flags override its values",
+							},
+							&cli.StringFlag{
+								Name:  "save",
+								Usage: "save the query under the name instead of running it",
+							},
+							&cli.StringFlag{
+								Name:    "queries-file",
+								Usage:   "file with saved queries",
+								Value:   ".flow-queries.json",
+								EnvVars: []string{"FLOW_QUERIES_FILE"},
+							},
+							&cli.BoolFlag{
+								Name:  "list",
+								Usage: "list saved queries",
+							},
+							&cli.StringSliceFlag{
+								Name:  "log-group-name",
+								Usage: "log group name, can be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "log-group-name-prefix",
+								Usage: "query all log groups starting with the prefix",
+							},
+							&cli.StringFlag{
+								Name:  "start",
+								Usage: "start time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "1h",
+							},
+							&cli.StringFlag{
+								Name:  "end",
+								Usage: "end time, RFC3339 or relative like 15m, 2h or 7d",
+								Value: "now",
+							},
+							&cli.Int64Flag{
+								Name:  "limit",
+								Usage: "maximum number of rows, at most 10000",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table', 'json', 'jsonl' or 'csv'",
+								Value: "table",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							queriesFile := c.String("queries-file")
+
+							if c.Bool("list") {
+								queries, err := logs.LoadSavedQueries(queriesFile)
+								if err != nil {
+									return err
+								}
+								w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+								fmt.Fprintln(w, "NAME\tDESCRIPTION\tQUERY")
+								var names []string
+								for name := range queries {
+									names = append(names, name)
+								}
+								sort.Strings(names)
+								for _, name := range names {
+									fmt.Fprintf(w, "%s\t%s\t%s\n", name, queries[name].Description, strings.ReplaceAll(queries[name].QueryString, "\n", " "))
+								}
+								return w.Flush()
+							}
+
+							var q logs.SavedQuery
+							if name := c.String("name"); name != "" {
+								queries, err := logs.LoadSavedQueries(queriesFile)
+								if err != nil {
+									return err
+								}
+								saved, ok := queries[name]
+								if !ok {
+									return fmt.Errorf("saved query %s not found in %s", name, queriesFile)
+								}
+								q = saved
+							}
+							if c.IsSet("query-string") {
+								q.QueryString = c.String("query-string")
+							}
+							if c.IsSet("query-file") {
+								b, err := os.ReadFile(c.String("query-file"))
+								if err != nil {
+									return errors.Wrap(err, "failed to read query file")
+								}
+								q.QueryString = string(b)
+							}
+							if c.IsSet("log-group-name") {
+								q.LogGroupNames = c.StringSlice("log-group-name")
+							}
+							if c.IsSet("log-group-name-prefix") {
+								q.LogGroupNamePrefix = c.String("log-group-name-prefix")
+							}
+							if c.IsSet("start") || q.Start == "" {
+								q.Start = c.String("start")
+							}
+							if c.IsSet("end") || q.End == "" {
+								q.End = c.String("end")
+							}
+							if strings.TrimSpace(q.QueryString) == "" {
+								return fmt.Errorf("query-string, query-file or name is required")
+							}
+
+							if name := c.String("save"); name != "" {
+								if err := logs.SaveQuery(queriesFile, name, q); err != nil {
+									return err
+								}
+								log.Printf("saved query %s to %s", name, queriesFile)
+								return nil
+							}
+
+							if len(q.LogGroupNames) == 0 && q.LogGroupNamePrefix == "" {
+								return fmt.Errorf("log-group-name or log-group-name-prefix is required")
+							}
+							now := time.Now()
+							startTime, err := logs.ParseTime(q.Start, now)
+							if err != nil {
+								return err
+							}
+							endTime, err := logs.ParseTime(q.End, now)
+							if err != nil {
+								return err
+							}
+							if c.Int64("limit") > logs.QueryRowLimit {
+								return fmt.Errorf("limit can not be greater than %d", logs.QueryRowLimit)
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							cwlc := cloudwatchlogs.New(sess)
+
+							ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+							defer stop()
+
+							logGroups, err := logs.ResolveLogGroups(ctx, q.LogGroupNames, q.LogGroupNamePrefix, cwlc)
+							if err != nil {
+								return err
+							}
+							var logGroupNames []string
+							for _, lg := range logGroups {
+								logGroupNames = append(logGroupNames, *lg.LogGroupName)
+							}
+
+							result, err := logs.RunQuery(ctx, logGroupNames, logs.QueryOptions{
+								QueryString: q.QueryString,
+								StartTime:   startTime,
+								EndTime:     endTime,
+								Limit:       c.Int64("limit"),
+							}, cwlc)
+							if err != nil {
+								return err
+							}
+
+							if err := logs.WriteQueryResult(os.Stdout, result, c.String("output")); err != nil {
+								return err
+							}
+
+							log.Printf("%d rows, %.0f records matched, %.0f records scanned, %.2f MB scanned", len(result.Rows), result.RecordsMatched, result.RecordsScanned, result.BytesScanned/1e+6)
+							if result.LimitReached() {
+								log.Printf("warning: the result has %d rows which is the Logs Insights limit, narrow the time range or the query to see all results", logs.QueryRowLimit)
+							}
+
+							return nil
+						},
+					},
+				},
+			}
+		}(),
+		func() *cli.Command {
+			return &cli.Command{
+				Name:  "ssm",
+				Usage: "AWS SSM",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "export",
+						Usage: "exports ssm parameters and their values to json",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "output-file-name",
+								Value: "ssm.json",
+							},
+							&cli.StringFlag{
+								Name:  "path",
+								Usage: "export only parameters under the path, e.g. /staging/",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						}, encryptionFlags()...),
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							outFileName := c.String("output-file-name")
+							recipients, err := exportRecipients(c)
+							if err != nil {
+								return err
+							}
+							sess := session.NewSessionWithSharedProfile(profile)
+
+							ssmc := ssm.New(sess)
+
+							parameters, err := flowssm.Export(c.Context, c.String("path"), c.Bool("redact"), ssmc)
+							if err != nil {
+								return err
+							}
+
+							if err := writeExport(outFileName, recipients, parameters); err != nil {
+								return err
+							}
+							log.Printf("wrote %d parameters to %s", len(parameters), outFileName)
+							return nil
+						},
+					},
+					{
+						Name:  "import",
+						Usage: "creates or updates parameters from a file written by export",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "input-file-name",
+								Value: "ssm.json",
+							},
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "update existing parameters with a different value",
+							},
+							&cli.StringFlag{
+								Name:  "path-prefix",
+								Usage: "import only parameters with the prefix and replace it, e.g. /staging/=/dev-alice/",
+							},
+							&cli.StringFlag{
+								Name:  "kms-key-id",
+								Usage: "KMS key for SecureString parameters instead of the exported one",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the changes without applying them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						}, decryptionFlags()...),
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							opts := flowssm.ImportOptions{
+								Overwrite: c.Bool("overwrite"),
+								KeyId:     c.String("kms-key-id"),
+							}
+							if prefix := c.String("path-prefix"); prefix != "" {
+								from, to, ok := strings.Cut(prefix, "=")
+								if !ok || from == "" || to == "" {
+									return fmt.Errorf("invalid path-prefix %s, expected from=to", prefix)
+								}
+								opts.FromPrefix, opts.ToPrefix = from, to
+							}
+
+							f, err := openExport(c, c.String("input-file-name"))
+							if err != nil {
+								return err
+							}
+							defer f.Close()
+							parameters, err := flowssm.ReadExport(f)
+							if err != nil {
+								return err
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							ssmc := ssm.New(sess)
+
+							changes, err := flowssm.PlanImport(c.Context, parameters, opts, ssmc)
+							if err != nil {
+								return err
+							}
+							if len(changes) == 0 {
+								log.Printf("no parameters to import")
+								return nil
+							}
+							if err := flowssm.WriteImportTable(os.Stdout, changes); err != nil {
+								return err
+							}
+							if c.Bool("dry-run") {
+								return nil
+							}
+							if err := flowssm.Import(c.Context, changes, opts, ssmc); err != nil {
+								return err
+							}
+							exists := 0
+							for _, change := range changes {
+								if change.Action == flowssm.ImportExists {
+									exists++
+								}
+							}
+							if exists > 0 {
+								log.Printf("%d existing parameters were not changed, use --overwrite to update them", exists)
+							}
+							return nil
+						},
+					},
+					{
+						Name:  "get",
+						Usage: "get decrypted parameters under a path",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "path",
+								Usage:    "parameter path, e.g. /app/",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:    "recursive",
+								Aliases: []string{"r"},
+								Usage:   "include parameters in sub paths",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "'table' or 'json'",
+								Value: "table",
+							},
+							&cli.BoolFlag{
+								Name:  "show-secrets",
+								Usage: "print SecureString values instead of masking them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							ssmc := ssm.New(session.NewSessionWithSharedProfile(profile))
+							parameters, err := flowssm.ParametersByPath(c.Context, c.String("path"), c.Bool("recursive"), ssmc)
+							if err != nil {
+								return err
+							}
+							return flowssm.WriteParameters(os.Stdout, parameters, c.String("output"), c.Bool("show-secrets"))
+						},
+					},
+					{
+						Name:  "tree",
+						Usage: "print the parameters under a path as a tree",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "path",
+								Usage: "parameter path, e.g. /app/",
+								Value: "/",
+							},
+							&cli.BoolFlag{
+								Name:  "values",
+								Usage: "print the values of the parameters",
+							},
+							&cli.BoolFlag{
+								Name:  "show-secrets",
+								Usage: "print SecureString values instead of masking them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+							ssmc := ssm.New(session.NewSessionWithSharedProfile(profile))
+							parameters, err := flowssm.ParametersByPath(c.Context, c.String("path"), true, ssmc)
+							if err != nil {
+								return err
+							}
+							return flowssm.WriteTree(os.Stdout, c.String("path"), parameters, c.Bool("values"), c.Bool("show-secrets"))
+						},
+					},
+					{
+						Name:  "diff",
+						Usage: "compare the parameters under two paths, optionally in two accounts, exits with 1 when they differ",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "path-a",
+								Usage:    "parameter path, e.g. /staging/app",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "path-b",
+								Usage:    "parameter path, e.g. /dev/app",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "profile-a",
+								Usage: "profile for path-a, defaults to profile",
+							},
+							&cli.StringFlag{
+								Name:  "profile-b",
+								Usage: "profile for path-b, defaults to profile",
+							},
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "also print parameters that are the same",
+							},
+							&cli.BoolFlag{
+								Name:  "show-secrets",
+								Usage: "print SecureString values instead of masking them",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							profileA, profileB := c.String("profile-a"), c.String("profile-b")
+							if profileA == "" {
+								profileA = c.String("profile")
+							}
+							if profileB == "" {
+								profileB = c.String("profile")
+							}
+							a, err := flowssm.ParametersByPath(c.Context, c.String("path-a"), true, ssm.New(session.NewSessionWithSharedProfile(profileA)))
+							if err != nil {
+								return err
+							}
+							b, err := flowssm.ParametersByPath(c.Context, c.String("path-b"), true, ssm.New(session.NewSessionWithSharedProfile(profileB)))
+							if err != nil {
+								return err
+							}
+
+							diffs := flowssm.DiffParameters(c.String("path-a"), a, c.String("path-b"), b)
+							if err := flowssm.WriteParameterDiff(os.Stdout, diffs, c.Bool("all"), c.Bool("show-secrets")); err != nil {
+								return err
+							}
+							counts := map[string]int{}
+							for _, d := range diffs {
+								counts[d.Status]++
+							}
+							if counts[flowssm.DiffSame] < len(diffs) {
+								return cli.Exit(fmt.Sprintf("%d missing, %d extra and %d differing parameters", counts[flowssm.DiffMissing], counts[flowssm.DiffExtra], counts[flowssm.DiffDiffer]), 1)
+							}
+							log.Printf("%d parameters are the same", len(diffs))
+							return nil
+						},
+					},
+				},
+			}
+		}(),
+		func() *cli.Command {
+			return &cli.Command{
+				Name:      "exec",
+				Usage:     "run a command with SSM parameters and Secrets Manager values as environment variables",
+				ArgsUsage: "-- <command> [args...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "ssm-path",
+						Usage: "parameters under the path, e.g. /app/dev/ sets DB_HOST from /app/dev/db/host, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "secret",
+						Usage: "secret id, keys of JSON secrets are separate variables, can be repeated",
+					},
+					&cli.BoolFlag{
+						Name:  "dotenv",
+						Usage: "print the variables in .env format instead of running a command",
+					},
+					&cli.StringFlag{
+						Name:  "profile",
+						Value: "",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					profile := c.String("profile")
+					args := c.Args().Slice()
+					if len(args) == 0 && !c.Bool("dotenv") {
+						return fmt.Errorf("command is required, e.g. flow exec --ssm-path /app/dev/ -- npm start")
+					}
+					if len(c.StringSlice("ssm-path")) == 0 && len(c.StringSlice("secret")) == 0 {
+						return fmt.Errorf("ssm-path or secret is required")
+					}
+
+					sess := session.NewSessionWithSharedProfile(profile)
+					vars := map[string]string{}
+					add := func(source string, m map[string]string) {
+						for k, v := range m {
+							if _, ok := vars[k]; ok {
+								log.Printf("%s from %s overrides an earlier value", k, source)
+							}
+							vars[k] = v
+						}
+					}
+					ssmc := ssm.New(sess)
+					for _, path := range c.StringSlice("ssm-path") {
+						parameters, err := flowssm.ParametersByPath(c.Context, path, true, ssmc)
+						if err != nil {
+							return err
+						}
+						values := map[string]string{}
+						for _, p := range parameters {
+							values[aws.StringValue(p.Name)] = aws.StringValue(p.Value)
+						}
+						pvars, err := flowenv.FromParameters(path, values)
+						if err != nil {
+							return err
+						}
+						add(path, pvars)
+					}
+					smc := secretsmanager.New(sess)
+					for _, secretId := range c.StringSlice("secret") {
+						value, err := flowsecretsmanager.GetSecretString(c.Context, secretId, smc)
+						if err != nil {
+							return err
+						}
+						svars, err := flowenv.FromSecret(secretId, value)
+						if err != nil {
+							return err
+						}
+						add(secretId, svars)
+					}
+
+					if c.Bool("dotenv") {
+						return flowenv.WriteDotenv(os.Stdout, vars)
+					}
+
+					cmd := exec.Command(args[0], args[1:]...)
+					cmd.Env = flowenv.Environ(os.Environ(), vars)
+					cmd.Stdin = os.Stdin
+					cmd.Stdout = os.Stdout
+					cmd.Stderr = os.Stderr
+					// the command decides how to handle interrupts, it is in the same process group and
+					// gets them from the terminal itself, so only SIGTERM is forwarded. Signals are
+					// caught before the start so none arriving in between ends flow without the command.
+					signals := make(chan os.Signal, 1)
+					signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+					defer signal.Stop(signals)
+					if err := cmd.Start(); err != nil {
+						return err
+					}
+					go func() {
+						for s := range signals {
+							if s == syscall.SIGTERM {
+								_ = cmd.Process.Signal(s)
+							}
+						}
+					}()
 
-							b, err := json.Marshal(parameters)
-							if err != nil {
-								return err
-							}
-							err = os.WriteFile(outFileName, b, 0644)
-							fmt.Printf("wrote to %v", outFileName)
-							return err
-						},
-					},
+					err := cmd.Wait()
+					if exitErr, ok := err.(*exec.ExitError); ok {
+						// like a shell, a command killed by a signal exits with 128 + the signal number
+						if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+							return cli.Exit("", 128+int(status.Signal()))
+						}
+						return cli.Exit("", exitErr.ExitCode())
+					}
+					return err
 				},
 			}
 		}(),
@@ -1826,11 +4677,27 @@ func main() {
 				Subcommands: []*cli.Command{
 					{
 						Name:        "get-secret-value",
-						Description: "Gets secret value",
+						Description: "Gets secret value, binary values like the RSA keys of create-secrets are decoded",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name: "secret-id",
 							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "value, json or env",
+								Value: "value",
+							},
+							&cli.StringFlag{
+								Name:  "json-key",
+								Usage: "key of a JSON secret to print",
+							},
+							&cli.StringFlag{
+								Name:  "version-stage",
+								Usage: "e.g. AWSPREVIOUS, defaults to AWSCURRENT",
+							},
+							&cli.StringFlag{
+								Name: "version-id",
+							},
 							&cli.StringFlag{
 								Name:  "profile",
 								Value: "",
@@ -1841,29 +4708,26 @@ func main() {
 							profile := c.String("profile")
 
 							if secretId == "" {
-								return fmt.Errorf("secred-id is required")
+								return fmt.Errorf("secret-id is required")
+							}
+							if c.String("version-stage") != "" && c.String("version-id") != "" {
+								return fmt.Errorf("use version-stage or version-id, not both")
 							}
 
 							sess := session.NewSessionWithSharedProfile(profile)
-							ssmc := secretsmanager.New(sess)
+							smc := secretsmanager.New(sess)
 
-							getSecretValueInput := secretsmanager.GetSecretValueInput{
-								SecretId: aws.String(secretId),
-							}
-							getSecretValueOutput, err := ssmc.GetSecretValue(&getSecretValueInput)
+							v, err := flowsecretsmanager.GetSecretValue(c.Context, secretId, c.String("version-stage"), c.String("version-id"), smc)
 							if err != nil {
-								panic(err)
+								return err
 							}
-
-							fmt.Printf("%v", getSecretValueOutput)
-
-							return nil
+							return flowsecretsmanager.WriteSecretValue(os.Stdout, v, c.String("output"), c.String("json-key"))
 						},
 					},
 					{
 						Name:  "export",
 						Usage: "exports secrets and their values to json",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "output-file-name",
 								Value: "secretsmanager.json",
@@ -1872,48 +4736,32 @@ func main() {
 								Name:  "profile",
 								Value: "",
 							},
-						},
+						}, encryptionFlags()...),
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
 							outFileName := c.String("output-file-name")
+							recipients, err := exportRecipients(c)
+							if err != nil {
+								return err
+							}
 
 							sess := session.NewSessionWithSharedProfile(profile)
 
-							ssmc := secretsmanager.New(sess)
-
-							listSecretsInput := secretsmanager.ListSecretsInput{}
-							var entries []*SecretOutput
-							err := ssmc.ListSecretsPages(&listSecretsInput, func(output *secretsmanager.ListSecretsOutput, lastPage bool) bool {
-								for _, elem := range output.SecretList {
-									getSecretValueInput := secretsmanager.GetSecretValueInput{
-										SecretId: elem.ARN,
-									}
-									getSecretValueOutput, err := ssmc.GetSecretValue(&getSecretValueInput)
-									if err != nil {
-										panic(err)
-									}
-
-									entries = append(entries, &SecretOutput{
-										Entry: elem,
-										Value: getSecretValueOutput,
-									})
-								}
-								return lastPage == false
-							})
+							smc := secretsmanager.New(sess)
 
+							secrets, err := flowsecretsmanager.Export(c.Context, c.Bool("redact"), smc)
 							if err != nil {
-								panic(err)
+								return err
 							}
-
-							if entries == nil {
-								fmt.Println("no secrets found")
+							if len(secrets) == 0 {
+								log.Printf("no secrets found")
 								return nil
 							}
 
-							b, _ := json.Marshal(entries)
-							_ = os.WriteFile(outFileName, b, 0644)
-
-							fmt.Printf("exported to %s", outFileName)
+							if err := writeExport(outFileName, recipients, secrets); err != nil {
+								return err
+							}
+							log.Printf("wrote %d secrets to %s", len(secrets), outFileName)
 							return nil
 						},
 					},
@@ -1932,6 +4780,7 @@ func main() {
 
 							ssmc := secretsmanager.New(sess)
 
+							var deleteErr error
 							listSecretsInput := secretsmanager.ListSecretsInput{}
 							err := ssmc.ListSecretsPages(&listSecretsInput, func(output *secretsmanager.ListSecretsOutput, lastPage bool) bool {
 								for _, elem := range output.SecretList {
@@ -1941,24 +4790,24 @@ func main() {
 									}
 									_, err := ssmc.DeleteSecret(&deleteSecretInput)
 									if err != nil {
-										panic(err)
+										deleteErr = fmt.Errorf("unable to delete secret %s: %v", aws.StringValue(elem.Name), err)
+										return false
 									}
 									fmt.Printf("deleted: %v\n", &elem.Name)
 								}
 								return lastPage == false
 							})
-
 							if err != nil {
-								panic(err)
+								return err
 							}
 
-							return nil
+							return deleteErr
 						},
 					},
 					{
 						Name:  "restore-all",
 						Usage: "resotres all values from input file",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name: "input-file-name",
 							},
@@ -1966,7 +4815,7 @@ func main() {
 								Name:  "profile",
 								Value: "",
 							},
-						},
+						}, decryptionFlags()...),
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
 							sess := session.NewSessionWithSharedProfile(profile)
@@ -1974,37 +4823,113 @@ func main() {
 							if inFileName == "" {
 								return fmt.Errorf("input-file-name not found")
 							}
-							jsonFile, err := os.Open(inFileName)
+							jsonFile, err := openExport(c, inFileName)
 							if err != nil {
-								return fmt.Errorf("error when opening %s", inFileName)
+								return err
 							}
 							defer jsonFile.Close()
 
 							ssmc := secretsmanager.New(sess)
 
-							var secrets []*Secret
+							// secrets of the input format have a Name, those of export an Entry
+							var secrets []struct {
+								Name  string
+								Entry *secretsmanager.SecretListEntry
+							}
 							byteValue, _ := io.ReadAll(jsonFile)
 							if err := json.Unmarshal(byteValue, &secrets); err != nil {
 								return err
 							}
 
 							for _, secret := range secrets {
+								name := secret.Name
+								if secret.Entry != nil {
+									name = aws.StringValue(secret.Entry.Name)
+								}
 								restoreInput := secretsmanager.RestoreSecretInput{
-									SecretId: &secret.Name,
+									SecretId: aws.String(name),
 								}
 								_, err := ssmc.RestoreSecret(&restoreInput)
 								if err != nil {
-									panic(err)
+									return fmt.Errorf("unable to restore secret %s: %v", name, err)
+								}
+								fmt.Printf("restored: %s\n", name)
+							}
+							return nil
+						},
+					},
+					{
+						Name:  "apply",
+						Usage: "creates, restores and updates secrets from a file, or a file of export, so they match it",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:     "input-file-name",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "print the plan without applying it",
+							},
+							&cli.BoolFlag{
+								Name:  "yes",
+								Usage: "apply without confirmation",
+							},
+							&cli.StringFlag{
+								Name:  "profile",
+								Value: "",
+							},
+						}, decryptionFlags()...),
+						Action: func(c *cli.Context) error {
+							profile := c.String("profile")
+
+							f, err := openExport(c, c.String("input-file-name"))
+							if err != nil {
+								return err
+							}
+							defer f.Close()
+							secrets, err := flowsecretsmanager.ReadSecrets(f)
+							if err != nil {
+								return err
+							}
+
+							sess := session.NewSessionWithSharedProfile(profile)
+							smc := secretsmanager.New(sess)
+
+							changes, err := flowsecretsmanager.PlanApply(c.Context, secrets, smc)
+							if err != nil {
+								return err
+							}
+							if err := flowsecretsmanager.WriteApplyTable(os.Stdout, changes); err != nil {
+								return err
+							}
+							pending := 0
+							for _, change := range changes {
+								if change.Action != flowsecretsmanager.ApplyNoop {
+									pending++
 								}
-								fmt.Printf("restored: %s\n", secret.Name)
 							}
+							if pending == 0 {
+								log.Printf("secrets are up to date")
+								return nil
+							}
+							if c.Bool("dry-run") {
+								return nil
+							}
+							if !c.Bool("yes") && !confirm(fmt.Sprintf("apply %d changes?", pending)) {
+								log.Printf("not confirmed, nothing was applied")
+								return nil
+							}
+							if err := flowsecretsmanager.Apply(c.Context, changes, smc); err != nil {
+								return err
+							}
+							log.Printf("applied %d changes", pending)
 							return nil
 						},
 					},
 					{
 						Name:  "create-secrets",
-						Usage: "createsSecrets from file",
-						Flags: []cli.Flag{
+						Usage: "createsSecrets from file, fails for existing secrets, see apply",
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name: "input-file-name",
 							},
@@ -2012,7 +4937,7 @@ func main() {
 								Name:  "profile",
 								Value: "",
 							},
-						},
+						}, decryptionFlags()...),
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
 							inFileName := c.String("input-file-name")
@@ -2022,13 +4947,13 @@ func main() {
 
 							sess := session.NewSessionWithSharedProfile(profile)
 
-							jsonFile, err := os.Open(inFileName)
+							jsonFile, err := openExport(c, inFileName)
 							if err != nil {
-								return fmt.Errorf("error when opening %s", inFileName)
+								return err
 							}
 							defer jsonFile.Close()
 
-							var secrets []*Secret
+							var secrets []*flowsecretsmanager.Secret
 							byteValue, _ := io.ReadAll(jsonFile)
 							if err := json.Unmarshal(byteValue, &secrets); err != nil {
 								return err
@@ -2071,8 +4996,8 @@ func main() {
 					},
 					{
 						Name:  "update",
-						Usage: "update secrets from file",
-						Flags: []cli.Flag{
+						Usage: "update secrets from file, fails for missing secrets, see apply",
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name: "input-file-name",
 							},
@@ -2080,7 +5005,7 @@ func main() {
 								Name:  "profile",
 								Value: "",
 							},
-						},
+						}, decryptionFlags()...),
 						Action: func(c *cli.Context) error {
 							profile := c.String("profile")
 							inFileName := c.String("input-file-name")
@@ -2090,13 +5015,13 @@ func main() {
 
 							sess := session.NewSessionWithSharedProfile(profile)
 
-							jsonFile, err := os.Open(inFileName)
+							jsonFile, err := openExport(c, inFileName)
 							if err != nil {
-								return fmt.Errorf("error when opening %s", inFileName)
+								return err
 							}
 							defer jsonFile.Close()
 
-							var secrets []*Secret
+							var secrets []*flowsecretsmanager.Secret
 							byteValue, _ := io.ReadAll(jsonFile)
 							if err := json.Unmarshal(byteValue, &secrets); err != nil {
 								return err
@@ -3781,7 +6706,7 @@ func main() {
 								return errors.Wrap(err, "generate rsa key pair")
 							}
 
-							privFile, err := os.OpenFile("private_key.pem", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+							privFile, err := os.OpenFile("private_key.pem", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
 							if err != nil {
 								if os.IsExist(err) {
 									return errors.New("private key file already exists, please delete it first")
@@ -3943,6 +6868,408 @@ func chunkFile(filePath string, chunkSize int) (string, [][]string, error) {
 	return headerArr, chunks, nil
 }
 
+// confirm asks on stdin for confirmation and returns true when answered with y or yes.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// parseSize parses sizes like 500KB, 100MB or 1GB, a plain number is bytes and an empty string is 0.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		bytes  int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.bytes
+			s = strings.TrimSuffix(s, unit.suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// parseTags parses key=value pairs.
+func parseTags(tags []string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("tag %s is not in key=value format", tag)
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+// alarmFlags are the flags shared by the cloudwatch commands that change alarms.
+func alarmFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "name",
+			Usage: "alarm name, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "prefix",
+			Usage: "all alarms with names starting with the prefix",
+		},
+		&cli.StringFlag{
+			Name:  "profile",
+			Value: "",
+		},
+	}
+}
+
+// selectAlarms returns the alarms selected with alarmFlags.
+func selectAlarms(c *cli.Context, cwc *cloudwatch.CloudWatch) ([]flowcloudwatch.Alarm, error) {
+	names := c.StringSlice("name")
+	prefix := c.String("prefix")
+	if len(names) == 0 && prefix == "" {
+		return nil, fmt.Errorf("name or prefix is required")
+	}
+	if len(names) > 0 && prefix != "" {
+		return nil, fmt.Errorf("name and prefix can not be used together")
+	}
+	alarms, err := flowcloudwatch.Alarms(c.Context, flowcloudwatch.AlarmFilter{Names: names, Prefix: prefix}, cwc)
+	if err != nil {
+		return nil, err
+	}
+	if len(alarms) == 0 {
+		return nil, fmt.Errorf("no alarms found")
+	}
+	return alarms, nil
+}
+
+// dashboardVars returns the template variables of dashboards: Region of the session, Env and --var
+// variables.
+func dashboardVars(c *cli.Context, sess *asession.Session) (map[string]string, error) {
+	vars, err := parseTags(c.StringSlice("var"))
+	if err != nil {
+		return nil, err
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["Region"] = aws.StringValue(sess.Config.Region)
+	if env := c.String("env"); env != "" {
+		vars["Env"] = env
+	}
+	return vars, nil
+}
+
+type renderedDashboard struct {
+	name string
+	file string
+	body string
+}
+
+// renderDashboards renders the dashboard files of the --file flag with dashboardVars.
+func renderDashboards(c *cli.Context, sess *asession.Session) ([]renderedDashboard, error) {
+	vars, err := dashboardVars(c, sess)
+	if err != nil {
+		return nil, err
+	}
+	files, err := flowcloudwatch.DashboardFiles(c.String("file"))
+	if err != nil {
+		return nil, err
+	}
+	if c.String("name") != "" && len(files) > 1 {
+		return nil, fmt.Errorf("name can only be used with a single file")
+	}
+	var dashboards []renderedDashboard
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := c.String("name")
+		if name == "" {
+			name = flowcloudwatch.DashboardName(file)
+		}
+		body, err := flowcloudwatch.RenderDashboard(name, string(b), vars)
+		if err != nil {
+			return nil, err
+		}
+		dashboards = append(dashboards, renderedDashboard{name: name, file: file, body: body})
+	}
+	return dashboards, nil
+}
+
+// encryptionFlags are the flags of exports with secret values, see exportRecipients.
+func encryptionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "recipient",
+			Usage: "encrypt for an age public key, age1..., or a public key file like public_key.pem of crypto genrsa, can be repeated",
+		},
+		&cli.BoolFlag{
+			Name:  "passphrase",
+			Usage: "encrypt with a passphrase, read from FLOW_PASSPHRASE or prompted",
+		},
+		&cli.BoolFlag{
+			Name:  "redact",
+			Usage: "export only metadata without values",
+		},
+		&cli.BoolFlag{
+			Name:  "plaintext",
+			Usage: "write values unencrypted",
+		},
+	}
+}
+
+// decryptionFlags are the flags of commands reading files that may be encrypted, see openExport.
+func decryptionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "identity",
+			Usage: "age identity file, or a private key file like private_key.pem of crypto genrsa, can be repeated",
+		},
+		&cli.BoolFlag{
+			Name:  "passphrase",
+			Usage: "decrypt with a passphrase, read from FLOW_PASSPHRASE or prompted",
+		},
+	}
+}
+
+// readPassphrase returns FLOW_PASSPHRASE or prompts for the passphrase, twice with confirm.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("FLOW_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("unable to read passphrase: %v", err)
+		}
+		return string(b), nil
+	}
+	passphrase, err := read("passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty")
+	}
+	if confirm {
+		again, err := read("confirm passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// exportRecipients returns the recipients of encryptionFlags, none for unencrypted exports which
+// require --redact or --plaintext.
+func exportRecipients(c *cli.Context) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, recipient := range c.StringSlice("recipient") {
+		r, err := flowcrypto.ParseRecipient(recipient)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r...)
+	}
+	if c.Bool("passphrase") {
+		if len(recipients) > 0 {
+			return nil, fmt.Errorf("use recipient or passphrase, not both")
+		}
+		passphrase, err := readPassphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		r, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 && !c.Bool("redact") && !c.Bool("plaintext") {
+		return nil, fmt.Errorf("the export contains secret values, use --recipient or --passphrase to encrypt it, --redact to leave out the values or --plaintext")
+	}
+	return recipients, nil
+}
+
+// writeExport writes v as json to a file only the user can read, encrypted for the recipients
+// of exportRecipients.
+func writeExport(name string, recipients []age.Recipient, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// an existing file keeps its mode
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	w, err := flowcrypto.Encrypt(f, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// openExport opens a file, written by writeExport or by hand, and decrypts it with
+// decryptionFlags when it is encrypted.
+func openExport(c *cli.Context, name string) (io.ReadCloser, error) {
+	var identities []age.Identity
+	for _, file := range c.StringSlice("identity") {
+		ids, err := flowcrypto.ParseIdentities(file)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, ids...)
+	}
+	if c.Bool("passphrase") {
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := flowcrypto.Decrypt(f, identities...)
+	if err == flowcrypto.ErrNoIdentity {
+		f.Close()
+		return nil, fmt.Errorf("%s is encrypted, use --identity or --passphrase", name)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// queueConfigFlags are the flags shared by sqs create and set-attributes.
+func queueConfigFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "dlq-name",
+			Usage: "dead-letter queue name, created when it does not exist",
+		},
+		&cli.Int64Flag{
+			Name:  "max-receive-count",
+			Value: 5,
+			Usage: "number of receives before message is moved to dead-letter queue",
+		},
+		&cli.Int64Flag{
+			Name:  "visibility-timeout",
+			Usage: "visibility timeout in seconds",
+		},
+		&cli.Int64Flag{
+			Name:  "retention",
+			Usage: "message retention period in seconds",
+		},
+		&cli.Int64Flag{
+			Name:  "delay",
+			Usage: "delivery delay in seconds",
+		},
+		&cli.BoolFlag{
+			Name:  "content-based-deduplication",
+			Usage: "enable content based deduplication for fifo queue",
+		},
+		&cli.StringFlag{
+			Name:  "kms-key-id",
+			Usage: "enable SSE-KMS with the key id, alias or arn",
+		},
+		&cli.BoolFlag{
+			Name:  "sse-sqs",
+			Usage: "enable SSE-SQS (sqs managed encryption)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tag",
+			Usage: "key=value",
+		},
+	}
+}
+
+// queueConfig reads queueConfigFlags, flags not given by the user are left unset.
+func queueConfig(c *cli.Context) (flowsqs.QueueConfig, error) {
+	cfg := flowsqs.QueueConfig{
+		DeadLetterQueueName: c.String("dlq-name"),
+		MaxReceiveCount:     c.Int64("max-receive-count"),
+		KmsMasterKeyId:      c.String("kms-key-id"),
+	}
+	if c.IsSet("visibility-timeout") {
+		cfg.VisibilityTimeout = aws.Int64(c.Int64("visibility-timeout"))
+	}
+	if c.IsSet("retention") {
+		cfg.MessageRetentionPeriod = aws.Int64(c.Int64("retention"))
+	}
+	if c.IsSet("delay") {
+		cfg.DelaySeconds = aws.Int64(c.Int64("delay"))
+	}
+	if c.IsSet("content-based-deduplication") {
+		cfg.ContentBasedDeduplication = aws.Bool(c.Bool("content-based-deduplication"))
+	}
+	if c.IsSet("sse-sqs") {
+		cfg.SqsManagedSSE = aws.Bool(c.Bool("sse-sqs"))
+	}
+	if cfg.KmsMasterKeyId != "" && c.Bool("sse-sqs") {
+		return cfg, fmt.Errorf("kms-key-id and sse-sqs can not be used together")
+	}
+	if c.IsSet("max-receive-count") && cfg.DeadLetterQueueName == "" {
+		return cfg, fmt.Errorf("max-receive-count requires dlq-name")
+	}
+
+	tags, err := parseTags(c.StringSlice("tag"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Tags = tags
+
+	return cfg, nil
+}
+
 func newClientset(cluster *eks.Cluster, sess *asession.Session) (*kubernetes.Clientset, error) {
 	gen, err := token.NewGenerator(true, false)
 	if err != nil {
@@ -0,0 +1,280 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// maxAlarmNames is the number of alarm names DeleteAlarms, DisableAlarmActions and
+// EnableAlarmActions accept in one call.
+const maxAlarmNames = 100
+
+// Alarm is a metric or composite alarm.
+type Alarm struct {
+	Name           string    `json:"name"`
+	Arn            string    `json:"arn"`
+	Type           string    `json:"type"`
+	State          string    `json:"state"`
+	StateReason    string    `json:"stateReason"`
+	StateUpdated   time.Time `json:"stateUpdated"`
+	Namespace      string    `json:"namespace,omitempty"`
+	MetricName     string    `json:"metricName,omitempty"`
+	ActionsEnabled bool      `json:"actionsEnabled"`
+}
+
+// AlarmFilter selects alarms, empty fields match all alarms.
+type AlarmFilter struct {
+	// Names can not be used with Prefix.
+	Names  []string
+	Prefix string
+	// State is OK, ALARM or INSUFFICIENT_DATA.
+	State string
+	// Namespace only matches metric alarms.
+	Namespace string
+}
+
+// Alarms returns the metric and composite alarms matching the filter.
+func Alarms(ctx context.Context, filter AlarmFilter, c cloudwatchiface.CloudWatchAPI) ([]Alarm, error) {
+	// describe alarms accepts at most maxAlarmNames names
+	names := batches(filter.Names)
+	if len(names) == 0 {
+		names = [][]string{nil}
+	}
+	var alarms []Alarm
+	for _, batch := range names {
+		a, err := describeAlarms(ctx, filter, batch, c)
+		if err != nil {
+			return nil, err
+		}
+		alarms = append(alarms, a...)
+	}
+	return alarms, nil
+}
+
+func describeAlarms(ctx context.Context, filter AlarmFilter, names []string, c cloudwatchiface.CloudWatchAPI) ([]Alarm, error) {
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmTypes: aws.StringSlice([]string{cloudwatch.AlarmTypeMetricAlarm, cloudwatch.AlarmTypeCompositeAlarm}),
+	}
+	if len(names) > 0 {
+		input.AlarmNames = aws.StringSlice(names)
+	}
+	if filter.Prefix != "" {
+		input.AlarmNamePrefix = aws.String(filter.Prefix)
+	}
+	if filter.State != "" {
+		input.StateValue = aws.String(strings.ToUpper(filter.State))
+	}
+
+	var alarms []Alarm
+	err := c.DescribeAlarmsPagesWithContext(ctx, input, func(output *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		for _, a := range output.MetricAlarms {
+			if filter.Namespace != "" && aws.StringValue(a.Namespace) != filter.Namespace {
+				continue
+			}
+			alarms = append(alarms, Alarm{
+				Name:           aws.StringValue(a.AlarmName),
+				Arn:            aws.StringValue(a.AlarmArn),
+				Type:           cloudwatch.AlarmTypeMetricAlarm,
+				State:          aws.StringValue(a.StateValue),
+				StateReason:    aws.StringValue(a.StateReason),
+				StateUpdated:   aws.TimeValue(a.StateUpdatedTimestamp),
+				Namespace:      aws.StringValue(a.Namespace),
+				MetricName:     aws.StringValue(a.MetricName),
+				ActionsEnabled: aws.BoolValue(a.ActionsEnabled),
+			})
+		}
+		if filter.Namespace != "" {
+			return lastPage == false
+		}
+		for _, a := range output.CompositeAlarms {
+			alarms = append(alarms, Alarm{
+				Name:           aws.StringValue(a.AlarmName),
+				Arn:            aws.StringValue(a.AlarmArn),
+				Type:           cloudwatch.AlarmTypeCompositeAlarm,
+				State:          aws.StringValue(a.StateValue),
+				StateReason:    aws.StringValue(a.StateReason),
+				StateUpdated:   aws.TimeValue(a.StateUpdatedTimestamp),
+				ActionsEnabled: aws.BoolValue(a.ActionsEnabled),
+			})
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe alarms: %v", err)
+	}
+	return alarms, nil
+}
+
+// AlarmNames returns the names of the alarms.
+func AlarmNames(alarms []Alarm) []string {
+	names := make([]string, len(alarms))
+	for i, a := range alarms {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// WriteAlarms writes the alarms as a table or json.
+func WriteAlarms(w io.Writer, alarms []Alarm, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(alarms)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSTATE\tSINCE\tACTIONS\tMETRIC")
+		for _, a := range alarms {
+			actions := "enabled"
+			if !a.ActionsEnabled {
+				actions = "disabled"
+			}
+			metric := a.Type
+			if a.MetricName != "" {
+				metric = a.Namespace + "/" + a.MetricName
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Name, a.State, a.StateUpdated.Format(time.RFC3339), actions, metric)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output %s, use table or json", format)
+	}
+}
+
+// HistoryItem is a state transition of an alarm.
+type HistoryItem struct {
+	AlarmName string    `json:"alarmName"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   string    `json:"summary"`
+}
+
+// History returns the state transitions of an alarm between start and end, newest first. An empty
+// alarm name returns the transitions of all alarms.
+func History(ctx context.Context, alarmName string, start, end time.Time, c cloudwatchiface.CloudWatchAPI) ([]HistoryItem, error) {
+	input := &cloudwatch.DescribeAlarmHistoryInput{
+		HistoryItemType: aws.String(cloudwatch.HistoryItemTypeStateUpdate),
+		StartDate:       aws.Time(start),
+		EndDate:         aws.Time(end),
+		ScanBy:          aws.String(cloudwatch.ScanByTimestampDescending),
+	}
+	if alarmName != "" {
+		input.AlarmName = aws.String(alarmName)
+	}
+	var items []HistoryItem
+	err := c.DescribeAlarmHistoryPagesWithContext(ctx, input, func(output *cloudwatch.DescribeAlarmHistoryOutput, lastPage bool) bool {
+		for _, h := range output.AlarmHistoryItems {
+			items = append(items, HistoryItem{
+				AlarmName: aws.StringValue(h.AlarmName),
+				Timestamp: aws.TimeValue(h.Timestamp),
+				Summary:   aws.StringValue(h.HistorySummary),
+			})
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe alarm history: %v", err)
+	}
+	return items, nil
+}
+
+// WriteHistory writes the history items as a table or json.
+func WriteHistory(w io.Writer, items []HistoryItem, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(items)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIMESTAMP\tALARM\tSUMMARY")
+		for _, h := range items {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", h.Timestamp.Format(time.RFC3339), h.AlarmName, h.Summary)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output %s, use table or json", format)
+	}
+}
+
+// DisableActions disables the actions of the alarms.
+func DisableActions(ctx context.Context, names []string, c cloudwatchiface.CloudWatchAPI) error {
+	for _, batch := range batches(names) {
+		_, err := c.DisableAlarmActionsWithContext(ctx, &cloudwatch.DisableAlarmActionsInput{
+			AlarmNames: aws.StringSlice(batch),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to disable alarm actions: %v", err)
+		}
+	}
+	return nil
+}
+
+// EnableActions enables the actions of the alarms.
+func EnableActions(ctx context.Context, names []string, c cloudwatchiface.CloudWatchAPI) error {
+	for _, batch := range batches(names) {
+		_, err := c.EnableAlarmActionsWithContext(ctx, &cloudwatch.EnableAlarmActionsInput{
+			AlarmNames: aws.StringSlice(batch),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to enable alarm actions: %v", err)
+		}
+	}
+	return nil
+}
+
+// Silence disables the actions of the alarms for the duration and enables them again when the time
+// is up or ctx is cancelled. Alarms with actions already disabled are left as they are, so they are
+// not enabled afterwards. It returns the names of the silenced alarms.
+func Silence(ctx context.Context, alarms []Alarm, d time.Duration, c cloudwatchiface.CloudWatchAPI) ([]string, error) {
+	var names []string
+	for _, a := range alarms {
+		if a.ActionsEnabled {
+			names = append(names, a.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if err := DisableActions(ctx, names, c); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// ctx may be cancelled, the actions must be enabled anyway
+	if err := EnableActions(context.Background(), names, c); err != nil {
+		return names, err
+	}
+	return names, nil
+}
+
+// DeleteAlarms deletes the alarms.
+func DeleteAlarms(ctx context.Context, names []string, c cloudwatchiface.CloudWatchAPI) error {
+	for _, batch := range batches(names) {
+		_, err := c.DeleteAlarmsWithContext(ctx, &cloudwatch.DeleteAlarmsInput{
+			AlarmNames: aws.StringSlice(batch),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to delete alarms: %v", err)
+		}
+	}
+	return nil
+}
+
+func batches(names []string) [][]string {
+	var b [][]string
+	for i := 0; i < len(names); i += maxAlarmNames {
+		b = append(b, names[i:min(i+maxAlarmNames, len(names))])
+	}
+	return b
+}
@@ -0,0 +1,139 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type alarmsMock struct {
+	cloudwatchiface.CloudWatchAPI
+	input    *cloudwatch.DescribeAlarmsInput
+	names    [][]string
+	disabled [][]string
+	enabled  [][]string
+	deleted  [][]string
+}
+
+func (m *alarmsMock) DescribeAlarmsPagesWithContext(ctx aws.Context, input *cloudwatch.DescribeAlarmsInput, f func(*cloudwatch.DescribeAlarmsOutput, bool) bool, opts ...request.Option) error {
+	m.input = input
+	m.names = append(m.names, aws.StringValueSlice(input.AlarmNames))
+	f(&cloudwatch.DescribeAlarmsOutput{
+		MetricAlarms: []*cloudwatch.MetricAlarm{
+			{AlarmName: aws.String("dev-queue-depth"), Namespace: aws.String("AWS/SQS"), MetricName: aws.String("ApproximateNumberOfMessagesVisible"), StateValue: aws.String("ALARM"), ActionsEnabled: aws.Bool(true)},
+			{AlarmName: aws.String("dev-table-throttles"), Namespace: aws.String("AWS/DynamoDB"), StateValue: aws.String("OK"), ActionsEnabled: aws.Bool(false)},
+		},
+	}, false)
+	f(&cloudwatch.DescribeAlarmsOutput{
+		CompositeAlarms: []*cloudwatch.CompositeAlarm{
+			{AlarmName: aws.String("dev-service"), StateValue: aws.String("OK"), ActionsEnabled: aws.Bool(true)},
+		},
+	}, true)
+	return nil
+}
+
+func (m *alarmsMock) DisableAlarmActionsWithContext(ctx aws.Context, input *cloudwatch.DisableAlarmActionsInput, opts ...request.Option) (*cloudwatch.DisableAlarmActionsOutput, error) {
+	m.disabled = append(m.disabled, aws.StringValueSlice(input.AlarmNames))
+	return &cloudwatch.DisableAlarmActionsOutput{}, nil
+}
+
+func (m *alarmsMock) EnableAlarmActionsWithContext(ctx aws.Context, input *cloudwatch.EnableAlarmActionsInput, opts ...request.Option) (*cloudwatch.EnableAlarmActionsOutput, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.enabled = append(m.enabled, aws.StringValueSlice(input.AlarmNames))
+	return &cloudwatch.EnableAlarmActionsOutput{}, nil
+}
+
+func (m *alarmsMock) DeleteAlarmsWithContext(ctx aws.Context, input *cloudwatch.DeleteAlarmsInput, opts ...request.Option) (*cloudwatch.DeleteAlarmsOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValueSlice(input.AlarmNames))
+	return &cloudwatch.DeleteAlarmsOutput{}, nil
+}
+
+func TestAlarms(t *testing.T) {
+	t.Run("Should return metric and composite alarms", func(t *testing.T) {
+		mock := &alarmsMock{}
+		alarms, err := Alarms(context.Background(), AlarmFilter{Prefix: "dev-", State: "alarm"}, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dev-queue-depth", "dev-table-throttles", "dev-service"}, AlarmNames(alarms))
+		assert.Equal(t, "dev-", *mock.input.AlarmNamePrefix)
+		assert.Equal(t, "ALARM", *mock.input.StateValue)
+	})
+
+	t.Run("Should describe names in batches", func(t *testing.T) {
+		var names []string
+		for i := 0; i < 150; i++ {
+			names = append(names, fmt.Sprintf("alarm-%d", i))
+		}
+		mock := &alarmsMock{}
+		_, err := Alarms(context.Background(), AlarmFilter{Names: names}, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(mock.names))
+		assert.Equal(t, 100, len(mock.names[0]))
+		assert.Equal(t, 50, len(mock.names[1]))
+	})
+
+	t.Run("Should filter on namespace", func(t *testing.T) {
+		alarms, err := Alarms(context.Background(), AlarmFilter{Namespace: "AWS/SQS"}, &alarmsMock{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dev-queue-depth"}, AlarmNames(alarms))
+	})
+}
+
+func TestWriteAlarms(t *testing.T) {
+	alarms, _ := Alarms(context.Background(), AlarmFilter{}, &alarmsMock{})
+	var buf bytes.Buffer
+
+	assert.Nil(t, WriteAlarms(&buf, alarms, "table"))
+	assert.Contains(t, buf.String(), "AWS/SQS/ApproximateNumberOfMessagesVisible")
+	assert.Contains(t, buf.String(), "disabled")
+	assert.NotNil(t, WriteAlarms(&buf, alarms, "csv"))
+}
+
+func TestSilence(t *testing.T) {
+	alarms, _ := Alarms(context.Background(), AlarmFilter{}, &alarmsMock{})
+
+	t.Run("Should enable actions when the time is up", func(t *testing.T) {
+		mock := &alarmsMock{}
+		names, err := Silence(context.Background(), alarms, time.Millisecond, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dev-queue-depth", "dev-service"}, names)
+		assert.Equal(t, [][]string{names}, mock.disabled)
+		assert.Equal(t, [][]string{names}, mock.enabled)
+	})
+
+	t.Run("Should enable actions when cancelled", func(t *testing.T) {
+		mock := &alarmsMock{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		names, err := Silence(ctx, alarms, time.Hour, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{names}, mock.enabled)
+	})
+}
+
+func TestDeleteAlarms(t *testing.T) {
+	var names []string
+	for i := 0; i < 150; i++ {
+		names = append(names, fmt.Sprintf("alarm-%d", i))
+	}
+	mock := &alarmsMock{}
+
+	assert.Nil(t, DeleteAlarms(context.Background(), names, mock))
+	assert.Equal(t, 2, len(mock.deleted))
+	assert.Equal(t, 100, len(mock.deleted[0]))
+	assert.Equal(t, 50, len(mock.deleted[1]))
+}
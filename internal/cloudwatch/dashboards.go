@@ -0,0 +1,241 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// ErrDashboardNotFound is returned by GetDashboard when the dashboard does not exist.
+var ErrDashboardNotFound = errors.New("dashboard not found")
+
+// diffContext is the number of unchanged lines printed around changes by Diff.
+const diffContext = 3
+
+// Dashboards returns the names of the dashboards starting with the prefix.
+func Dashboards(ctx context.Context, prefix string, c cloudwatchiface.CloudWatchAPI) ([]string, error) {
+	input := &cloudwatch.ListDashboardsInput{}
+	if prefix != "" {
+		input.DashboardNamePrefix = aws.String(prefix)
+	}
+	var names []string
+	err := c.ListDashboardsPagesWithContext(ctx, input, func(output *cloudwatch.ListDashboardsOutput, lastPage bool) bool {
+		for _, d := range output.DashboardEntries {
+			names = append(names, aws.StringValue(d.DashboardName))
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list dashboards: %v", err)
+	}
+	return names, nil
+}
+
+// GetDashboard returns the body of a dashboard formatted with sorted keys, or ErrDashboardNotFound.
+func GetDashboard(ctx context.Context, name string, c cloudwatchiface.CloudWatchAPI) (string, error) {
+	output, err := c.GetDashboardWithContext(ctx, &cloudwatch.GetDashboardInput{
+		DashboardName: aws.String(name),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatch.ErrCodeDashboardNotFoundError {
+		return "", ErrDashboardNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get dashboard %s: %v", name, err)
+	}
+	return FormatDashboard(aws.StringValue(output.DashboardBody))
+}
+
+// FormatDashboard indents a dashboard body and sorts its keys, so bodies can be compared line by
+// line.
+func FormatDashboard(body string) (string, error) {
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return "", fmt.Errorf("invalid dashboard body: %v", err)
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// Templatize replaces the values of vars in a dashboard body with template variables, e.g. the
+// region eu-west-1 with {{.Region}}, so an exported dashboard can be imported in other accounts.
+// Values are only replaced as whole words, dev in orders-dev but not in device, and longer values
+// are replaced first. Existing {{ are escaped, so the body renders to itself.
+func Templatize(body string, vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k, v := range vars {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(vars[keys[i]]) == len(vars[keys[j]]) {
+			return keys[i] < keys[j]
+		}
+		return len(vars[keys[i]]) > len(vars[keys[j]])
+	})
+
+	var b strings.Builder
+	for i := 0; i < len(body); {
+		if strings.HasPrefix(body[i:], "{{") {
+			b.WriteString("{{`{{`}}")
+			i += 2
+			continue
+		}
+		replaced := false
+		if i == 0 || !isWordByte(body[i-1]) {
+			for _, k := range keys {
+				v := vars[k]
+				end := i + len(v)
+				if strings.HasPrefix(body[i:], v) && (end == len(body) || !isWordByte(body[end])) {
+					b.WriteString("{{." + k + "}}")
+					i = end
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			b.WriteByte(body[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// RenderDashboard executes a dashboard body as a text/template with vars, e.g. {{.Env}} and
+// {{.Region}}, and returns the formatted body. Variables missing in vars are an error.
+func RenderDashboard(name, body string, vars map[string]string) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse dashboard %s: %v", name, err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("unable to render dashboard %s: %v", name, err)
+	}
+	return FormatDashboard(b.String())
+}
+
+// DashboardName returns the dashboard name of a file, the file name without the extension.
+func DashboardName(fileName string) string {
+	base := filepath.Base(fileName)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// DashboardFiles returns the json files in dir or the file itself when fileOrDir is a file.
+func DashboardFiles(fileOrDir string) ([]string, error) {
+	fi, err := os.Stat(fileOrDir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{fileOrDir}, nil
+	}
+	files, err := filepath.Glob(filepath.Join(fileOrDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no dashboard files in %s", fileOrDir)
+	}
+	return files, nil
+}
+
+// PutDashboard creates or updates a dashboard and returns the validation messages of the body.
+func PutDashboard(ctx context.Context, name, body string, c cloudwatchiface.CloudWatchAPI) ([]string, error) {
+	output, err := c.PutDashboardWithContext(ctx, &cloudwatch.PutDashboardInput{
+		DashboardName: aws.String(name),
+		DashboardBody: aws.String(body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to put dashboard %s: %v", name, err)
+	}
+	var messages []string
+	for _, m := range output.DashboardValidationMessages {
+		messages = append(messages, fmt.Sprintf("%s: %s", aws.StringValue(m.DataPath), aws.StringValue(m.Message)))
+	}
+	return messages, nil
+}
+
+// Diff compares two texts line by line and returns the changed lines prefixed with - and + with
+// unchanged lines around them, it is empty when the texts are the same.
+func Diff(from, to string) string {
+	a := strings.Split(strings.TrimSuffix(from, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(to, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	last := -1
+	for k, l := range lines {
+		near := false
+		for n := max(0, k-diffContext); n <= min(len(lines)-1, k+diffContext); n++ {
+			if lines[n].op != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			out.WriteString("...\n")
+		}
+		last = k
+		fmt.Fprintf(&out, "%c %s\n", l.op, l.text)
+	}
+	return out.String()
+}
@@ -0,0 +1,120 @@
+package cloudwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type dashboardsMock struct {
+	cloudwatchiface.CloudWatchAPI
+	put *cloudwatch.PutDashboardInput
+}
+
+func (m *dashboardsMock) GetDashboardWithContext(ctx aws.Context, input *cloudwatch.GetDashboardInput, opts ...request.Option) (*cloudwatch.GetDashboardOutput, error) {
+	if *input.DashboardName == "missing" {
+		return nil, awserr.New(cloudwatch.ErrCodeDashboardNotFoundError, "not found", nil)
+	}
+	return &cloudwatch.GetDashboardOutput{
+		DashboardName: input.DashboardName,
+		DashboardBody: aws.String(`{"widgets":[{"type":"metric","properties":{"region":"eu-west-1","title":"orders-prod"}}]}`),
+	}, nil
+}
+
+func (m *dashboardsMock) PutDashboardWithContext(ctx aws.Context, input *cloudwatch.PutDashboardInput, opts ...request.Option) (*cloudwatch.PutDashboardOutput, error) {
+	m.put = input
+	return &cloudwatch.PutDashboardOutput{DashboardValidationMessages: []*cloudwatch.DashboardValidationMessage{
+		{DataPath: aws.String("/widgets/0"), Message: aws.String("unknown metric")},
+	}}, nil
+}
+
+func TestTemplatizeAndRender(t *testing.T) {
+	body, err := GetDashboard(context.Background(), "orders", &dashboardsMock{})
+	assert.Nil(t, err)
+
+	template := Templatize(body, map[string]string{"Env": "prod", "Region": "eu-west-1"})
+	assert.Contains(t, template, `"region": "{{.Region}}"`)
+	assert.Contains(t, template, `"title": "orders-{{.Env}}"`)
+
+	t.Run("Should only replace whole words", func(t *testing.T) {
+		template := Templatize(`{"title": "device-dev", "metric": "dev_errors", "arn": "arn:aws:sqs:eu-west-1:111111111111:dev"}`, map[string]string{"Env": "dev", "Account": "111111111111"})
+
+		assert.Equal(t, `{"title": "device-{{.Env}}", "metric": "dev_errors", "arn": "arn:aws:sqs:eu-west-1:{{.Account}}:{{.Env}}"}`, template)
+	})
+
+	t.Run("Should keep existing braces", func(t *testing.T) {
+		body := `{"markdown": "use {{ and }} in {{.Env}}"}`
+		template := Templatize(body, nil)
+
+		rendered, err := RenderDashboard("orders", template, nil)
+		assert.Nil(t, err)
+		expected, _ := FormatDashboard(body)
+		assert.Equal(t, expected, rendered)
+	})
+
+	t.Run("Should render variables", func(t *testing.T) {
+		rendered, err := RenderDashboard("orders", template, map[string]string{"Env": "dev", "Region": "eu-north-1"})
+
+		assert.Nil(t, err)
+		assert.Contains(t, rendered, `"title": "orders-dev"`)
+		assert.Contains(t, rendered, `"region": "eu-north-1"`)
+	})
+
+	t.Run("Should fail on missing variables", func(t *testing.T) {
+		_, err := RenderDashboard("orders", template, map[string]string{"Env": "dev"})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should fail on invalid json", func(t *testing.T) {
+		_, err := RenderDashboard("orders", `{"widgets": [}`, nil)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetDashboard(t *testing.T) {
+	_, err := GetDashboard(context.Background(), "missing", &dashboardsMock{})
+
+	assert.Equal(t, ErrDashboardNotFound, err)
+}
+
+func TestPutDashboard(t *testing.T) {
+	mock := &dashboardsMock{}
+	messages, err := PutDashboard(context.Background(), "orders", "{}", mock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "orders", *mock.put.DashboardName)
+	assert.Equal(t, []string{"/widgets/0: unknown metric"}, messages)
+}
+
+func TestDashboardFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "orders.json"), []byte("{}"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(""), 0644))
+
+	files, err := DashboardFiles(dir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "orders.json")}, files)
+	assert.Equal(t, "orders", DashboardName(files[0]))
+}
+
+func TestDiff(t *testing.T) {
+	assert.Equal(t, "", Diff("a\nb\n", "a\nb\n"))
+	assert.Equal(t, "  a\n- b\n+ c\n  d\n", Diff("a\nb\nd\n", "a\nc\nd\n"))
+
+	t.Run("Should only print lines near changes", func(t *testing.T) {
+		diff := Diff("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+
+		assert.Equal(t, "+ 0\n  1\n  2\n  3\n...\n  7\n  8\n  9\n- 10\n", diff)
+	})
+}
@@ -0,0 +1,403 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// maxMetricDataQueries is the number of queries GetMetricData accepts in one request.
+const maxMetricDataQueries = 500
+
+// DefaultPeriod is the period of metric queries in seconds when none is given.
+const DefaultPeriod = 60
+
+var queryId = regexp.MustCompile(`^[a-z][a-zA-Z0-9_]*$`)
+
+// MetricQuery is a metric with a statistic or a metric math expression.
+type MetricQuery struct {
+	Id    string
+	Label string
+	// Expression is a metric math expression, e.g. m1/m2, the metric fields are not used with it.
+	Expression string
+	Namespace  string
+	MetricName string
+	Dimensions map[string]string
+	Stat       string
+	// Period in seconds.
+	Period int64
+	// Hidden queries are only used by expressions and not returned.
+	Hidden bool
+}
+
+// ParseMetricQuery parses a metric query of comma separated key=value pairs, e.g.
+// namespace=AWS/SQS,metric=ApproximateNumberOfMessagesVisible,dim.QueueName=orders,stat=Maximum.
+// Keys are id, label, namespace, metric, dim.<name>, stat, period and hidden. Stat defaults to
+// Average and period to defaultPeriod.
+func ParseMetricQuery(s string, defaultPeriod int64) (MetricQuery, error) {
+	q := MetricQuery{Stat: cloudwatch.StatisticAverage, Period: defaultPeriod}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || v == "" {
+			return q, fmt.Errorf("invalid metric query %s, expected key=value pairs", s)
+		}
+		switch {
+		case k == "id":
+			q.Id = v
+		case k == "label":
+			q.Label = v
+		case k == "namespace":
+			q.Namespace = v
+		case k == "metric":
+			q.MetricName = v
+		case strings.HasPrefix(k, "dim."):
+			if q.Dimensions == nil {
+				q.Dimensions = map[string]string{}
+			}
+			q.Dimensions[strings.TrimPrefix(k, "dim.")] = v
+		case k == "stat":
+			q.Stat = v
+		case k == "period":
+			period, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || period <= 0 {
+				return q, fmt.Errorf("invalid period %s", v)
+			}
+			q.Period = period
+		case k == "hidden":
+			hidden, err := strconv.ParseBool(v)
+			if err != nil {
+				return q, fmt.Errorf("invalid hidden %s", v)
+			}
+			q.Hidden = hidden
+		default:
+			return q, fmt.Errorf("unknown key %s in metric query %s", k, s)
+		}
+	}
+	if q.Namespace == "" || q.MetricName == "" {
+		return q, fmt.Errorf("namespace and metric are required in metric query %s", s)
+	}
+	if q.Id != "" && !queryId.MatchString(q.Id) {
+		return q, fmt.Errorf("invalid id %s, it must start with a lowercase letter", q.Id)
+	}
+	return q, nil
+}
+
+// ParseMetricExpression parses a metric math expression with an optional id, e.g. ratio=m1/m2.
+// Comparisons like m1==0 have no id.
+func ParseMetricExpression(s string) (MetricQuery, error) {
+	q := MetricQuery{Expression: s}
+	id, expression, ok := strings.Cut(s, "=")
+	if ok && !strings.HasPrefix(expression, "=") && queryId.MatchString(strings.TrimSpace(id)) {
+		q.Id = strings.TrimSpace(id)
+		q.Label = q.Id
+		q.Expression = expression
+	}
+	if strings.TrimSpace(q.Expression) == "" {
+		return q, fmt.Errorf("expression is required")
+	}
+	return q, nil
+}
+
+// Presets are the names of the metric presets of Preset.
+var Presets = []string{"dynamodb", "sqs", "lambda"}
+
+// Preset returns the metric queries of a common resource: the consumed and provisioned capacity
+// of a DynamoDB table, the depth of an SQS queue or the invocations of a Lambda function.
+func Preset(name, resource string, period int64) ([]MetricQuery, error) {
+	if resource == "" {
+		return nil, fmt.Errorf("resource is required for preset %s", name)
+	}
+	metric := func(id, namespace, metricName, dimension, stat string, hidden bool) MetricQuery {
+		return MetricQuery{
+			Id:         id,
+			Label:      metricName,
+			Namespace:  namespace,
+			MetricName: metricName,
+			Dimensions: map[string]string{dimension: resource},
+			Stat:       stat,
+			Period:     period,
+			Hidden:     hidden,
+		}
+	}
+	switch name {
+	case "dynamodb":
+		// consumed capacity is a sum per period, divided by the period it is per second like
+		// the provisioned capacity
+		return []MetricQuery{
+			metric("cr", "AWS/DynamoDB", "ConsumedReadCapacityUnits", "TableName", cloudwatch.StatisticSum, true),
+			metric("cw", "AWS/DynamoDB", "ConsumedWriteCapacityUnits", "TableName", cloudwatch.StatisticSum, true),
+			{Id: "consumedRead", Label: "ConsumedReadCapacityUnits/s", Expression: "cr/PERIOD(cr)"},
+			metric("pr", "AWS/DynamoDB", "ProvisionedReadCapacityUnits", "TableName", cloudwatch.StatisticAverage, false),
+			{Id: "consumedWrite", Label: "ConsumedWriteCapacityUnits/s", Expression: "cw/PERIOD(cw)"},
+			metric("pw", "AWS/DynamoDB", "ProvisionedWriteCapacityUnits", "TableName", cloudwatch.StatisticAverage, false),
+			metric("throttled", "AWS/DynamoDB", "ThrottledRequests", "TableName", cloudwatch.StatisticSum, false),
+		}, nil
+	case "sqs":
+		return []MetricQuery{
+			metric("visible", "AWS/SQS", "ApproximateNumberOfMessagesVisible", "QueueName", cloudwatch.StatisticMaximum, false),
+			metric("inflight", "AWS/SQS", "ApproximateNumberOfMessagesNotVisible", "QueueName", cloudwatch.StatisticMaximum, false),
+			metric("age", "AWS/SQS", "ApproximateAgeOfOldestMessage", "QueueName", cloudwatch.StatisticMaximum, false),
+			metric("sent", "AWS/SQS", "NumberOfMessagesSent", "QueueName", cloudwatch.StatisticSum, false),
+			metric("deleted", "AWS/SQS", "NumberOfMessagesDeleted", "QueueName", cloudwatch.StatisticSum, false),
+		}, nil
+	case "lambda":
+		return []MetricQuery{
+			metric("invocations", "AWS/Lambda", "Invocations", "FunctionName", cloudwatch.StatisticSum, false),
+			metric("errors", "AWS/Lambda", "Errors", "FunctionName", cloudwatch.StatisticSum, false),
+			metric("throttles", "AWS/Lambda", "Throttles", "FunctionName", cloudwatch.StatisticSum, false),
+			metric("duration", "AWS/Lambda", "Duration", "FunctionName", "p99", false),
+			metric("concurrency", "AWS/Lambda", "ConcurrentExecutions", "FunctionName", cloudwatch.StatisticMaximum, false),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown preset %s, use %s", name, strings.Join(Presets, ", "))
+	}
+}
+
+// Point is a metric value at a time.
+type Point struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// MetricSeries are the values of a metric query in chronological order.
+type MetricSeries struct {
+	Id     string  `json:"id"`
+	Label  string  `json:"label"`
+	Points []Point `json:"points"`
+}
+
+// WithDefaultIds returns a copy of queries where the ones without an id get m1, m2 and so on by
+// their position. Number the queries given by the user before adding a preset, so m1 is the
+// first of them.
+func WithDefaultIds(queries []MetricQuery) []MetricQuery {
+	result := make([]MetricQuery, len(queries))
+	for i, q := range queries {
+		if q.Id == "" {
+			q.Id = fmt.Sprintf("m%d", i+1)
+		}
+		result[i] = q
+	}
+	return result
+}
+
+// GetMetrics returns the values of the queries between start and end, one series per query that
+// is not hidden, in the order of the queries. Queries without an id are numbered with
+// WithDefaultIds.
+func GetMetrics(ctx context.Context, queries []MetricQuery, start, end time.Time, c cloudwatchiface.CloudWatchAPI) ([]MetricSeries, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("at least one metric query is required")
+	}
+	if len(queries) > maxMetricDataQueries {
+		return nil, fmt.Errorf("too many metric queries %d, max %d", len(queries), maxMetricDataQueries)
+	}
+
+	var series []*MetricSeries
+	byId := map[string]*MetricSeries{}
+	var input []*cloudwatch.MetricDataQuery
+	for _, q := range WithDefaultIds(queries) {
+		id := q.Id
+		if _, ok := byId[id]; ok {
+			return nil, fmt.Errorf("duplicate query id %s", id)
+		}
+		mdq := &cloudwatch.MetricDataQuery{
+			Id:         aws.String(id),
+			ReturnData: aws.Bool(!q.Hidden),
+		}
+		label := q.Label
+		if q.Expression != "" {
+			mdq.Expression = aws.String(q.Expression)
+			if label == "" {
+				label = q.Expression
+			}
+		} else {
+			var dimensions []*cloudwatch.Dimension
+			for _, name := range sortedKeys(q.Dimensions) {
+				dimensions = append(dimensions, &cloudwatch.Dimension{Name: aws.String(name), Value: aws.String(q.Dimensions[name])})
+			}
+			period := q.Period
+			if period <= 0 {
+				period = DefaultPeriod
+			}
+			mdq.MetricStat = &cloudwatch.MetricStat{
+				Metric: &cloudwatch.Metric{
+					Namespace:  aws.String(q.Namespace),
+					MetricName: aws.String(q.MetricName),
+					Dimensions: dimensions,
+				},
+				Period: aws.Int64(period),
+				Stat:   aws.String(q.Stat),
+			}
+			if label == "" {
+				label = q.MetricName
+			}
+		}
+		if label != "" {
+			mdq.Label = aws.String(label)
+		}
+		input = append(input, mdq)
+		s := &MetricSeries{Id: id, Label: label}
+		byId[id] = s
+		if !q.Hidden {
+			series = append(series, s)
+		}
+	}
+
+	err := c.GetMetricDataPagesWithContext(ctx, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: input,
+		StartTime:         aws.Time(start),
+		EndTime:           aws.Time(end),
+		ScanBy:            aws.String(cloudwatch.ScanByTimestampAscending),
+	}, func(output *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+		for _, r := range output.MetricDataResults {
+			s, ok := byId[aws.StringValue(r.Id)]
+			if !ok {
+				continue
+			}
+			for i := 0; i < len(r.Timestamps) && i < len(r.Values); i++ {
+				s.Points = append(s.Points, Point{Timestamp: aws.TimeValue(r.Timestamps[i]).UTC(), Value: aws.Float64Value(r.Values[i])})
+			}
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metric data: %v", err)
+	}
+
+	result := make([]MetricSeries, len(series))
+	for i, s := range series {
+		sort.SliceStable(s.Points, func(i, j int) bool {
+			return s.Points[i].Timestamp.Before(s.Points[j].Timestamp)
+		})
+		result[i] = *s
+	}
+	return result, nil
+}
+
+// WriteMetrics writes the series as csv with a column per series, as json or as sparkline charts.
+func WriteMetrics(w io.Writer, series []MetricSeries, format string, width int) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(series)
+	case "csv":
+		return writeMetricsCSV(w, series)
+	case "chart":
+		return writeSparklines(w, series, width)
+	default:
+		return fmt.Errorf("unknown output %s, use chart, csv or json", format)
+	}
+}
+
+func writeMetricsCSV(w io.Writer, series []MetricSeries) error {
+	values := map[time.Time][]string{}
+	var timestamps []time.Time
+	for i, s := range series {
+		for _, p := range s.Points {
+			row, ok := values[p.Timestamp]
+			if !ok {
+				row = make([]string, len(series))
+				values[p.Timestamp] = row
+				timestamps = append(timestamps, p.Timestamp)
+			}
+			row[i] = strconv.FormatFloat(p.Value, 'f', -1, 64)
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	cw := csv.NewWriter(w)
+	header := []string{"timestamp"}
+	for _, s := range series {
+		header = append(header, s.Label)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, ts := range timestamps {
+		if err := cw.Write(append([]string{ts.Format(time.RFC3339)}, values[ts]...)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws the values with one character each, scaled between the min and max value.
+// Values are averaged into width buckets when there are more of them.
+func Sparkline(values []float64, width int) string {
+	if width > 0 && len(values) > width {
+		buckets := make([]float64, width)
+		for i := range buckets {
+			from, to := i*len(values)/width, (i+1)*len(values)/width
+			sum := 0.0
+			for _, v := range values[from:to] {
+				sum += v
+			}
+			buckets[i] = sum / float64(to-from)
+		}
+		values = buckets
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if high > low {
+			i = int((v - low) / (high - low) * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+func writeSparklines(w io.Writer, series []MetricSeries, width int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tMIN\tMAX\tLAST\tCHART")
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\tno datapoints\n", s.Label)
+			continue
+		}
+		values := make([]float64, len(s.Points))
+		low, high := math.Inf(1), math.Inf(-1)
+		for i, p := range s.Points {
+			values[i] = p.Value
+			low, high = math.Min(low, p.Value), math.Max(high, p.Value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Label, formatValue(low), formatValue(high), formatValue(values[len(values)-1]), Sparkline(values, width))
+	}
+	return tw.Flush()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type metricsMock struct {
+	cloudwatchiface.CloudWatchAPI
+	input *cloudwatch.GetMetricDataInput
+}
+
+func (m *metricsMock) GetMetricDataPagesWithContext(ctx aws.Context, input *cloudwatch.GetMetricDataInput, f func(*cloudwatch.GetMetricDataOutput, bool) bool, opts ...request.Option) error {
+	m.input = input
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	if f(&cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{
+		{Id: aws.String("m1"), Timestamps: []*time.Time{aws.Time(t0.Add(time.Minute))}, Values: []*float64{aws.Float64(2)}},
+		{Id: aws.String("ratio"), Timestamps: []*time.Time{aws.Time(t0)}, Values: []*float64{aws.Float64(0.5)}},
+	}}, false) {
+		f(&cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{
+			{Id: aws.String("m1"), Timestamps: []*time.Time{aws.Time(t0)}, Values: []*float64{aws.Float64(1)}},
+		}}, true)
+	}
+	return nil
+}
+
+func TestParseMetricQuery(t *testing.T) {
+	t.Run("Should parse metric query", func(t *testing.T) {
+		q, err := ParseMetricQuery("namespace=AWS/SQS,metric=ApproximateNumberOfMessagesVisible,dim.QueueName=orders,stat=Maximum,period=300", 60)
+
+		assert.Nil(t, err)
+		assert.Equal(t, MetricQuery{
+			Namespace:  "AWS/SQS",
+			MetricName: "ApproximateNumberOfMessagesVisible",
+			Dimensions: map[string]string{"QueueName": "orders"},
+			Stat:       "Maximum",
+			Period:     300,
+		}, q)
+	})
+
+	for _, s := range []string{
+		"metric=Errors",
+		"namespace=AWS/Lambda,metric=Errors,color=red",
+		"namespace=AWS/Lambda,metric=Errors,period=0",
+		"namespace=AWS/Lambda,metric=Errors,id=Errors",
+		"namespace",
+	} {
+		t.Run("Should not parse "+s, func(t *testing.T) {
+			_, err := ParseMetricQuery(s, 60)
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestParseMetricExpression(t *testing.T) {
+	q, err := ParseMetricExpression("ratio=m1/m2")
+	assert.Nil(t, err)
+	assert.Equal(t, "ratio", q.Id)
+	assert.Equal(t, "m1/m2", q.Expression)
+
+	q, err = ParseMetricExpression("m1==0")
+	assert.Nil(t, err)
+	assert.Equal(t, "", q.Id)
+	assert.Equal(t, "m1==0", q.Expression)
+
+	q, err = ParseMetricExpression("SUM(METRICS())")
+	assert.Nil(t, err)
+	assert.Equal(t, "", q.Id)
+
+	_, err = ParseMetricExpression("")
+	assert.NotNil(t, err)
+}
+
+func TestPreset(t *testing.T) {
+	queries, err := Preset("sqs", "orders", 60)
+	assert.Nil(t, err)
+	assert.Equal(t, "orders", queries[0].Dimensions["QueueName"])
+
+	_, err = Preset("sqs", "", 60)
+	assert.NotNil(t, err)
+
+	_, err = Preset("rds", "orders", 60)
+	assert.NotNil(t, err)
+}
+
+func TestGetMetrics(t *testing.T) {
+	mock := &metricsMock{}
+	series, err := GetMetrics(context.Background(), []MetricQuery{
+		{Namespace: "AWS/SQS", MetricName: "NumberOfMessagesSent", Stat: "Sum", Dimensions: map[string]string{"QueueName": "orders"}},
+		{Id: "hidden", Namespace: "AWS/SQS", MetricName: "NumberOfMessagesDeleted", Stat: "Sum", Hidden: true},
+		{Id: "ratio", Label: "ratio", Expression: "hidden/m1"},
+	}, time.Now().Add(-time.Hour), time.Now(), mock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(mock.input.MetricDataQueries))
+	assert.Equal(t, int64(DefaultPeriod), *mock.input.MetricDataQueries[0].MetricStat.Period)
+	assert.False(t, *mock.input.MetricDataQueries[1].ReturnData)
+	assert.Equal(t, 2, len(series))
+	assert.Equal(t, "NumberOfMessagesSent", series[0].Label)
+	assert.Equal(t, []float64{1, 2}, []float64{series[0].Points[0].Value, series[0].Points[1].Value})
+	assert.Equal(t, "ratio", series[1].Id)
+
+	t.Run("Should write csv with a column per series", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, WriteMetrics(&buf, series, "csv", 0))
+		assert.Equal(t, "timestamp,NumberOfMessagesSent,ratio\n2024-03-01T10:00:00Z,1,0.5\n2024-03-01T10:01:00Z,2,\n", buf.String())
+	})
+}
+
+func TestGetMetrics_Preset(t *testing.T) {
+	mock := &metricsMock{}
+	preset, err := Preset("sqs", "orders", 60)
+	assert.Nil(t, err)
+	expression, err := ParseMetricExpression("m1*2")
+	assert.Nil(t, err)
+	queries := WithDefaultIds([]MetricQuery{
+		{Namespace: "AWS/SQS", MetricName: "NumberOfMessagesSent", Stat: "Sum"},
+		expression,
+	})
+
+	_, err = GetMetrics(context.Background(), append(preset, queries...), time.Now().Add(-time.Hour), time.Now(), mock)
+
+	assert.Nil(t, err)
+	var ids []string
+	for _, q := range mock.input.MetricDataQueries {
+		ids = append(ids, *q.Id)
+	}
+	assert.Equal(t, []string{"visible", "inflight", "age", "sent", "deleted", "m1", "m2"}, ids)
+	assert.Equal(t, "NumberOfMessagesSent", *mock.input.MetricDataQueries[5].MetricStat.Metric.MetricName)
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", Sparkline([]float64{0, 5, 10}, 0))
+	assert.Equal(t, "▁▁", Sparkline([]float64{3, 3}, 0))
+	assert.Equal(t, "▁█", Sparkline([]float64{0, 0, 10, 10}, 2))
+	assert.Equal(t, "", Sparkline(nil, 10))
+}
@@ -0,0 +1,145 @@
+// Package crypto encrypts exports in the age format, https://age-encryption.org, so they can be
+// decrypted with flow or the age tools.
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrNoIdentity is returned by Decrypt for encrypted input without identities.
+var ErrNoIdentity = errors.New("input is encrypted, an identity or passphrase is required")
+
+const binaryHeader = "age-encryption.org/v1"
+
+// ParseRecipient parses an age X25519 recipient, age1..., or reads the recipients from a file: an
+// RSA public key in PEM format written by crypto genrsa, an ssh public key or age recipients.
+// RSA keys are used with RSA-OAEP.
+func ParseRecipient(s string) ([]age.Recipient, error) {
+	if strings.HasPrefix(s, "age1") {
+		r, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse recipient %s: %v", s, err)
+		}
+		return []age.Recipient{r}, nil
+	}
+	b, err := os.ReadFile(s)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read recipient file: %v", err)
+	}
+	if block, _ := pem.Decode(b); block != nil {
+		var key interface{}
+		switch block.Type {
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		default:
+			return nil, fmt.Errorf("%s has a %s, expected a public key", s, block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse public key %s: %v", s, err)
+		}
+		pk, err := ssh.NewPublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported public key %s: %v", s, err)
+		}
+		r, err := agessh.NewRSARecipient(pk)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported public key %s: %v", s, err)
+		}
+		return []age.Recipient{r}, nil
+	}
+	if strings.HasPrefix(string(b), "ssh-") {
+		r, err := agessh.ParseRecipient(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ssh key %s: %v", s, err)
+		}
+		return []age.Recipient{r}, nil
+	}
+	recipients, err := age.ParseRecipients(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse recipients %s: %v", s, err)
+	}
+	return recipients, nil
+}
+
+// ParseIdentities reads the identities from a file: an RSA private key in PEM format written by
+// crypto genrsa, an ssh private key or age identities, AGE-SECRET-KEY-1....
+func ParseIdentities(file string) ([]age.Identity, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read identity file: %v", err)
+	}
+	if block, _ := pem.Decode(b); block != nil {
+		identity, err := agessh.ParseIdentity(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key %s: %v", file, err)
+		}
+		return []age.Identity{identity}, nil
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse identities %s: %v", file, err)
+	}
+	return identities, nil
+}
+
+type armoredWriter struct {
+	io.WriteCloser
+	armor io.WriteCloser
+}
+
+func (w *armoredWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return w.armor.Close()
+}
+
+// Encrypt returns a writer that encrypts to w for the recipients, in the ASCII armored format so
+// the output can be pasted. The output is complete after Close.
+func Encrypt(w io.Writer, recipients ...age.Recipient) (io.WriteCloser, error) {
+	a := armor.NewWriter(w)
+	encrypted, err := age.Encrypt(a, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt: %v", err)
+	}
+	return &armoredWriter{WriteCloser: encrypted, armor: a}, nil
+}
+
+// Decrypt returns a reader of the decrypted input, armored or binary. Input that is not encrypted
+// is read as it is.
+func Decrypt(r io.Reader, identities ...age.Identity) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(len(armor.Header))
+	var src io.Reader
+	switch {
+	case string(header) == armor.Header:
+		src = armor.NewReader(br)
+	case strings.HasPrefix(string(header), binaryHeader):
+		src = br
+	default:
+		return br, nil
+	}
+	if len(identities) == 0 {
+		return nil, ErrNoIdentity
+	}
+	decrypted, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt: %v", err)
+	}
+	return decrypted, nil
+}
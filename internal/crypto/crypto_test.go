@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+)
+
+func roundTrip(t *testing.T, recipients []age.Recipient, identities []age.Identity) string {
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, recipients...)
+	assert.Nil(t, err)
+	_, err = io.WriteString(w, `[{"Name":"db","Value":"p4ss"}]`)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	assert.NotContains(t, buf.String(), "p4ss")
+
+	r, err := Decrypt(&buf, identities...)
+	assert.Nil(t, err)
+	b, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func TestEncrypt(t *testing.T) {
+	t.Run("Should encrypt for X25519 recipients", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		assert.Nil(t, err)
+		recipients, err := ParseRecipient(identity.Recipient().String())
+		assert.Nil(t, err)
+
+		assert.Equal(t, `[{"Name":"db","Value":"p4ss"}]`, roundTrip(t, recipients, []age.Identity{identity}))
+	})
+
+	t.Run("Should encrypt for RSA keys of genrsa", func(t *testing.T) {
+		dir := t.TempDir()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.Nil(t, err)
+		privateKey := filepath.Join(dir, "private_key.pem")
+		publicKey := filepath.Join(dir, "public_key.pem")
+		assert.Nil(t, os.WriteFile(privateKey, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+		assert.Nil(t, os.WriteFile(publicKey, pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}), 0600))
+
+		recipients, err := ParseRecipient(publicKey)
+		assert.Nil(t, err)
+		identities, err := ParseIdentities(privateKey)
+		assert.Nil(t, err)
+
+		assert.Equal(t, `[{"Name":"db","Value":"p4ss"}]`, roundTrip(t, recipients, identities))
+	})
+
+	t.Run("Should encrypt with a passphrase", func(t *testing.T) {
+		recipient, err := age.NewScryptRecipient("correct horse")
+		assert.Nil(t, err)
+		identity, err := age.NewScryptIdentity("correct horse")
+		assert.Nil(t, err)
+
+		assert.Equal(t, `[{"Name":"db","Value":"p4ss"}]`, roundTrip(t, []age.Recipient{recipient}, []age.Identity{identity}))
+	})
+}
+
+func TestDecrypt(t *testing.T) {
+	t.Run("Should read plaintext", func(t *testing.T) {
+		r, err := Decrypt(strings.NewReader(`[]`))
+		assert.Nil(t, err)
+		b, _ := io.ReadAll(r)
+		assert.Equal(t, `[]`, string(b))
+	})
+
+	t.Run("Should require an identity", func(t *testing.T) {
+		identity, _ := age.GenerateX25519Identity()
+		var buf bytes.Buffer
+		w, err := Encrypt(&buf, identity.Recipient())
+		assert.Nil(t, err)
+		assert.Nil(t, w.Close())
+
+		_, err = Decrypt(&buf)
+		assert.Equal(t, ErrNoIdentity, err)
+	})
+}
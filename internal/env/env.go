@@ -0,0 +1,123 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var invalidChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// Name maps a parameter or secret key to an environment variable name, e.g. db/password and
+// db-password to DB_PASSWORD.
+func Name(key string) string {
+	name := strings.Trim(invalidChars.ReplaceAllString(strings.ToUpper(key), "_"), "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// FromParameters maps parameters, name to value, to environment variables named after the name
+// relative to the path, e.g. /app/dev/db/host under /app/dev/ to DB_HOST. Names that map to no
+// variable name or to the same one, e.g. /app/dev/db/host and /app/dev/db-host, are an error.
+func FromParameters(path string, parameters map[string]string) (map[string]string, error) {
+	vars := map[string]string{}
+	keys := map[string]string{}
+	for _, name := range sortedKeys(parameters) {
+		rel := strings.TrimPrefix(name, strings.TrimSuffix(path, "/"))
+		if err := add(vars, keys, name, Name(rel), parameters[name]); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
+
+// FromSecret maps a secret to environment variables. The keys of a JSON object are separate
+// variables, other values are one variable named after the last segment of the secret name.
+// Keys that map to no variable name or to the same one are an error.
+func FromSecret(secretName, value string) (map[string]string, error) {
+	vars := map[string]string{}
+	keys := map[string]string{}
+	var object map[string]any
+	if err := json.Unmarshal([]byte(value), &object); err != nil {
+		segments := strings.Split(secretName, "/")
+		if err := add(vars, keys, secretName, Name(segments[len(segments)-1]), value); err != nil {
+			return nil, err
+		}
+		return vars, nil
+	}
+	for _, k := range sortedKeys(object) {
+		s, ok := object[k].(string)
+		if !ok {
+			b, _ := json.Marshal(object[k])
+			s = string(b)
+		}
+		if err := add(vars, keys, k, Name(k), s); err != nil {
+			return nil, fmt.Errorf("secret %s: %v", secretName, err)
+		}
+	}
+	return vars, nil
+}
+
+// add sets vars[name] to value, keys records the key each name came from.
+func add(vars, keys map[string]string, key, name, value string) error {
+	if name == "" {
+		return fmt.Errorf("%s does not map to an environment variable name", key)
+	}
+	if other, ok := keys[name]; ok {
+		return fmt.Errorf("%s and %s both map to %s", other, key, name)
+	}
+	keys[name] = key
+	vars[name] = value
+	return nil
+}
+
+// Environ returns base, as returned by os.Environ, with the variables added or replaced.
+func Environ(base []string, vars map[string]string) []string {
+	var environ []string
+	for _, kv := range base {
+		k, _, _ := strings.Cut(kv, "=")
+		if _, ok := vars[k]; !ok {
+			environ = append(environ, kv)
+		}
+	}
+	for _, k := range sortedKeys(vars) {
+		environ = append(environ, k+"="+vars[k])
+	}
+	return environ
+}
+
+// WriteDotenv writes the variables in .env format for docker compose, values are double quoted
+// with quotes, backslashes and newlines escaped.
+func WriteDotenv(w io.Writer, vars map[string]string) error {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	for _, k := range sortedKeys(vars) {
+		if _, err := fmt.Fprintf(w, "%s=\"%s\"\n", k, escaper.Replace(vars[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteExports writes the variables as shell export statements, values are single quoted.
+func WriteExports(w io.Writer, vars map[string]string) error {
+	for _, k := range sortedKeys(vars) {
+		if _, err := fmt.Fprintf(w, "export %s='%s'\n", k, strings.ReplaceAll(vars[k], "'", `'\''`)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package env
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestName(t *testing.T) {
+	for key, name := range map[string]string{
+		"db/password":    "DB_PASSWORD",
+		"/db-host/":      "DB_HOST",
+		"apiKey":         "APIKEY",
+		"2fa.secret":     "_2FA_SECRET",
+		"queue..url":     "QUEUE_URL",
+		"already_FORMAT": "ALREADY_FORMAT",
+	} {
+		t.Run("Should map "+key, func(t *testing.T) {
+			assert.Equal(t, name, Name(key))
+		})
+	}
+}
+
+func TestFromParameters(t *testing.T) {
+	t.Run("Should name variables relative to the path", func(t *testing.T) {
+		vars, err := FromParameters("/app/dev/", map[string]string{
+			"/app/dev/db/host": "localhost",
+			"/app/dev/name":    "orders",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "localhost", "NAME": "orders"}, vars)
+	})
+
+	t.Run("Should fail when names collide", func(t *testing.T) {
+		_, err := FromParameters("/app/", map[string]string{
+			"/app/db/host": "a",
+			"/app/db-host": "b",
+		})
+
+		assert.EqualError(t, err, "/app/db-host and /app/db/host both map to DB_HOST")
+	})
+
+	t.Run("Should fail when a name is empty", func(t *testing.T) {
+		_, err := FromParameters("/app/", map[string]string{"/app/-": "a"})
+
+		assert.EqualError(t, err, "/app/- does not map to an environment variable name")
+	})
+}
+
+func TestFromSecret(t *testing.T) {
+	t.Run("Should expand JSON secrets", func(t *testing.T) {
+		vars, err := FromSecret("my/app/secret", `{"username":"admin","password":"p4ss","port":5432}`)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"USERNAME": "admin", "PASSWORD": "p4ss", "PORT": "5432"}, vars)
+	})
+
+	t.Run("Should name plain secrets after the secret", func(t *testing.T) {
+		vars, err := FromSecret("my/app/api-key", "abc")
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"API_KEY": "abc"}, vars)
+	})
+
+	t.Run("Should fail when keys collide", func(t *testing.T) {
+		_, err := FromSecret("my/app/secret", `{"db-host":"a","db_host":"b"}`)
+
+		assert.EqualError(t, err, "secret my/app/secret: db-host and db_host both map to DB_HOST")
+	})
+}
+
+func TestEnviron(t *testing.T) {
+	environ := Environ([]string{"HOME=/root", "DB_HOST=db"}, map[string]string{"DB_HOST": "localhost", "A": "1"})
+
+	assert.Equal(t, []string{"HOME=/root", "A=1", "DB_HOST=localhost"}, environ)
+}
+
+func TestWriteDotenv(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.Nil(t, WriteDotenv(&buf, map[string]string{"B": "say \"hi\"\n$HOME", "A": "1"}))
+	assert.Equal(t, "A=\"1\"\nB=\"say \\\"hi\\\"\\n\\$HOME\"\n", buf.String())
+}
+
+func TestWriteExports(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.Nil(t, WriteExports(&buf, map[string]string{"PASSWORD": "it's", "A": "$HOME"}))
+	assert.Equal(t, "export A='$HOME'\nexport PASSWORD='it'\\''s'\n", buf.String())
+}
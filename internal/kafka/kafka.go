@@ -133,8 +133,6 @@ func (ss *saramaService) Produce(ctx context.Context, topic string, msg Message)
 			}
 		}
 	}
-
-	return nil
 }
 
 func (ss *saramaService) Pipe(ctx context.Context, c <-chan Message, topic string) error {
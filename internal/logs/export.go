@@ -0,0 +1,327 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	LogGroupName  string
+	FilterPattern string
+	// StartTime and EndTime limit the events, zero values are unbounded.
+	StartTime time.Time
+	EndTime   time.Time
+	// Format is jsonl, csv or text.
+	Format string
+	// FileName is the output file, with MaxFileSize set parts are named like name-00000.jsonl.
+	FileName string
+	// MaxFileSize in bytes starts a new part when exceeded, 0 writes a single file.
+	MaxFileSize int64
+	// ResumeFileName is where the resume token is written after every page.
+	ResumeFileName string
+	// Resume continues the export from the token in ResumeFileName.
+	Resume bool
+}
+
+// ExportToken records how far an export got, so an interrupted export can continue.
+type ExportToken struct {
+	LogGroupName  string `json:"logGroupName"`
+	FilterPattern string `json:"filterPattern,omitempty"`
+	StartTime     int64  `json:"startTime,omitempty"`
+	EndTime       int64  `json:"endTime,omitempty"`
+	Format        string `json:"format"`
+	NextToken     string `json:"nextToken"`
+	// Part and Offset are the current part file and its size after the last complete page.
+	Part   int   `json:"part"`
+	Offset int64 `json:"offset"`
+	Events int64 `json:"events"`
+}
+
+// ExportResult summarises an export.
+type ExportResult struct {
+	Events int64
+	Files  []string
+}
+
+// Export streams the events of a log group to files as pages arrive.
+func Export(ctx context.Context, opts ExportOptions, c cloudwatchlogsiface.CloudWatchLogsAPI) (*ExportResult, error) {
+	encode, header, err := eventEncoder(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	token := ExportToken{
+		LogGroupName:  opts.LogGroupName,
+		FilterPattern: opts.FilterPattern,
+		Format:        opts.Format,
+	}
+	if !opts.StartTime.IsZero() {
+		token.StartTime = opts.StartTime.UnixNano() / int64(time.Millisecond)
+	}
+	if !opts.EndTime.IsZero() {
+		token.EndTime = opts.EndTime.UnixNano() / int64(time.Millisecond)
+	}
+	if opts.Resume {
+		saved, err := ReadExportToken(opts.ResumeFileName)
+		if err != nil {
+			return nil, err
+		}
+		if saved.LogGroupName != token.LogGroupName || saved.FilterPattern != token.FilterPattern || saved.Format != token.Format {
+			return nil, fmt.Errorf("resume token %s is for log group %s, filter pattern %q and format %s", opts.ResumeFileName, saved.LogGroupName, saved.FilterPattern, saved.Format)
+		}
+		// the time range is taken from the token as relative times have moved since
+		token = *saved
+	}
+
+	w := &partWriter{
+		fileName: opts.FileName,
+		split:    opts.MaxFileSize > 0,
+		maxSize:  opts.MaxFileSize,
+		header:   header,
+	}
+	if err := w.open(token.Part, token.Offset, opts.Resume); err != nil {
+		return nil, err
+	}
+	defer w.close()
+
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(token.LogGroupName),
+	}
+	if token.FilterPattern != "" {
+		input.FilterPattern = aws.String(token.FilterPattern)
+	}
+	if token.StartTime != 0 {
+		input.StartTime = aws.Int64(token.StartTime)
+	}
+	if token.EndTime != 0 {
+		input.EndTime = aws.Int64(token.EndTime)
+	}
+	if token.NextToken != "" {
+		input.NextToken = aws.String(token.NextToken)
+	}
+
+	var writeErr error
+	err = c.FilterLogEventsPagesWithContext(ctx, input, func(output *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		for _, e := range output.Events {
+			record, err := encode(e)
+			if err != nil {
+				writeErr = err
+				return false
+			}
+			if err := w.write(record); err != nil {
+				writeErr = err
+				return false
+			}
+			token.Events++
+		}
+		if err := w.flush(); err != nil {
+			writeErr = err
+			return false
+		}
+
+		token.NextToken = aws.StringValue(output.NextToken)
+		token.Part = w.part
+		token.Offset = w.size
+		if opts.ResumeFileName != "" && token.NextToken != "" {
+			if err := writeExportToken(opts.ResumeFileName, token); err != nil {
+				writeErr = err
+				return false
+			}
+		}
+		return lastPage == false
+	})
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to filter log events: %v", err)
+	}
+	if err := w.close(); err != nil {
+		return nil, err
+	}
+
+	if opts.ResumeFileName != "" {
+		if err := os.Remove(opts.ResumeFileName); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to remove resume token: %v", err)
+		}
+	}
+
+	return &ExportResult{Events: token.Events, Files: w.files}, nil
+}
+
+// ReadExportToken reads a resume token written by Export.
+func ReadExportToken(fileName string) (*ExportToken, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read resume token: %v", err)
+	}
+	var token ExportToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, fmt.Errorf("unable to parse resume token %s: %v", fileName, err)
+	}
+	return &token, nil
+}
+
+// writeExportToken replaces the token file in one rename so an interrupt never leaves half a token.
+func writeExportToken(fileName string, token ExportToken) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("unable to write resume token: %v", err)
+	}
+	if err := os.Rename(tmp, fileName); err != nil {
+		return fmt.Errorf("unable to write resume token: %v", err)
+	}
+	return nil
+}
+
+// eventEncoder returns the encoder for one event in format and the header written at the start of each file.
+func eventEncoder(format string) (func(*cloudwatchlogs.FilteredLogEvent) ([]byte, error), []byte, error) {
+	switch format {
+	case "jsonl":
+		return func(e *cloudwatchlogs.FilteredLogEvent) ([]byte, error) {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return nil, err
+			}
+			return append(b, '\n'), nil
+		}, nil, nil
+	case "csv":
+		header, err := csvRecord("timestamp", "message", "logStreamName")
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(e *cloudwatchlogs.FilteredLogEvent) ([]byte, error) {
+			return csvRecord(strconv.FormatInt(aws.Int64Value(e.Timestamp), 10), aws.StringValue(e.Message), aws.StringValue(e.LogStreamName))
+		}, header, nil
+	case "text":
+		return func(e *cloudwatchlogs.FilteredLogEvent) ([]byte, error) {
+			return []byte(strings.TrimRight(aws.StringValue(e.Message), "\r\n") + "\n"), nil
+		}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown format %s, use jsonl, csv or text", format)
+	}
+}
+
+func csvRecord(values ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(values); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// ExportFileName returns the name of part of fileName, split files get the part number before the extension.
+func ExportFileName(fileName string, part int, split bool) string {
+	if !split {
+		return fileName
+	}
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(fileName, ext), part, ext)
+}
+
+// partWriter writes records to files and starts a new part when a file would grow beyond maxSize.
+type partWriter struct {
+	fileName string
+	split    bool
+	maxSize  int64
+	header   []byte
+
+	part  int
+	size  int64
+	f     *os.File
+	buf   *bufio.Writer
+	files []string
+}
+
+// open opens part, with resume the existing file is truncated to offset to drop an incomplete page.
+func (w *partWriter) open(part int, offset int64, resume bool) error {
+	name := ExportFileName(w.fileName, part, w.split)
+	var f *os.File
+	var err error
+	if resume {
+		f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+		if err == nil {
+			err = f.Truncate(offset)
+		}
+		if err == nil {
+			_, err = f.Seek(offset, io.SeekStart)
+		}
+	} else {
+		f, err = os.Create(name)
+		offset = 0
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", name, err)
+	}
+
+	w.f = f
+	w.buf = bufio.NewWriter(f)
+	w.part = part
+	w.size = offset
+	w.files = append(w.files, name)
+	if offset == 0 && len(w.header) > 0 {
+		n, err := w.buf.Write(w.header)
+		w.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("unable to write %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (w *partWriter) write(record []byte) error {
+	if w.split && w.size > int64(len(w.header)) && w.size+int64(len(record)) > w.maxSize {
+		if err := w.close(); err != nil {
+			return err
+		}
+		if err := w.open(w.part+1, 0, false); err != nil {
+			return err
+		}
+	}
+	n, err := w.buf.Write(record)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %v", w.f.Name(), err)
+	}
+	return nil
+}
+
+func (w *partWriter) flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("unable to write %s: %v", w.f.Name(), err)
+	}
+	return nil
+}
+
+func (w *partWriter) close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.flush()
+	if cerr := w.f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("unable to close %s: %v", w.f.Name(), cerr)
+	}
+	w.f = nil
+	return err
+}
@@ -0,0 +1,150 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportMock serves pages of two events, the token of a page is its index. failAt makes the
+// request for that page fail.
+type exportMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	pages  int
+	failAt int
+	inputs []*cloudwatchlogs.FilterLogEventsInput
+}
+
+func (m *exportMock) FilterLogEventsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.FilterLogEventsInput, f func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool, opts ...request.Option) error {
+	m.inputs = append(m.inputs, input)
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(*input.NextToken)
+	}
+	for ; page < m.pages; page++ {
+		if m.failAt != 0 && page == m.failAt {
+			return fmt.Errorf("ThrottlingException")
+		}
+		output := &cloudwatchlogs.FilterLogEventsOutput{}
+		for i := 0; i < 2; i++ {
+			n := page*2 + i
+			output.Events = append(output.Events, &cloudwatchlogs.FilteredLogEvent{
+				EventId:       aws.String(strconv.Itoa(n)),
+				Timestamp:     aws.Int64(int64(n)),
+				Message:       aws.String(fmt.Sprintf("message %d\n", n)),
+				LogStreamName: aws.String("stream"),
+			})
+		}
+		last := page == m.pages-1
+		if !last {
+			output.NextToken = aws.String(strconv.Itoa(page + 1))
+		}
+		if !f(output, last) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	t.Run("Should write text to a single file", func(t *testing.T) {
+		dir := t.TempDir()
+		result, err := Export(context.Background(), ExportOptions{
+			LogGroupName:   "group",
+			Format:         "text",
+			FileName:       filepath.Join(dir, "out.txt"),
+			ResumeFileName: filepath.Join(dir, "out.txt.resume"),
+		}, &exportMock{pages: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), result.Events)
+		b, _ := os.ReadFile(filepath.Join(dir, "out.txt"))
+		assert.Equal(t, "message 0\nmessage 1\nmessage 2\nmessage 3\n", string(b))
+		_, err = os.Stat(filepath.Join(dir, "out.txt.resume"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should split csv files by size with a header in each part", func(t *testing.T) {
+		dir := t.TempDir()
+		result, err := Export(context.Background(), ExportOptions{
+			LogGroupName: "group",
+			Format:       "csv",
+			FileName:     filepath.Join(dir, "out.csv"),
+			MaxFileSize:  90,
+		}, &exportMock{pages: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "out-00000.csv"), filepath.Join(dir, "out-00001.csv")}, result.Files)
+		for _, name := range result.Files {
+			b, _ := os.ReadFile(name)
+			assert.True(t, strings.HasPrefix(string(b), "timestamp,message,logStreamName\n"))
+			assert.Equal(t, 2, strings.Count(string(b), "stream\n"))
+		}
+	})
+
+	t.Run("Should resume an interrupted export", func(t *testing.T) {
+		dir := t.TempDir()
+		opts := ExportOptions{
+			LogGroupName:   "group",
+			Format:         "jsonl",
+			FileName:       filepath.Join(dir, "out.jsonl"),
+			ResumeFileName: filepath.Join(dir, "out.jsonl.resume"),
+		}
+		_, err := Export(context.Background(), opts, &exportMock{pages: 3, failAt: 2})
+		assert.NotNil(t, err)
+
+		token, err := ReadExportToken(opts.ResumeFileName)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", token.NextToken)
+		assert.Equal(t, int64(4), token.Events)
+
+		// simulate an event written after the token was saved
+		f, _ := os.OpenFile(opts.FileName, os.O_APPEND|os.O_WRONLY, 0644)
+		_, _ = f.WriteString("{\"partial\"")
+		_ = f.Close()
+
+		opts.Resume = true
+		mock := &exportMock{pages: 3}
+		result, err := Export(context.Background(), opts, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "2", *mock.inputs[0].NextToken)
+		assert.Equal(t, int64(6), result.Events)
+		b, _ := os.ReadFile(opts.FileName)
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		assert.Equal(t, 6, len(lines))
+		assert.Contains(t, lines[5], `"EventId":"5"`)
+	})
+
+	t.Run("Should reject resume token for another log group", func(t *testing.T) {
+		dir := t.TempDir()
+		resume := filepath.Join(dir, "out.resume")
+		assert.Nil(t, writeExportToken(resume, ExportToken{LogGroupName: "other", Format: "jsonl", NextToken: "1"}))
+
+		_, err := Export(context.Background(), ExportOptions{
+			LogGroupName:   "group",
+			Format:         "jsonl",
+			FileName:       filepath.Join(dir, "out.jsonl"),
+			ResumeFileName: resume,
+			Resume:         true,
+		}, &exportMock{pages: 1})
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestExportFileName(t *testing.T) {
+	assert.Equal(t, "out.jsonl", ExportFileName("out.jsonl", 3, false))
+	assert.Equal(t, "out-00003.jsonl", ExportFileName("out.jsonl", 3, true))
+	assert.Equal(t, "dir/out-00000", ExportFileName("dir/out", 0, true))
+}
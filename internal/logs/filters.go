@@ -0,0 +1,260 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+// testMetricFilterBatchSize is the number of messages TestMetricFilter accepts in one call.
+const testMetricFilterBatchSize = 50
+
+// SubscriptionFilters returns all subscription filters of a log group.
+func SubscriptionFilters(ctx context.Context, logGroupName string, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]*cloudwatchlogs.SubscriptionFilter, error) {
+	input := &cloudwatchlogs.DescribeSubscriptionFiltersInput{
+		LogGroupName: aws.String(logGroupName),
+	}
+	var filters []*cloudwatchlogs.SubscriptionFilter
+	err := c.DescribeSubscriptionFiltersPagesWithContext(ctx, input, func(output *cloudwatchlogs.DescribeSubscriptionFiltersOutput, lastPage bool) bool {
+		filters = append(filters, output.SubscriptionFilters...)
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe subscription filters of %s: %v", logGroupName, err)
+	}
+	return filters, nil
+}
+
+// SubscriptionFilter is the configuration of a subscription filter.
+type SubscriptionFilter struct {
+	FilterName     string
+	FilterPattern  string
+	DestinationArn string
+	// RoleArn is required for Kinesis and Firehose destinations and not used for Lambda.
+	RoleArn string
+	// Distribution is ByLogStream or Random, only used for Kinesis destinations.
+	Distribution string
+}
+
+// DestinationService returns the service of the destination, lambda, kinesis or firehose.
+func (f SubscriptionFilter) DestinationService() (string, error) {
+	a, err := arn.Parse(f.DestinationArn)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination arn %s: %v", f.DestinationArn, err)
+	}
+	switch a.Service {
+	case "lambda":
+		if f.RoleArn != "" {
+			return "", fmt.Errorf("role arn is not used for lambda destinations")
+		}
+	case "kinesis", "firehose":
+		if f.RoleArn == "" {
+			return "", fmt.Errorf("role arn is required for %s destinations", a.Service)
+		}
+	case "logs":
+		// cross account destination
+	default:
+		return "", fmt.Errorf("unsupported destination %s, use lambda, kinesis or firehose", a.Service)
+	}
+	return a.Service, nil
+}
+
+// PutSubscriptionFilter creates or updates a subscription filter of a log group.
+func PutSubscriptionFilter(ctx context.Context, logGroupName string, f SubscriptionFilter, c cloudwatchlogsiface.CloudWatchLogsAPI) error {
+	input := &cloudwatchlogs.PutSubscriptionFilterInput{
+		LogGroupName:   aws.String(logGroupName),
+		FilterName:     aws.String(f.FilterName),
+		FilterPattern:  aws.String(f.FilterPattern),
+		DestinationArn: aws.String(f.DestinationArn),
+	}
+	if f.RoleArn != "" {
+		input.RoleArn = aws.String(f.RoleArn)
+	}
+	if f.Distribution != "" {
+		input.Distribution = aws.String(f.Distribution)
+	}
+	if _, err := c.PutSubscriptionFilterWithContext(ctx, input); err != nil {
+		return fmt.Errorf("unable to put subscription filter for %s: %v", logGroupName, err)
+	}
+	return nil
+}
+
+// DeleteSubscriptionFilters deletes all subscription filters of a log group and returns their names.
+func DeleteSubscriptionFilters(ctx context.Context, logGroupName string, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]string, error) {
+	filters, err := SubscriptionFilters(ctx, logGroupName, c)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, sf := range filters {
+		_, err := c.DeleteSubscriptionFilterWithContext(ctx, &cloudwatchlogs.DeleteSubscriptionFilterInput{
+			LogGroupName: aws.String(logGroupName),
+			FilterName:   sf.FilterName,
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("unable to delete subscription filter %s of %s: %v", aws.StringValue(sf.FilterName), logGroupName, err)
+		}
+		deleted = append(deleted, aws.StringValue(sf.FilterName))
+	}
+	return deleted, nil
+}
+
+// AllowLogsToInvoke adds a permission to a Lambda function so CloudWatch Logs can deliver events of
+// the log group, logGroupArn is the arn as returned by DescribeLogGroups. An existing permission is
+// left as it is.
+func AllowLogsToInvoke(ctx context.Context, functionArn, logGroupArn string, c lambdaiface.LambdaAPI) error {
+	a, err := arn.Parse(functionArn)
+	if err != nil {
+		return fmt.Errorf("invalid function arn %s: %v", functionArn, err)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(logGroupArn))
+	_, err = c.AddPermissionWithContext(ctx, &lambda.AddPermissionInput{
+		FunctionName:  aws.String(functionArn),
+		StatementId:   aws.String("cloudwatch-logs-" + strconv.FormatUint(h.Sum64(), 16)),
+		Action:        aws.String("lambda:InvokeFunction"),
+		Principal:     aws.String("logs." + a.Region + ".amazonaws.com"),
+		SourceArn:     aws.String(logGroupArn),
+		SourceAccount: aws.String(a.AccountID),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceConflictException {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to allow logs to invoke %s: %v", functionArn, err)
+	}
+	return nil
+}
+
+// WriteSubscriptionFiltersTable writes the subscription filters as a table.
+func WriteSubscriptionFiltersTable(w io.Writer, filters []*cloudwatchlogs.SubscriptionFilter) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOG GROUP\tFILTER NAME\tFILTER PATTERN\tDESTINATION\tDISTRIBUTION")
+	for _, sf := range filters {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", aws.StringValue(sf.LogGroupName), aws.StringValue(sf.FilterName), aws.StringValue(sf.FilterPattern), aws.StringValue(sf.DestinationArn), aws.StringValue(sf.Distribution))
+	}
+	return tw.Flush()
+}
+
+// MetricFilters returns all metric filters of a log group.
+func MetricFilters(ctx context.Context, logGroupName string, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]*cloudwatchlogs.MetricFilter, error) {
+	input := &cloudwatchlogs.DescribeMetricFiltersInput{
+		LogGroupName: aws.String(logGroupName),
+	}
+	var filters []*cloudwatchlogs.MetricFilter
+	err := c.DescribeMetricFiltersPagesWithContext(ctx, input, func(output *cloudwatchlogs.DescribeMetricFiltersOutput, lastPage bool) bool {
+		filters = append(filters, output.MetricFilters...)
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe metric filters of %s: %v", logGroupName, err)
+	}
+	return filters, nil
+}
+
+// MetricFilter is the configuration of a metric filter with a single metric transformation.
+type MetricFilter struct {
+	FilterName      string
+	FilterPattern   string
+	MetricName      string
+	MetricNamespace string
+	// MetricValue is a number or a field selector like $.latency.
+	MetricValue  string
+	DefaultValue *float64
+	Unit         string
+	// Dimensions map dimension names to field selectors, they can not be used with DefaultValue.
+	Dimensions map[string]string
+}
+
+// PutMetricFilter creates or updates a metric filter of a log group.
+func PutMetricFilter(ctx context.Context, logGroupName string, f MetricFilter, c cloudwatchlogsiface.CloudWatchLogsAPI) error {
+	transformation := &cloudwatchlogs.MetricTransformation{
+		MetricName:      aws.String(f.MetricName),
+		MetricNamespace: aws.String(f.MetricNamespace),
+		MetricValue:     aws.String(f.MetricValue),
+		DefaultValue:    f.DefaultValue,
+	}
+	if f.Unit != "" {
+		transformation.Unit = aws.String(f.Unit)
+	}
+	if len(f.Dimensions) > 0 {
+		transformation.Dimensions = aws.StringMap(f.Dimensions)
+	}
+	_, err := c.PutMetricFilterWithContext(ctx, &cloudwatchlogs.PutMetricFilterInput{
+		LogGroupName:          aws.String(logGroupName),
+		FilterName:            aws.String(f.FilterName),
+		FilterPattern:         aws.String(f.FilterPattern),
+		MetricTransformations: []*cloudwatchlogs.MetricTransformation{transformation},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to put metric filter for %s: %v", logGroupName, err)
+	}
+	return nil
+}
+
+// DeleteMetricFilter deletes a metric filter of a log group, it returns false when the log group
+// has no filter with the name.
+func DeleteMetricFilter(ctx context.Context, logGroupName, filterName string, c cloudwatchlogsiface.CloudWatchLogsAPI) (bool, error) {
+	_, err := c.DeleteMetricFilterWithContext(ctx, &cloudwatchlogs.DeleteMetricFilterInput{
+		LogGroupName: aws.String(logGroupName),
+		FilterName:   aws.String(filterName),
+	})
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to delete metric filter %s of %s: %v", filterName, logGroupName, err)
+	}
+	return true, nil
+}
+
+// TestMetricFilter tests the filter pattern against messages in batches and returns the matches,
+// line numbers of the matches refer to messages.
+func TestMetricFilter(ctx context.Context, filterPattern string, messages []string, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]*cloudwatchlogs.MetricFilterMatchRecord, error) {
+	var matches []*cloudwatchlogs.MetricFilterMatchRecord
+	for i := 0; i < len(messages); i += testMetricFilterBatchSize {
+		batch := messages[i:min(i+testMetricFilterBatchSize, len(messages))]
+		output, err := c.TestMetricFilterWithContext(ctx, &cloudwatchlogs.TestMetricFilterInput{
+			FilterPattern:    aws.String(filterPattern),
+			LogEventMessages: aws.StringSlice(batch),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to test metric filter: %v", err)
+		}
+		for _, m := range output.Matches {
+			m.EventNumber = aws.Int64(aws.Int64Value(m.EventNumber) + int64(i))
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// WriteMetricFiltersTable writes the metric filters as a table.
+func WriteMetricFiltersTable(w io.Writer, filters []*cloudwatchlogs.MetricFilter) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOG GROUP\tFILTER NAME\tFILTER PATTERN\tMETRIC\tVALUE\tDIMENSIONS")
+	for _, mf := range filters {
+		for _, t := range mf.MetricTransformations {
+			var dimensions []string
+			for k, v := range t.Dimensions {
+				dimensions = append(dimensions, k+"="+aws.StringValue(v))
+			}
+			sort.Strings(dimensions)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s/%s\t%s\t%s\n", aws.StringValue(mf.LogGroupName), aws.StringValue(mf.FilterName), aws.StringValue(mf.FilterPattern), aws.StringValue(t.MetricNamespace), aws.StringValue(t.MetricName), aws.StringValue(t.MetricValue), strings.Join(dimensions, ","))
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,171 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type filtersMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	deleted     []string
+	putMetric   *cloudwatchlogs.PutMetricFilterInput
+	testBatches []int
+}
+
+func (m *filtersMock) DescribeSubscriptionFiltersPagesWithContext(ctx aws.Context, input *cloudwatchlogs.DescribeSubscriptionFiltersInput, f func(*cloudwatchlogs.DescribeSubscriptionFiltersOutput, bool) bool, opts ...request.Option) error {
+	if f(&cloudwatchlogs.DescribeSubscriptionFiltersOutput{SubscriptionFilters: []*cloudwatchlogs.SubscriptionFilter{{FilterName: aws.String("a")}}}, false) {
+		f(&cloudwatchlogs.DescribeSubscriptionFiltersOutput{SubscriptionFilters: []*cloudwatchlogs.SubscriptionFilter{{FilterName: aws.String("b")}}}, true)
+	}
+	return nil
+}
+
+func (m *filtersMock) DeleteSubscriptionFilterWithContext(ctx aws.Context, input *cloudwatchlogs.DeleteSubscriptionFilterInput, opts ...request.Option) (*cloudwatchlogs.DeleteSubscriptionFilterOutput, error) {
+	m.deleted = append(m.deleted, *input.FilterName)
+	return &cloudwatchlogs.DeleteSubscriptionFilterOutput{}, nil
+}
+
+func (m *filtersMock) PutMetricFilterWithContext(ctx aws.Context, input *cloudwatchlogs.PutMetricFilterInput, opts ...request.Option) (*cloudwatchlogs.PutMetricFilterOutput, error) {
+	m.putMetric = input
+	return &cloudwatchlogs.PutMetricFilterOutput{}, nil
+}
+
+func (m *filtersMock) DeleteMetricFilterWithContext(ctx aws.Context, input *cloudwatchlogs.DeleteMetricFilterInput, opts ...request.Option) (*cloudwatchlogs.DeleteMetricFilterOutput, error) {
+	if *input.LogGroupName == "without-filter" {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &cloudwatchlogs.DeleteMetricFilterOutput{}, nil
+}
+
+func (m *filtersMock) TestMetricFilterWithContext(ctx aws.Context, input *cloudwatchlogs.TestMetricFilterInput, opts ...request.Option) (*cloudwatchlogs.TestMetricFilterOutput, error) {
+	m.testBatches = append(m.testBatches, len(input.LogEventMessages))
+	var matches []*cloudwatchlogs.MetricFilterMatchRecord
+	for i, message := range input.LogEventMessages {
+		if *message == "ERROR" {
+			matches = append(matches, &cloudwatchlogs.MetricFilterMatchRecord{EventNumber: aws.Int64(int64(i + 1)), EventMessage: message})
+		}
+	}
+	return &cloudwatchlogs.TestMetricFilterOutput{Matches: matches}, nil
+}
+
+type lambdaMock struct {
+	lambdaiface.LambdaAPI
+	input *lambda.AddPermissionInput
+	err   error
+}
+
+func (m *lambdaMock) AddPermissionWithContext(ctx aws.Context, input *lambda.AddPermissionInput, opts ...request.Option) (*lambda.AddPermissionOutput, error) {
+	m.input = input
+	return &lambda.AddPermissionOutput{}, m.err
+}
+
+func TestDeleteSubscriptionFilters(t *testing.T) {
+	mock := &filtersMock{}
+	deleted, err := DeleteSubscriptionFilters(context.Background(), "group", mock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, deleted)
+	assert.Equal(t, []string{"a", "b"}, mock.deleted)
+}
+
+func TestSubscriptionFilterDestinationService(t *testing.T) {
+	for _, tc := range []struct {
+		filter  SubscriptionFilter
+		service string
+	}{
+		{SubscriptionFilter{DestinationArn: "arn:aws:lambda:eu-west-1:123456789012:function:shipper"}, "lambda"},
+		{SubscriptionFilter{DestinationArn: "arn:aws:kinesis:eu-west-1:123456789012:stream/logs", RoleArn: "arn:aws:iam::123456789012:role/logs"}, "kinesis"},
+		{SubscriptionFilter{DestinationArn: "arn:aws:firehose:eu-west-1:123456789012:deliverystream/logs"}, ""},
+		{SubscriptionFilter{DestinationArn: "arn:aws:sqs:eu-west-1:123456789012:queue"}, ""},
+		{SubscriptionFilter{DestinationArn: "shipper"}, ""},
+	} {
+		t.Run("Should validate "+tc.filter.DestinationArn, func(t *testing.T) {
+			service, err := tc.filter.DestinationService()
+
+			assert.Equal(t, tc.service, service)
+			assert.Equal(t, tc.service == "", err != nil)
+		})
+	}
+}
+
+func TestAllowLogsToInvoke(t *testing.T) {
+	functionArn := "arn:aws:lambda:eu-west-1:123456789012:function:shipper"
+	logGroupArn := "arn:aws:logs:eu-west-1:123456789012:log-group:/aws/lambda/orders:*"
+
+	t.Run("Should add permission for the log group", func(t *testing.T) {
+		mock := &lambdaMock{}
+		err := AllowLogsToInvoke(context.Background(), functionArn, logGroupArn, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "logs.eu-west-1.amazonaws.com", *mock.input.Principal)
+		assert.Equal(t, logGroupArn, *mock.input.SourceArn)
+		assert.Equal(t, "123456789012", *mock.input.SourceAccount)
+	})
+
+	t.Run("Should ignore existing permission", func(t *testing.T) {
+		mock := &lambdaMock{err: awserr.New(lambda.ErrCodeResourceConflictException, "exists", nil)}
+
+		assert.Nil(t, AllowLogsToInvoke(context.Background(), functionArn, logGroupArn, mock))
+	})
+
+	t.Run("Should return other errors", func(t *testing.T) {
+		mock := &lambdaMock{err: fmt.Errorf("AccessDenied")}
+
+		assert.NotNil(t, AllowLogsToInvoke(context.Background(), functionArn, logGroupArn, mock))
+	})
+}
+
+func TestPutMetricFilter(t *testing.T) {
+	mock := &filtersMock{}
+	err := PutMetricFilter(context.Background(), "group", MetricFilter{
+		FilterName:      "errors",
+		FilterPattern:   `{ $.level = "ERROR" }`,
+		MetricName:      "Errors",
+		MetricNamespace: "Orders",
+		MetricValue:     "1",
+		Dimensions:      map[string]string{"Service": "$.service"},
+	}, mock)
+
+	assert.Nil(t, err)
+	transformation := mock.putMetric.MetricTransformations[0]
+	assert.Equal(t, "Errors", *transformation.MetricName)
+	assert.Nil(t, transformation.Unit)
+	assert.Equal(t, "$.service", *transformation.Dimensions["Service"])
+}
+
+func TestDeleteMetricFilter(t *testing.T) {
+	deleted, err := DeleteMetricFilter(context.Background(), "group", "errors", &filtersMock{})
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = DeleteMetricFilter(context.Background(), "without-filter", "errors", &filtersMock{})
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+}
+
+func TestTestMetricFilter(t *testing.T) {
+	var messages []string
+	for i := 0; i < 120; i++ {
+		messages = append(messages, "INFO")
+	}
+	messages[10] = "ERROR"
+	messages[110] = "ERROR"
+	mock := &filtersMock{}
+
+	matches, err := TestMetricFilter(context.Background(), "ERROR", messages, mock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int{50, 50, 20}, mock.testBatches)
+	assert.Equal(t, 2, len(matches))
+	assert.Equal(t, int64(11), *matches[0].EventNumber)
+	assert.Equal(t, int64(111), *matches[1].EventNumber)
+}
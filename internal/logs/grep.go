@@ -0,0 +1,595 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// Record is a log event with the fields of its message when the message is a JSON object.
+// Besides the message fields @timestamp, @logStream and @message are available.
+type Record struct {
+	Timestamp     int64
+	LogStreamName string
+	Message       string
+	Fields        map[string]any
+}
+
+// NewRecord parses message, fields stay nil for messages that are not JSON objects.
+func NewRecord(timestamp int64, logStreamName, message string) *Record {
+	r := &Record{Timestamp: timestamp, LogStreamName: logStreamName, Message: message}
+	trimmed := strings.TrimSpace(message)
+	if strings.HasPrefix(trimmed, "{") {
+		d := json.NewDecoder(strings.NewReader(trimmed))
+		d.UseNumber()
+		var fields map[string]any
+		if err := d.Decode(&fields); err == nil {
+			r.Fields = fields
+		}
+	}
+	return r
+}
+
+// Value returns the value of field, nested fields are separated by dots, e.g. http.status.
+func (r *Record) Value(field string) (any, bool) {
+	switch field {
+	case "@timestamp":
+		return time.Unix(0, r.Timestamp*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano), true
+	case "@logStream":
+		return r.LogStreamName, true
+	case "@message":
+		return strings.TrimRight(r.Message, "\r\n"), true
+	}
+	if r.Fields == nil {
+		return nil, false
+	}
+	if v, ok := r.Fields[field]; ok {
+		return v, true
+	}
+	var v any = r.Fields
+	for _, part := range strings.Split(field, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// String returns the value of field as text, objects and arrays as JSON and missing fields as "".
+func (r *Record) String(field string) string {
+	v, ok := r.Value(field)
+	if !ok || v == nil {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// Expression selects records.
+type Expression interface {
+	Match(r *Record) bool
+}
+
+type matchAll struct{}
+
+func (matchAll) Match(*Record) bool { return true }
+
+type andExpression struct{ left, right Expression }
+
+func (e andExpression) Match(r *Record) bool { return e.left.Match(r) && e.right.Match(r) }
+
+type orExpression struct{ left, right Expression }
+
+func (e orExpression) Match(r *Record) bool { return e.left.Match(r) || e.right.Match(r) }
+
+type notExpression struct{ e Expression }
+
+func (e notExpression) Match(r *Record) bool { return !e.e.Match(r) }
+
+// condition compares a field with a value, without an operator it checks that the field exists.
+// Missing fields never match a comparison.
+type condition struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (c condition) Match(r *Record) bool {
+	v, ok := r.Value(c.field)
+	if !ok {
+		return false
+	}
+	if c.op == "" {
+		return true
+	}
+	s := r.String(c.field)
+	switch c.op {
+	case "~":
+		return c.re.MatchString(s)
+	case "!~":
+		return !c.re.MatchString(s)
+	}
+
+	var cmp int
+	a, aErr := numberValue(v)
+	b, bErr := strconv.ParseFloat(c.value, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(s, c.value)
+	}
+
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+func numberValue(v any) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number")
+}
+
+// ParseExpression parses expressions like `level=ERROR and latency_ms>500`. Conditions are
+// field, op and value with op one of = != > >= < <= ~ (regexp) !~, or a field name alone to
+// check it exists. They are combined with and, or, not and parentheses. Values with spaces are
+// quoted. An empty expression matches every record.
+func ParseExpression(s string) (Expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return matchAll{}, nil
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s in expression", p.tokens[p.pos].text)
+	}
+	return e, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+var operators = []string{"!=", ">=", "<=", "!~", "=", ">", "<", "~"}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case unicode.IsSpace(rune(ch)):
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, token{text: string(ch)})
+			i++
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(s[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in expression")
+			}
+			tokens = append(tokens, token{text: s[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op != "" {
+				tokens = append(tokens, token{text: op})
+				i += len(op)
+				continue
+			}
+			start := i
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && !strings.ContainsRune("()=!<>~\"'", rune(s[i])) {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("unexpected %c in expression", ch)
+			}
+			tokens = append(tokens, token{text: s[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) keyword(k string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, k) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Expression, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpression{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expression, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpression{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Expression, error) {
+	if p.keyword("not") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{e}, nil
+	}
+	if p.keyword("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("missing ) in expression")
+		}
+		return e, nil
+	}
+	return p.condition()
+}
+
+func (p *parser) condition() (Expression, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	field := p.tokens[p.pos]
+	if !field.quoted && (field.text == ")" || isOperator(field.text)) {
+		return nil, fmt.Errorf("expected field name, got %s", field.text)
+	}
+	p.pos++
+	c := condition{field: field.text}
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && isOperator(p.tokens[p.pos].text) {
+		c.op = p.tokens[p.pos].text
+		p.pos++
+		if p.pos >= len(p.tokens) {
+			return nil, fmt.Errorf("missing value for %s", c.field)
+		}
+		c.value = p.tokens[p.pos].text
+		p.pos++
+		if c.op == "~" || c.op == "!~" {
+			re, err := regexp.Compile(c.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regexp for %s: %v", c.field, err)
+			}
+			c.re = re
+		}
+	}
+	return c, nil
+}
+
+func isOperator(s string) bool {
+	for _, o := range operators {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+// FileFormat returns the format of a file exported by flow from its extension: jsonl, csv or text.
+func FileFormat(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".jsonl", ".json", ".ndjson":
+		return "jsonl"
+	case ".csv":
+		return "csv"
+	default:
+		return "text"
+	}
+}
+
+// ReadRecords reads records from a file exported by write-to-file or get-log-events. jsonl lines
+// that are not exported events are used as the message.
+func ReadRecords(r io.Reader, format string, fn func(*Record) error) error {
+	switch format {
+	case "csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		for line := 0; ; line++ {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("unable to read csv: %v", err)
+			}
+			if line == 0 && len(row) > 0 && row[0] == "timestamp" {
+				continue
+			}
+			if len(row) < 3 {
+				return fmt.Errorf("expected timestamp, message and logStreamName in csv line %d", line+1)
+			}
+			ts, _ := strconv.ParseInt(row[0], 10, 64)
+			if err := fn(NewRecord(ts, row[2], row[1])); err != nil {
+				return err
+			}
+		}
+	case "jsonl", "text":
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for s.Scan() {
+			line := s.Text()
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			record := NewRecord(0, "", line)
+			if format == "jsonl" {
+				var e cloudwatchlogs.FilteredLogEvent
+				if err := json.Unmarshal([]byte(line), &e); err == nil && e.Message != nil {
+					record = NewRecord(aws.Int64Value(e.Timestamp), aws.StringValue(e.LogStreamName), *e.Message)
+				}
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return s.Err()
+	default:
+		return fmt.Errorf("unknown format %s, use jsonl, csv or text", format)
+	}
+}
+
+// ReadLogGroupRecords calls fn for the events of a log group between startTime and endTime,
+// filterPattern is applied by CloudWatch Logs before the records are parsed.
+func ReadLogGroupRecords(ctx context.Context, logGroupName, filterPattern string, startTime, endTime time.Time, c cloudwatchlogsiface.CloudWatchLogsAPI, fn func(*Record) error) error {
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(logGroupName),
+		StartTime:    aws.Int64(startTime.UnixNano() / int64(time.Millisecond)),
+		EndTime:      aws.Int64(endTime.UnixNano() / int64(time.Millisecond)),
+	}
+	if filterPattern != "" {
+		input.FilterPattern = aws.String(filterPattern)
+	}
+	var fnErr error
+	err := c.FilterLogEventsPagesWithContext(ctx, input, func(output *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		for _, e := range output.Events {
+			if fnErr = fn(NewRecord(aws.Int64Value(e.Timestamp), aws.StringValue(e.LogStreamName), aws.StringValue(e.Message))); fnErr != nil {
+				return false
+			}
+		}
+		return lastPage == false
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to filter log events for %s: %v", logGroupName, err)
+	}
+	return nil
+}
+
+// RecordWriter writes records as table or csv columns of the projected fields, or as jsonl
+// messages.
+type RecordWriter struct {
+	format string
+	fields []string
+	tw     *tabwriter.Writer
+	cw     *csv.Writer
+	w      io.Writer
+}
+
+// NewRecordWriter returns a writer for format table, csv or jsonl. Table and csv need fields.
+func NewRecordWriter(w io.Writer, format string, fields []string) (*RecordWriter, error) {
+	rw := &RecordWriter{format: format, fields: fields, w: w}
+	switch format {
+	case "table":
+		rw.tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(rw.tw, strings.Join(fields, "\t"))
+	case "csv":
+		rw.cw = csv.NewWriter(w)
+		if err := rw.cw.Write(fields); err != nil {
+			return nil, err
+		}
+	case "jsonl":
+	default:
+		return nil, fmt.Errorf("unknown output %s, use table, csv or jsonl", format)
+	}
+	return rw, nil
+}
+
+// Write writes one record.
+func (rw *RecordWriter) Write(r *Record) error {
+	switch rw.format {
+	case "table":
+		values := make([]string, len(rw.fields))
+		for i, f := range rw.fields {
+			values[i] = strings.ReplaceAll(r.String(f), "\n", " ")
+		}
+		_, err := fmt.Fprintln(rw.tw, strings.Join(values, "\t"))
+		return err
+	case "csv":
+		values := make([]string, len(rw.fields))
+		for i, f := range rw.fields {
+			values[i] = r.String(f)
+		}
+		return rw.cw.Write(values)
+	default:
+		if len(rw.fields) == 0 {
+			_, err := fmt.Fprintln(rw.w, strings.TrimRight(r.Message, "\r\n"))
+			return err
+		}
+		projected := map[string]any{}
+		for _, f := range rw.fields {
+			if v, ok := r.Value(f); ok {
+				projected[f] = v
+			}
+		}
+		b, err := json.Marshal(projected)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(rw.w, string(b))
+		return err
+	}
+}
+
+// Flush writes buffered records.
+func (rw *RecordWriter) Flush() error {
+	switch rw.format {
+	case "table":
+		return rw.tw.Flush()
+	case "csv":
+		rw.cw.Flush()
+		return rw.cw.Error()
+	}
+	return nil
+}
+
+// Count is the number of records with a value of the counted field.
+type Count struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Counter counts records by the value of a field.
+type Counter struct {
+	Field  string
+	counts map[string]int64
+}
+
+// Add counts r, records without the field are counted under an empty value.
+func (c *Counter) Add(r *Record) {
+	if c.counts == nil {
+		c.counts = map[string]int64{}
+	}
+	c.counts[r.String(c.Field)]++
+}
+
+// Counts returns the counts, highest first.
+func (c *Counter) Counts() []Count {
+	var counts []Count
+	for v, n := range c.counts {
+		counts = append(counts, Count{Value: v, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Value < counts[j].Value
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	return counts
+}
+
+// WriteCounts writes the counts as a table, csv or jsonl.
+func (c *Counter) WriteCounts(w io.Writer, format string) error {
+	counts := c.Counts()
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\tcount\n", c.Field)
+		for _, count := range counts {
+			fmt.Fprintf(tw, "%s\t%d\n", strings.ReplaceAll(count.Value, "\n", " "), count.Count)
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{c.Field, "count"})
+		for _, count := range counts {
+			_ = cw.Write([]string{count.Value, strconv.FormatInt(count.Count, 10)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, count := range counts {
+			if err := enc.Encode(count); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output %s, use table, csv or jsonl", format)
+	}
+}
@@ -0,0 +1,124 @@
+package logs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpression(t *testing.T) {
+	r := NewRecord(0, "stream-1", `{"level":"ERROR","latency_ms":750,"http":{"status":503,"path":"/orders"},"msg":"upstream timed out"}`)
+
+	for _, tc := range []struct {
+		expression string
+		match      bool
+	}{
+		{"", true},
+		{"level=ERROR and latency_ms>500", true},
+		{"level=ERROR and latency_ms>1000", false},
+		{"level=INFO or http.status>=500", true},
+		{"not level=ERROR", false},
+		{"level!=INFO", true},
+		{"(level=INFO or level=WARN) and latency_ms>500", false},
+		{"msg~'timed out$'", true},
+		{`msg="upstream timed out"`, true},
+		{"http.path!~^/health", true},
+		{"user_id", false},
+		{"user_id!=1", false},
+		{"@logStream=stream-1", true},
+		{"latency_ms<=750 AND latency_ms>=750", true},
+	} {
+		t.Run("Should evaluate "+tc.expression, func(t *testing.T) {
+			e, err := ParseExpression(tc.expression)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.match, e.Match(r))
+		})
+	}
+
+	t.Run("Should not parse fields of plain messages", func(t *testing.T) {
+		e, err := ParseExpression("level=ERROR")
+
+		assert.Nil(t, err)
+		assert.False(t, e.Match(NewRecord(0, "", "level=ERROR plain text")))
+	})
+
+	for _, expression := range []string{"level=", "(level=ERROR", "level=ERROR and", "msg='open", "msg~(", "=ERROR"} {
+		t.Run("Should reject "+expression, func(t *testing.T) {
+			_, err := ParseExpression(expression)
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	t.Run("Should read exported jsonl and plain json lines", func(t *testing.T) {
+		input := `{"EventId":"1","LogStreamName":"s","Message":"{\"level\":\"ERROR\"}","Timestamp":1000}
+{"level":"INFO"}
+`
+		var records []*Record
+		err := ReadRecords(strings.NewReader(input), "jsonl", func(r *Record) error {
+			records = append(records, r)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(records))
+		assert.Equal(t, "ERROR", records[0].String("level"))
+		assert.Equal(t, "s", records[0].LogStreamName)
+		assert.Equal(t, int64(1000), records[0].Timestamp)
+		assert.Equal(t, "INFO", records[1].String("level"))
+	})
+
+	t.Run("Should read exported csv", func(t *testing.T) {
+		input := "timestamp,message,logStreamName\n1000,\"{\"\"level\"\":\"\"WARN\"\"}\",s\n"
+		var records []*Record
+		err := ReadRecords(strings.NewReader(input), "csv", func(r *Record) error {
+			records = append(records, r)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(records))
+		assert.Equal(t, "WARN", records[0].String("level"))
+	})
+}
+
+func TestRecordWriter(t *testing.T) {
+	r := NewRecord(0, "s", `{"level":"ERROR","latency_ms":750,"tags":["a"]}`)
+
+	t.Run("Should project fields to csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewRecordWriter(&buf, "csv", []string{"level", "latency_ms", "tags", "missing"})
+		assert.Nil(t, err)
+		assert.Nil(t, w.Write(r))
+		assert.Nil(t, w.Flush())
+
+		assert.Equal(t, "level,latency_ms,tags,missing\nERROR,750,\"[\"\"a\"\"]\",\n", buf.String())
+	})
+
+	t.Run("Should write messages as jsonl without fields", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewRecordWriter(&buf, "jsonl", nil)
+		assert.Nil(t, err)
+		assert.Nil(t, w.Write(r))
+
+		assert.Equal(t, `{"level":"ERROR","latency_ms":750,"tags":["a"]}`+"\n", buf.String())
+	})
+}
+
+func TestCounter(t *testing.T) {
+	c := &Counter{Field: "level"}
+	for _, m := range []string{`{"level":"ERROR"}`, `{"level":"INFO"}`, `{"level":"ERROR"}`, `plain`} {
+		c.Add(NewRecord(0, "", m))
+	}
+
+	assert.Equal(t, []Count{{"ERROR", 2}, {"", 1}, {"INFO", 1}}, c.Counts())
+
+	var buf bytes.Buffer
+	assert.Nil(t, c.WriteCounts(&buf, "csv"))
+	assert.Equal(t, "level,count\nERROR,2\n,1\nINFO,1\n", buf.String())
+}
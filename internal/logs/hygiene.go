@@ -0,0 +1,261 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// RetentionDays are the values accepted by PutRetentionPolicy.
+var RetentionDays = []int64{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+// ValidRetention reports whether days is accepted by PutRetentionPolicy.
+func ValidRetention(days int64) bool {
+	for _, d := range RetentionDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+// RetentionChange is a log group whose retention is changed, From is nil when it never expires.
+type RetentionChange struct {
+	LogGroupName string `json:"logGroupName"`
+	From         *int64 `json:"from"`
+	To           int64  `json:"to"`
+}
+
+// PlanRetention returns the log groups that need a change to get retention days, with onlyIfUnset
+// groups with a retention are left alone.
+func PlanRetention(logGroups []*cloudwatchlogs.LogGroup, days int64, onlyIfUnset bool) []RetentionChange {
+	var changes []RetentionChange
+	for _, lg := range logGroups {
+		if lg.RetentionInDays != nil && (onlyIfUnset || *lg.RetentionInDays == days) {
+			continue
+		}
+		changes = append(changes, RetentionChange{
+			LogGroupName: *lg.LogGroupName,
+			From:         lg.RetentionInDays,
+			To:           days,
+		})
+	}
+	return changes
+}
+
+// PruneOptions configures PlanPrune.
+type PruneOptions struct {
+	// Before is the cutoff, only log groups and streams created before it are pruned.
+	Before time.Time
+	// Empty prunes only log groups and streams without any events, otherwise the ones without
+	// events since Before are pruned.
+	Empty bool
+	// Streams also prunes streams of log groups that are kept.
+	Streams bool
+}
+
+// PruneCandidate is a log group, or a log stream when LogStreamName is set, that can be deleted.
+type PruneCandidate struct {
+	LogGroupName  string     `json:"logGroupName"`
+	LogStreamName string     `json:"logStreamName,omitempty"`
+	CreationTime  time.Time  `json:"creationTime"`
+	LastEventTime *time.Time `json:"lastEventTime,omitempty"`
+	StoredBytes   int64      `json:"storedBytes"`
+}
+
+// PlanPrune returns the log groups, and with opts.Streams the log streams, that are empty or stale.
+func PlanPrune(ctx context.Context, logGroups []*cloudwatchlogs.LogGroup, opts PruneOptions, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]PruneCandidate, error) {
+	before := opts.Before.UnixNano() / int64(time.Millisecond)
+	prunable := func(creationTime, lastEvent int64) bool {
+		if creationTime >= before {
+			return false
+		}
+		if opts.Empty {
+			return lastEvent == 0
+		}
+		return lastEvent < before
+	}
+
+	var candidates []PruneCandidate
+	for _, lg := range logGroups {
+		input := &cloudwatchlogs.DescribeLogStreamsInput{
+			LogGroupName: lg.LogGroupName,
+			Descending:   aws.Bool(true),
+			OrderBy:      aws.String(cloudwatchlogs.OrderByLastEventTime),
+		}
+		if !opts.Streams {
+			// the first stream has the latest event, that is all a log group needs
+			input.Limit = aws.Int64(1)
+		}
+		var logStreams []*cloudwatchlogs.LogStream
+		err := c.DescribeLogStreamsPagesWithContext(ctx, input, func(output *cloudwatchlogs.DescribeLogStreamsOutput, lastPage bool) bool {
+			logStreams = append(logStreams, output.LogStreams...)
+			return opts.Streams && lastPage == false
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe log streams of %s: %v", *lg.LogGroupName, err)
+		}
+
+		groupLastEvent := int64(0)
+		for _, ls := range logStreams {
+			groupLastEvent = max(groupLastEvent, streamLastEvent(ls))
+		}
+		if aws.Int64Value(lg.StoredBytes) > 0 && groupLastEvent == 0 {
+			// stored bytes without known events, treat as not empty
+			groupLastEvent = aws.Int64Value(lg.CreationTime)
+		}
+		if prunable(aws.Int64Value(lg.CreationTime), groupLastEvent) {
+			candidates = append(candidates, pruneCandidate(*lg.LogGroupName, "", aws.Int64Value(lg.CreationTime), groupLastEvent, aws.Int64Value(lg.StoredBytes)))
+			continue
+		}
+		if !opts.Streams {
+			continue
+		}
+		for _, ls := range logStreams {
+			if prunable(aws.Int64Value(ls.CreationTime), streamLastEvent(ls)) {
+				candidates = append(candidates, pruneCandidate(*lg.LogGroupName, *ls.LogStreamName, aws.Int64Value(ls.CreationTime), streamLastEvent(ls), aws.Int64Value(ls.StoredBytes)))
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// streamLastEvent returns the time of the last event in ms, the last event time is updated
+// eventually so the ingestion time is used when it is later.
+func streamLastEvent(ls *cloudwatchlogs.LogStream) int64 {
+	return max(aws.Int64Value(ls.LastEventTimestamp), aws.Int64Value(ls.LastIngestionTime))
+}
+
+func pruneCandidate(logGroupName, logStreamName string, creationTime, lastEvent, storedBytes int64) PruneCandidate {
+	p := PruneCandidate{
+		LogGroupName:  logGroupName,
+		LogStreamName: logStreamName,
+		CreationTime:  msToTime(creationTime).UTC(),
+		StoredBytes:   storedBytes,
+	}
+	if lastEvent != 0 {
+		t := msToTime(lastEvent).UTC()
+		p.LastEventTime = &t
+	}
+	return p
+}
+
+// Prune deletes the candidates, log groups that are already gone are skipped.
+func Prune(ctx context.Context, candidates []PruneCandidate, c cloudwatchlogsiface.CloudWatchLogsAPI) error {
+	for _, p := range candidates {
+		var err error
+		if p.LogStreamName == "" {
+			_, err = c.DeleteLogGroupWithContext(ctx, &cloudwatchlogs.DeleteLogGroupInput{
+				LogGroupName: aws.String(p.LogGroupName),
+			})
+		} else {
+			_, err = c.DeleteLogStreamWithContext(ctx, &cloudwatchlogs.DeleteLogStreamInput{
+				LogGroupName:  aws.String(p.LogGroupName),
+				LogStreamName: aws.String(p.LogStreamName),
+			})
+		}
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to delete %s: %v", p.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Name returns the log group name, followed by the stream name for streams.
+func (p PruneCandidate) Name() string {
+	if p.LogStreamName == "" {
+		return p.LogGroupName
+	}
+	return p.LogGroupName + " " + p.LogStreamName
+}
+
+// WritePruneTable writes the candidates as a table.
+func WritePruneTable(w io.Writer, candidates []PruneCandidate) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOG GROUP\tLOG STREAM\tCREATED\tLAST EVENT\tSTORED BYTES")
+	for _, p := range candidates {
+		lastEvent := "-"
+		if p.LastEventTime != nil {
+			lastEvent = p.LastEventTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", p.LogGroupName, p.LogStreamName, p.CreationTime.Format(time.RFC3339), lastEvent, p.StoredBytes)
+	}
+	return tw.Flush()
+}
+
+// DefaultPricePerGB is the monthly storage price per GB in us-east-1.
+const DefaultPricePerGB = 0.03
+
+// LogGroupCost is the estimated monthly storage cost of a log group.
+type LogGroupCost struct {
+	LogGroupName    string  `json:"logGroupName"`
+	RetentionInDays *int64  `json:"retentionInDays"`
+	StoredBytes     int64   `json:"storedBytes"`
+	MonthlyCost     float64 `json:"monthlyCost"`
+}
+
+// Cost estimates the monthly storage cost from the stored bytes, most expensive first. It does
+// not include ingestion, which is usually the larger part of the bill.
+func Cost(logGroups []*cloudwatchlogs.LogGroup, pricePerGB float64) []LogGroupCost {
+	var costs []LogGroupCost
+	for _, lg := range logGroups {
+		storedBytes := aws.Int64Value(lg.StoredBytes)
+		costs = append(costs, LogGroupCost{
+			LogGroupName:    *lg.LogGroupName,
+			RetentionInDays: lg.RetentionInDays,
+			StoredBytes:     storedBytes,
+			MonthlyCost:     float64(storedBytes) / 1e+9 * pricePerGB,
+		})
+	}
+	sort.SliceStable(costs, func(i, j int) bool {
+		return costs[i].StoredBytes > costs[j].StoredBytes
+	})
+	return costs
+}
+
+// WriteCost writes costs as a table with a total, or as json.
+func WriteCost(w io.Writer, costs []LogGroupCost, format string) error {
+	switch format {
+	case "json":
+		jsonBytes, err := json.Marshal(costs)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(jsonBytes))
+		return err
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "LOG GROUP\tRETENTION\tSTORED MB\tMONTHLY COST")
+		var storedBytes int64
+		var total float64
+		for _, lc := range costs {
+			retention := "never expire"
+			if lc.RetentionInDays != nil {
+				retention = strconv.FormatInt(*lc.RetentionInDays, 10) + " days"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.1f\t$%.2f\n", lc.LogGroupName, retention, float64(lc.StoredBytes)/1e+6, lc.MonthlyCost)
+			storedBytes += lc.StoredBytes
+			total += lc.MonthlyCost
+		}
+		fmt.Fprintf(tw, "TOTAL\t\t%.1f\t$%.2f\n", float64(storedBytes)/1e+6, total)
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output %s, use table or json", format)
+	}
+}
+
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException
+}
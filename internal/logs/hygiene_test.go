@@ -0,0 +1,153 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type pruneMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	streams        map[string][]*cloudwatchlogs.LogStream
+	deletedGroups  []string
+	deletedStreams []string
+}
+
+func (m *pruneMock) DescribeLogStreamsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.DescribeLogStreamsInput, f func(*cloudwatchlogs.DescribeLogStreamsOutput, bool) bool, opts ...request.Option) error {
+	streams := m.streams[*input.LogGroupName]
+	if input.Limit != nil && int(*input.Limit) < len(streams) {
+		streams = streams[:*input.Limit]
+	}
+	f(&cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: streams}, true)
+	return nil
+}
+
+func (m *pruneMock) DeleteLogGroupWithContext(ctx aws.Context, input *cloudwatchlogs.DeleteLogGroupInput, opts ...request.Option) (*cloudwatchlogs.DeleteLogGroupOutput, error) {
+	if *input.LogGroupName == "gone" {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	m.deletedGroups = append(m.deletedGroups, *input.LogGroupName)
+	return &cloudwatchlogs.DeleteLogGroupOutput{}, nil
+}
+
+func (m *pruneMock) DeleteLogStreamWithContext(ctx aws.Context, input *cloudwatchlogs.DeleteLogStreamInput, opts ...request.Option) (*cloudwatchlogs.DeleteLogStreamOutput, error) {
+	m.deletedStreams = append(m.deletedStreams, *input.LogStreamName)
+	return &cloudwatchlogs.DeleteLogStreamOutput{}, nil
+}
+
+func TestPlanRetention(t *testing.T) {
+	logGroups := []*cloudwatchlogs.LogGroup{
+		{LogGroupName: aws.String("unset")},
+		{LogGroupName: aws.String("same"), RetentionInDays: aws.Int64(30)},
+		{LogGroupName: aws.String("other"), RetentionInDays: aws.Int64(365)},
+	}
+
+	t.Run("Should change groups with another retention", func(t *testing.T) {
+		changes := PlanRetention(logGroups, 30, false)
+
+		assert.Equal(t, 2, len(changes))
+		assert.Equal(t, "unset", changes[0].LogGroupName)
+		assert.Nil(t, changes[0].From)
+		assert.Equal(t, int64(365), *changes[1].From)
+	})
+
+	t.Run("Should change only groups without retention", func(t *testing.T) {
+		changes := PlanRetention(logGroups, 30, true)
+
+		assert.Equal(t, []RetentionChange{{LogGroupName: "unset", To: 30}}, changes)
+	})
+
+	t.Run("Should validate retention days", func(t *testing.T) {
+		assert.True(t, ValidRetention(14))
+		assert.False(t, ValidRetention(15))
+	})
+}
+
+func TestPlanPrune(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(daysAgo int) *int64 {
+		return aws.Int64(now.Add(-time.Duration(daysAgo)*24*time.Hour).UnixNano() / int64(time.Millisecond))
+	}
+	logGroups := []*cloudwatchlogs.LogGroup{
+		{LogGroupName: aws.String("empty-old"), CreationTime: ms(60), StoredBytes: aws.Int64(0)},
+		{LogGroupName: aws.String("empty-new"), CreationTime: ms(1), StoredBytes: aws.Int64(0)},
+		{LogGroupName: aws.String("stale"), CreationTime: ms(90), StoredBytes: aws.Int64(100)},
+		{LogGroupName: aws.String("active"), CreationTime: ms(90), StoredBytes: aws.Int64(100)},
+	}
+	mock := &pruneMock{streams: map[string][]*cloudwatchlogs.LogStream{
+		"stale": {{LogStreamName: aws.String("s1"), CreationTime: ms(90), LastEventTimestamp: ms(45)}},
+		"active": {
+			{LogStreamName: aws.String("today"), CreationTime: ms(90), LastEventTimestamp: ms(0)},
+			{LogStreamName: aws.String("old"), CreationTime: ms(90), LastEventTimestamp: ms(40)},
+			{LogStreamName: aws.String("never"), CreationTime: ms(50)},
+		},
+	}}
+	before := now.Add(-30 * 24 * time.Hour)
+
+	t.Run("Should plan groups without events since the cutoff", func(t *testing.T) {
+		candidates, err := PlanPrune(context.Background(), logGroups, PruneOptions{Before: before}, mock)
+
+		assert.Nil(t, err)
+		var names []string
+		for _, p := range candidates {
+			names = append(names, p.Name())
+		}
+		assert.Equal(t, []string{"empty-old", "stale"}, names)
+	})
+
+	t.Run("Should plan empty groups and streams", func(t *testing.T) {
+		candidates, err := PlanPrune(context.Background(), logGroups, PruneOptions{Before: before, Empty: true, Streams: true}, mock)
+
+		assert.Nil(t, err)
+		var names []string
+		for _, p := range candidates {
+			names = append(names, p.Name())
+		}
+		assert.Equal(t, []string{"empty-old", "active never"}, names)
+	})
+
+	t.Run("Should plan stale streams of active groups", func(t *testing.T) {
+		candidates, err := PlanPrune(context.Background(), logGroups[3:], PruneOptions{Before: before, Streams: true}, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(candidates))
+		assert.Equal(t, "old", candidates[0].LogStreamName)
+		assert.NotNil(t, candidates[0].LastEventTime)
+		assert.Nil(t, candidates[1].LastEventTime)
+	})
+
+	t.Run("Should delete candidates and skip missing groups", func(t *testing.T) {
+		err := Prune(context.Background(), []PruneCandidate{{LogGroupName: "stale"}, {LogGroupName: "gone"}, {LogGroupName: "active", LogStreamName: "old"}}, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"stale"}, mock.deletedGroups)
+		assert.Equal(t, []string{"old"}, mock.deletedStreams)
+	})
+}
+
+func TestCost(t *testing.T) {
+	costs := Cost([]*cloudwatchlogs.LogGroup{
+		{LogGroupName: aws.String("small"), StoredBytes: aws.Int64(1e+9), RetentionInDays: aws.Int64(7)},
+		{LogGroupName: aws.String("large"), StoredBytes: aws.Int64(100e+9)},
+	}, DefaultPricePerGB)
+
+	assert.Equal(t, "large", costs[0].LogGroupName)
+	assert.InDelta(t, 3.0, costs[0].MonthlyCost, 0.0001)
+	assert.InDelta(t, 0.03, costs[1].MonthlyCost, 0.0001)
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCost(&buf, costs, "table"))
+	assert.Equal(t, `LOG GROUP  RETENTION     STORED MB  MONTHLY COST
+large      never expire  100000.0   $3.00
+small      7 days        1000.0     $0.03
+TOTAL                    101000.0   $3.03
+`, buf.String())
+}
@@ -0,0 +1,234 @@
+package logs
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// QueryRowLimit is the maximum number of rows Logs Insights returns for a query.
+const QueryRowLimit = 10000
+
+// QueryLogGroupLimit is the maximum number of log groups a single Logs Insights query can search.
+const QueryLogGroupLimit = 50
+
+// QueryOptions configures RunQuery.
+type QueryOptions struct {
+	QueryString string
+	StartTime   time.Time
+	EndTime     time.Time
+	// Limit is the maximum number of rows, 0 uses the query or service default.
+	Limit int64
+	// PollInterval is the delay between GetQueryResults calls.
+	PollInterval time.Duration
+}
+
+// QueryResult is the outcome of a Logs Insights query. Rows hold the values of Fields, the
+// internal @ptr field is left out.
+type QueryResult struct {
+	QueryId        string              `json:"queryId"`
+	Status         string              `json:"status"`
+	Fields         []string            `json:"fields"`
+	Rows           []map[string]string `json:"rows"`
+	RecordsMatched float64             `json:"recordsMatched"`
+	RecordsScanned float64             `json:"recordsScanned"`
+	BytesScanned   float64             `json:"bytesScanned"`
+}
+
+// LimitReached reports whether the result was truncated by the Logs Insights row limit.
+func (r *QueryResult) LimitReached() bool {
+	return len(r.Rows) >= QueryRowLimit
+}
+
+// RunQuery starts a Logs Insights query on logGroupNames and polls until it is done. The query is
+// stopped when ctx is cancelled.
+func RunQuery(ctx context.Context, logGroupNames []string, opts QueryOptions, c cloudwatchlogsiface.CloudWatchLogsAPI) (*QueryResult, error) {
+	if len(logGroupNames) > QueryLogGroupLimit {
+		return nil, fmt.Errorf("query can search up to %d log groups, got %d, use a narrower log-group-name-prefix", QueryLogGroupLimit, len(logGroupNames))
+	}
+	input := &cloudwatchlogs.StartQueryInput{
+		LogGroupNames: aws.StringSlice(logGroupNames),
+		QueryString:   aws.String(opts.QueryString),
+		StartTime:     aws.Int64(opts.StartTime.Unix()),
+		EndTime:       aws.Int64(opts.EndTime.Unix()),
+	}
+	if opts.Limit > 0 {
+		input.Limit = aws.Int64(opts.Limit)
+	}
+	started, err := c.StartQueryWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start query: %v", err)
+	}
+	queryId := aws.StringValue(started.QueryId)
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		output, err := c.GetQueryResultsWithContext(ctx, &cloudwatchlogs.GetQueryResultsInput{QueryId: aws.String(queryId)})
+		if err != nil {
+			if ctx.Err() != nil {
+				stopQuery(queryId, c)
+			}
+			return nil, fmt.Errorf("unable to get query results: %v", err)
+		}
+
+		switch status := aws.StringValue(output.Status); status {
+		case cloudwatchlogs.QueryStatusComplete:
+			return queryResult(queryId, output), nil
+		case cloudwatchlogs.QueryStatusFailed, cloudwatchlogs.QueryStatusCancelled, cloudwatchlogs.QueryStatusTimeout:
+			return nil, fmt.Errorf("query %s finished with status %s", queryId, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			stopQuery(queryId, c)
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// stopQuery stops a running query, it uses its own context as the caller's one is already done.
+func stopQuery(queryId string, c cloudwatchlogsiface.CloudWatchLogsAPI) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = c.StopQueryWithContext(ctx, &cloudwatchlogs.StopQueryInput{QueryId: aws.String(queryId)})
+}
+
+func queryResult(queryId string, output *cloudwatchlogs.GetQueryResultsOutput) *QueryResult {
+	result := &QueryResult{
+		QueryId: queryId,
+		Status:  aws.StringValue(output.Status),
+		Rows:    []map[string]string{},
+	}
+	if s := output.Statistics; s != nil {
+		result.RecordsMatched = aws.Float64Value(s.RecordsMatched)
+		result.RecordsScanned = aws.Float64Value(s.RecordsScanned)
+		result.BytesScanned = aws.Float64Value(s.BytesScanned)
+	}
+
+	known := map[string]bool{}
+	for _, fields := range output.Results {
+		row := map[string]string{}
+		for _, f := range fields {
+			name := aws.StringValue(f.Field)
+			if name == "@ptr" {
+				continue
+			}
+			if !known[name] {
+				known[name] = true
+				result.Fields = append(result.Fields, name)
+			}
+			row[name] = aws.StringValue(f.Value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result
+}
+
+// WriteQueryResult writes the rows of r in format table, json, jsonl or csv.
+func WriteQueryResult(w io.Writer, r *QueryResult, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(r.Fields, "\t"))
+		for _, row := range r.Rows {
+			values := make([]string, len(r.Fields))
+			for i, f := range r.Fields {
+				values[i] = strings.ReplaceAll(row[f], "\n", " ")
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+		return tw.Flush()
+	case "json":
+		jsonBytes, err := json.MarshalIndent(r.Rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(jsonBytes))
+		return err
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, row := range r.Rows {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(r.Fields); err != nil {
+			return err
+		}
+		for _, row := range r.Rows {
+			values := make([]string, len(r.Fields))
+			for i, f := range r.Fields {
+				values[i] = row[f]
+			}
+			if err := cw.Write(values); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown output %s, use table, json, jsonl or csv", format)
+	}
+}
+
+// SavedQuery is a named Logs Insights query kept in a local file so it can be shared and rerun.
+type SavedQuery struct {
+	Description        string   `json:"description,omitempty"`
+	QueryString        string   `json:"queryString"`
+	LogGroupNames      []string `json:"logGroupNames,omitempty"`
+	LogGroupNamePrefix string   `json:"logGroupNamePrefix,omitempty"`
+	// Start and End accept the formats of ParseTime.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// LoadSavedQueries reads saved queries from fileName, a missing file has no queries.
+func LoadSavedQueries(fileName string) (map[string]SavedQuery, error) {
+	queries := map[string]SavedQuery{}
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return queries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read saved queries: %v", err)
+	}
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, fmt.Errorf("unable to parse saved queries in %s: %v", fileName, err)
+	}
+	return queries, nil
+}
+
+// SaveQuery adds or replaces the query with name in fileName.
+func SaveQuery(fileName, name string, q SavedQuery) error {
+	queries, err := LoadSavedQueries(fileName)
+	if err != nil {
+		return err
+	}
+	queries[name] = q
+	b, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write saved queries: %v", err)
+	}
+	return nil
+}
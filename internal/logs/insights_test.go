@@ -0,0 +1,156 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type insightsMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	start    *cloudwatchlogs.StartQueryInput
+	statuses []string
+	stopped  bool
+}
+
+func (m *insightsMock) StartQueryWithContext(ctx aws.Context, input *cloudwatchlogs.StartQueryInput, opts ...request.Option) (*cloudwatchlogs.StartQueryOutput, error) {
+	m.start = input
+	return &cloudwatchlogs.StartQueryOutput{QueryId: aws.String("q-1")}, nil
+}
+
+func (m *insightsMock) GetQueryResultsWithContext(ctx aws.Context, input *cloudwatchlogs.GetQueryResultsInput, opts ...request.Option) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	status := m.statuses[0]
+	if len(m.statuses) > 1 {
+		m.statuses = m.statuses[1:]
+	}
+	output := &cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(status)}
+	if status == cloudwatchlogs.QueryStatusComplete {
+		output.Statistics = &cloudwatchlogs.QueryStatistics{
+			BytesScanned:   aws.Float64(2048),
+			RecordsMatched: aws.Float64(2),
+			RecordsScanned: aws.Float64(10),
+		}
+		output.Results = [][]*cloudwatchlogs.ResultField{
+			{
+				{Field: aws.String("@timestamp"), Value: aws.String("2024-01-01 00:00:00.000")},
+				{Field: aws.String("@message"), Value: aws.String("a,b")},
+				{Field: aws.String("@ptr"), Value: aws.String("x")},
+			},
+			{
+				{Field: aws.String("@timestamp"), Value: aws.String("2024-01-01 00:00:01.000")},
+				{Field: aws.String("level"), Value: aws.String("ERROR")},
+			},
+		}
+	}
+	return output, nil
+}
+
+func (m *insightsMock) StopQueryWithContext(ctx aws.Context, input *cloudwatchlogs.StopQueryInput, opts ...request.Option) (*cloudwatchlogs.StopQueryOutput, error) {
+	m.stopped = true
+	return &cloudwatchlogs.StopQueryOutput{}, nil
+}
+
+func TestRunQuery(t *testing.T) {
+	opts := QueryOptions{
+		QueryString:  "fields @timestamp, @message",
+		StartTime:    time.Unix(100, 0),
+		EndTime:      time.Unix(200, 0),
+		PollInterval: time.Millisecond,
+	}
+
+	t.Run("Should poll until the query completes", func(t *testing.T) {
+		mock := &insightsMock{statuses: []string{cloudwatchlogs.QueryStatusScheduled, cloudwatchlogs.QueryStatusRunning, cloudwatchlogs.QueryStatusComplete}}
+		result, err := RunQuery(context.Background(), []string{"group"}, opts, mock)
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(100), *mock.start.StartTime)
+		assert.Equal(t, int64(200), *mock.start.EndTime)
+		assert.Nil(t, mock.start.Limit)
+		assert.Equal(t, []string{"@timestamp", "@message", "level"}, result.Fields)
+		assert.Equal(t, 2, len(result.Rows))
+		assert.Equal(t, float64(2048), result.BytesScanned)
+		assert.False(t, result.LimitReached())
+	})
+
+	t.Run("Should fail on too many log groups", func(t *testing.T) {
+		mock := &insightsMock{}
+		logGroupNames := make([]string, QueryLogGroupLimit+1)
+		_, err := RunQuery(context.Background(), logGroupNames, opts, mock)
+
+		assert.EqualError(t, err, "query can search up to 50 log groups, got 51, use a narrower log-group-name-prefix")
+		assert.Nil(t, mock.start)
+	})
+
+	t.Run("Should fail on failed query", func(t *testing.T) {
+		mock := &insightsMock{statuses: []string{cloudwatchlogs.QueryStatusFailed}}
+		_, err := RunQuery(context.Background(), []string{"group"}, opts, mock)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should stop query when cancelled", func(t *testing.T) {
+		mock := &insightsMock{statuses: []string{cloudwatchlogs.QueryStatusRunning}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := RunQuery(ctx, []string{"group"}, opts, mock)
+
+		assert.NotNil(t, err)
+		assert.True(t, mock.stopped)
+	})
+}
+
+func TestWriteQueryResult(t *testing.T) {
+	result := &QueryResult{
+		Fields: []string{"@timestamp", "@message"},
+		Rows: []map[string]string{
+			{"@timestamp": "t1", "@message": "a,b"},
+			{"@timestamp": "t2"},
+		},
+	}
+
+	t.Run("Should write csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, WriteQueryResult(&buf, result, "csv"))
+		assert.Equal(t, "@timestamp,@message\nt1,\"a,b\"\nt2,\n", buf.String())
+	})
+
+	t.Run("Should write jsonl", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, WriteQueryResult(&buf, result, "jsonl"))
+		assert.Equal(t, "{\"@message\":\"a,b\",\"@timestamp\":\"t1\"}\n{\"@timestamp\":\"t2\"}\n", buf.String())
+	})
+
+	t.Run("Should write table", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, WriteQueryResult(&buf, result, "table"))
+		assert.Equal(t, "@timestamp  @message\nt1          a,b\nt2          \n", buf.String())
+	})
+
+	t.Run("Should reject unknown format", func(t *testing.T) {
+		assert.NotNil(t, WriteQueryResult(&bytes.Buffer{}, result, "xml"))
+	})
+}
+
+func TestSavedQueries(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "queries.json")
+
+	queries, err := LoadSavedQueries(fileName)
+	assert.Nil(t, err)
+	assert.Empty(t, queries)
+
+	assert.Nil(t, SaveQuery(fileName, "errors", SavedQuery{QueryString: "filter level = 'ERROR'", Start: "1h"}))
+	assert.Nil(t, SaveQuery(fileName, "slow", SavedQuery{QueryString: "filter latency > 500"}))
+
+	queries, err = LoadSavedQueries(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(queries))
+	assert.Equal(t, "1h", queries["errors"].Start)
+}
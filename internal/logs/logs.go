@@ -1,14 +1,15 @@
 package logs
 
 import (
+	"container/heap"
 	"context"
 	"encoding/csv"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
 	"github.com/pkg/errors"
-	"log"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -93,57 +94,233 @@ type LogEvent struct {
 	LogStreamName *string
 }
 
-func WriteLogEvents(ctx context.Context, logGroupName string, startTime, endTime time.Time, c cloudwatchlogsiface.CloudWatchLogsAPI, writer *csv.Writer) error {
-	describeLogStreamsInput := cloudwatchlogs.DescribeLogStreamsInput{
+// DefaultConcurrency is the number of log streams read at the same time by ReadLogEvents.
+const DefaultConcurrency = 8
+
+// lastEventTimeSlack accounts for the last event time of a stream being updated eventually, it
+// can lag up to an hour behind ingestion.
+const lastEventTimeSlack = time.Hour
+
+// LogStreams returns the streams of a log group that may have events between startTime and endTime.
+func LogStreams(ctx context.Context, logGroupName string, startTime, endTime time.Time, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]*cloudwatchlogs.LogStream, error) {
+	start := startTime.Add(-lastEventTimeSlack).UnixNano() / int64(time.Millisecond)
+	end := endTime.UnixNano() / int64(time.Millisecond)
+	input := cloudwatchlogs.DescribeLogStreamsInput{
 		LogGroupName: &logGroupName,
 		Descending:   aws.Bool(true),
 		OrderBy:      aws.String(cloudwatchlogs.OrderByLastEventTime),
 	}
 	var logStreams []*cloudwatchlogs.LogStream
-	err := c.DescribeLogStreamsPagesWithContext(ctx, &describeLogStreamsInput, func(output *cloudwatchlogs.DescribeLogStreamsOutput, lastPage bool) bool {
+	err := c.DescribeLogStreamsPagesWithContext(ctx, &input, func(output *cloudwatchlogs.DescribeLogStreamsOutput, lastPage bool) bool {
 		for _, ls := range output.LogStreams {
+			last := max(aws.Int64Value(ls.LastEventTimestamp), aws.Int64Value(ls.LastIngestionTime))
+			if last < start {
+				// streams are ordered by last event time, the rest are older
+				return false
+			}
+			if aws.Int64Value(ls.FirstEventTimestamp) > end {
+				continue
+			}
 			logStreams = append(logStreams, ls)
 		}
 		return lastPage == false
 	})
 	if err != nil {
-		return errors.Wrap(err, "failed to describe log streams")
+		return nil, errors.Wrap(err, "failed to describe log streams")
+	}
+
+	return logStreams, nil
+}
+
+// ReadLogEvents reads the events of a log group between startTime and endTime and calls fn for
+// each of them in timestamp order. Streams are read concurrently, at most concurrency requests at
+// a time, and merged as their pages arrive, so only about a page per stream is held in memory.
+func ReadLogEvents(ctx context.Context, logGroupName string, startTime, endTime time.Time, concurrency int, c cloudwatchlogsiface.CloudWatchLogsAPI, fn func(*LogEvent) error) error {
+	logStreams, err := LogStreams(ctx, logGroupName, startTime, endTime, c)
+	if err != nil {
+		return err
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	// stops the readers when merging returns early
+	defer cancel()
+
+	sem := make(chan struct{}, concurrency)
+	streams := make([]*streamPages, len(logStreams))
+	for i, ls := range logStreams {
+		streams[i] = &streamPages{pages: make(chan []*LogEvent)}
+		wg.Add(1)
+		go func(s *streamPages, logStreamName *string) {
+			defer wg.Done()
+			s.read(ctx, logGroupName, logStreamName, startTime, endTime, sem, c)
+		}(streams[i], ls.LogStreamName)
 	}
 
-	for _, logStream := range logStreams {
-		input := &cloudwatchlogs.GetLogEventsInput{
-			LogGroupName:  &logGroupName,
-			LogStreamName: logStream.LogStreamName,
-			StartFromHead: aws.Bool(true),
-			StartTime:     aws.Int64(startTime.UnixNano() / int64(time.Millisecond)),
-			EndTime:       aws.Int64(endTime.UnixNano() / int64(time.Millisecond)),
+	return mergeLogEvents(streams, fn)
+}
+
+// streamPages holds the pages of a single log stream, err is set before pages is closed.
+type streamPages struct {
+	pages chan []*LogEvent
+	err   error
+}
+
+// read sends the non empty pages of a log stream until it is read or ctx is done. sem is held
+// only while a page is requested, so a stream waiting for the merge does not block the others.
+func (s *streamPages) read(ctx context.Context, logGroupName string, logStreamName *string, startTime, endTime time.Time, sem chan struct{}, c cloudwatchlogsiface.CloudWatchLogsAPI) {
+	defer close(s.pages)
+	acquire := func() bool {
+		select {
+		case sem <- struct{}{}:
+			return true
+		case <-ctx.Done():
+			return false
 		}
-		var logEvents []*LogEvent
-		err := c.GetLogEventsPagesWithContext(ctx, input, func(output *cloudwatchlogs.GetLogEventsOutput, lastPage bool) bool {
+	}
+	if !acquire() {
+		s.err = ctx.Err()
+		return
+	}
+
+	input := &cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  &logGroupName,
+		LogStreamName: logStreamName,
+		StartFromHead: aws.Bool(true),
+		StartTime:     aws.Int64(startTime.UnixNano() / int64(time.Millisecond)),
+		EndTime:       aws.Int64(endTime.UnixNano() / int64(time.Millisecond)),
+	}
+	held, stopped := true, false
+	err := c.GetLogEventsPagesWithContext(ctx, input, func(output *cloudwatchlogs.GetLogEventsOutput, lastPage bool) bool {
+		<-sem
+		held = false
+		if len(output.Events) > 0 {
+			page := make([]*LogEvent, 0, len(output.Events))
 			for _, le := range output.Events {
-				print(".")
-				event := LogEvent{
+				page = append(page, &LogEvent{
 					Timestamp:     le.Timestamp,
 					Message:       le.Message,
-					LogStreamName: logStream.LogStreamName,
-				}
-				logEvents = append(logEvents, &event)
+					LogStreamName: logStreamName,
+				})
 			}
-
-			for _, le := range logEvents {
-				if err := writer.Write([]string{strconv.FormatInt(*le.Timestamp, 10), *le.Message, *le.LogStreamName}); err != nil {
-					// not much we can do here unfortunately
-					log.Fatalf("failed to write to csv: %v", err)
-				}
+			select {
+			case s.pages <- page:
+			case <-ctx.Done():
+				stopped = true
+				return false
 			}
-			writer.Flush()
-			logEvents = nil
-			return lastPage == false
-		})
+		}
+		if lastPage {
+			return false
+		}
+		held = acquire()
+		stopped = !held
+		return held
+	})
+	if held {
+		<-sem
+	}
+	if err == nil && stopped {
+		err = ctx.Err()
+	}
+	if err != nil {
+		s.err = errors.Wrapf(err, "failed to get log events for %s", aws.StringValue(logStreamName))
+	}
+}
+
+// next returns the next page of the stream, nil once the stream is read.
+func (s *streamPages) next() ([]*LogEvent, error) {
+	page, ok := <-s.pages
+	if !ok {
+		return nil, s.err
+	}
+	return page, nil
+}
+
+// mergeLogEvents calls fn for the events of all streams in timestamp order. Each stream is
+// already ordered, so a heap of the stream heads is enough.
+func mergeLogEvents(streams []*streamPages, fn func(*LogEvent) error) error {
+	h := &eventHeap{}
+	for i, s := range streams {
+		page, err := s.next()
+		if err != nil {
+			return err
+		}
+		if page != nil {
+			h.heads = append(h.heads, eventHead{stream: i, events: page})
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		head := &h.heads[0]
+		if err := fn(head.events[head.pos]); err != nil {
+			return err
+		}
+		head.pos++
+		if head.pos < len(head.events) {
+			heap.Fix(h, 0)
+			continue
+		}
+		page, err := streams[head.stream].next()
 		if err != nil {
 			return err
 		}
+		if page == nil {
+			heap.Pop(h)
+			continue
+		}
+		head.events, head.pos = page, 0
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+type eventHead struct {
+	stream int
+	events []*LogEvent
+	pos    int
+}
+
+type eventHeap struct {
+	heads []eventHead
+}
+
+func (h *eventHeap) Len() int { return len(h.heads) }
+
+func (h *eventHeap) Less(i, j int) bool {
+	a := aws.Int64Value(h.heads[i].events[h.heads[i].pos].Timestamp)
+	b := aws.Int64Value(h.heads[j].events[h.heads[j].pos].Timestamp)
+	if a == b {
+		return h.heads[i].stream < h.heads[j].stream
+	}
+	return a < b
+}
+
+func (h *eventHeap) Swap(i, j int) { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *eventHeap) Push(x any) { h.heads = append(h.heads, x.(eventHead)) }
+
+func (h *eventHeap) Pop() any {
+	old := h.heads
+	x := old[len(old)-1]
+	h.heads = old[:len(old)-1]
+	return x
+}
+
+// WriteLogEvents writes the events of a log group between startTime and endTime to writer as csv
+// rows of timestamp, message and log stream name, in timestamp order.
+func WriteLogEvents(ctx context.Context, logGroupName string, startTime, endTime time.Time, concurrency int, c cloudwatchlogsiface.CloudWatchLogsAPI, writer *csv.Writer) error {
+	err := ReadLogEvents(ctx, logGroupName, startTime, endTime, concurrency, c, func(le *LogEvent) error {
+		return writer.Write([]string{strconv.FormatInt(*le.Timestamp, 10), *le.Message, *le.LogStreamName})
+	})
+	if err != nil {
+		return err
 	}
 
-	return err
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "failed to write csv")
 }
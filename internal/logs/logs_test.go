@@ -1,13 +1,19 @@
 package logs
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
 	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 )
 
 type cloudwatchLogsMock struct {
@@ -60,3 +66,127 @@ func TestSummary(t *testing.T) {
 	assert.Equal(t, int64(2000), *logGroupSummary.StoredGigaBytes)
 	assert.Equal(t, int64(2), *logGroupSummary.StoredTeraBytes)
 }
+
+type logEventsMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	mu      sync.Mutex
+	streams []*cloudwatchlogs.LogStream
+	events  map[string][]int64
+	fetched []string
+	fail    string
+	// gate holds back the second page of every stream until it is closed
+	gate chan struct{}
+}
+
+func (m *logEventsMock) DescribeLogStreamsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.DescribeLogStreamsInput, f func(*cloudwatchlogs.DescribeLogStreamsOutput, bool) bool, opts ...request.Option) error {
+	f(&cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: m.streams}, true)
+	return nil
+}
+
+func (m *logEventsMock) GetLogEventsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.GetLogEventsInput, f func(*cloudwatchlogs.GetLogEventsOutput, bool) bool, opts ...request.Option) error {
+	m.mu.Lock()
+	m.fetched = append(m.fetched, *input.LogStreamName)
+	m.mu.Unlock()
+	if *input.LogStreamName == m.fail {
+		return fmt.Errorf("ThrottlingException")
+	}
+	var events []*cloudwatchlogs.OutputLogEvent
+	for _, ts := range m.events[*input.LogStreamName] {
+		events = append(events, &cloudwatchlogs.OutputLogEvent{
+			Timestamp: aws.Int64(ts),
+			Message:   aws.String(fmt.Sprintf("%s-%d", *input.LogStreamName, ts)),
+		})
+	}
+	// two pages to check that events are collected across pages
+	half := len(events) / 2
+	if !f(&cloudwatchlogs.GetLogEventsOutput{Events: events[:half]}, false) {
+		return nil
+	}
+	if m.gate != nil {
+		select {
+		case <-m.gate:
+		case <-time.After(time.Second):
+			return fmt.Errorf("second page of %s requested before any event was merged", *input.LogStreamName)
+		}
+	}
+	f(&cloudwatchlogs.GetLogEventsOutput{Events: events[half:]}, true)
+	return nil
+}
+
+func logStream(name string, first, last int64) *cloudwatchlogs.LogStream {
+	return &cloudwatchlogs.LogStream{
+		LogStreamName:       aws.String(name),
+		FirstEventTimestamp: aws.Int64(first),
+		LastEventTimestamp:  aws.Int64(last),
+	}
+}
+
+func TestWriteLogEvents(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	startTime := time.Unix(0, 10*hour*int64(time.Millisecond))
+	endTime := time.Unix(0, 11*hour*int64(time.Millisecond))
+
+	t.Run("Should merge streams in timestamp order and skip streams outside the window", func(t *testing.T) {
+		mock := &logEventsMock{
+			streams: []*cloudwatchlogs.LogStream{
+				logStream("future", 12*hour, 12*hour),
+				logStream("a", 10*hour, 11*hour),
+				logStream("b", 10*hour, 11*hour),
+				logStream("old", 1, 2),
+			},
+			events: map[string][]int64{
+				"a": {10*hour + 1, 10*hour + 3, 10*hour + 3, 10*hour + 6},
+				"b": {10*hour + 2, 10*hour + 3, 10*hour + 5},
+			},
+		}
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		err := WriteLogEvents(context.Background(), "group", startTime, endTime, 2, mock, writer)
+
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"a", "b"}, mock.fetched)
+		var streams []string
+		var timestamps []string
+		records, _ := csv.NewReader(&buf).ReadAll()
+		for _, r := range records {
+			timestamps = append(timestamps, r[0])
+			streams = append(streams, r[2])
+		}
+		assert.Equal(t, []string{"a", "b", "a", "a", "b", "b", "a"}, streams)
+		assert.True(t, sort.StringsAreSorted(timestamps))
+	})
+
+	t.Run("Should merge pages as they arrive", func(t *testing.T) {
+		mock := &logEventsMock{
+			streams: []*cloudwatchlogs.LogStream{logStream("a", 10*hour, 11*hour), logStream("b", 10*hour, 11*hour)},
+			events: map[string][]int64{
+				"a": {10*hour + 1, 10*hour + 4},
+				"b": {10*hour + 2, 10*hour + 3},
+			},
+			gate: make(chan struct{}),
+		}
+		var timestamps []int64
+		err := ReadLogEvents(context.Background(), "group", startTime, endTime, 2, mock, func(e *LogEvent) error {
+			if len(timestamps) == 0 {
+				close(mock.gate)
+			}
+			timestamps = append(timestamps, *e.Timestamp)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{10*hour + 1, 10*hour + 2, 10*hour + 3, 10*hour + 4}, timestamps)
+	})
+
+	t.Run("Should return error of a failed stream", func(t *testing.T) {
+		mock := &logEventsMock{
+			streams: []*cloudwatchlogs.LogStream{logStream("a", 10*hour, 11*hour), logStream("b", 10*hour, 11*hour)},
+			events:  map[string][]int64{"a": {10 * hour}},
+			fail:    "b",
+		}
+		err := WriteLogEvents(context.Background(), "group", startTime, endTime, 1, mock, csv.NewWriter(&bytes.Buffer{}))
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "ThrottlingException")
+	})
+}
@@ -0,0 +1,200 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxTemplateLength keeps templates of long messages, e.g. stack traces, readable.
+const maxTemplateLength = 300
+
+var (
+	uuidPattern      = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?|\d{4}[-/]\d{2}[-/]\d{2}|\d{2}:\d{2}:\d{2}(\.\d+)?`)
+	ipPattern        = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}(:\d+)?\b`)
+	hexPattern       = regexp.MustCompile(`\b(0x)?[0-9a-fA-F]{8,}\b`)
+	numberPattern    = regexp.MustCompile(`(\B-)?\d+(\.\d+)?`) // also in words like "125ms", a minus only before a number
+	digitPattern     = regexp.MustCompile(`\d`)
+	whitespace       = regexp.MustCompile(`\s+`)
+)
+
+// Template masks the variable parts of a message, uuids, timestamps, ips, hex strings and numbers,
+// so messages that differ only in those get the same template.
+func Template(message string) string {
+	t := whitespace.ReplaceAllString(strings.TrimSpace(message), " ")
+	// timestamps go before numbers as they contain them
+	t = uuidPattern.ReplaceAllString(t, "<uuid>")
+	t = timestampPattern.ReplaceAllString(t, "<ts>")
+	t = ipPattern.ReplaceAllString(t, "<ip>")
+	t = hexPattern.ReplaceAllStringFunc(t, func(s string) string {
+		// words like "deadbeef" or "accepted" are not ids
+		if digitPattern.MatchString(s) {
+			return "<hex>"
+		}
+		return s
+	})
+	t = numberPattern.ReplaceAllString(t, "<num>")
+	if len(t) > maxTemplateLength {
+		t = strings.ToValidUTF8(t[:maxTemplateLength], "") + "..."
+	}
+	return t
+}
+
+// Pattern is a template with the number of messages that have it.
+type Pattern struct {
+	Template  string    `json:"template"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Example   string    `json:"example"`
+}
+
+// Patterns groups messages by template.
+type Patterns struct {
+	patterns map[string]*Pattern
+}
+
+// Add counts the message under its template, timestamp is in ms.
+func (p *Patterns) Add(timestamp int64, message string) {
+	if p.patterns == nil {
+		p.patterns = map[string]*Pattern{}
+	}
+	t := Template(message)
+	ts := msToTime(timestamp).UTC()
+	pattern, ok := p.patterns[t]
+	if !ok {
+		pattern = &Pattern{Template: t, FirstSeen: ts, LastSeen: ts, Example: strings.TrimRight(message, "\r\n")}
+		p.patterns[t] = pattern
+	}
+	pattern.Count++
+	if ts.Before(pattern.FirstSeen) {
+		pattern.FirstSeen = ts
+	}
+	if ts.After(pattern.LastSeen) {
+		pattern.LastSeen = ts
+	}
+}
+
+// List returns the patterns, most frequent first.
+func (p *Patterns) List() []Pattern {
+	var patterns []Pattern
+	for _, pattern := range p.patterns {
+		patterns = append(patterns, *pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count == patterns[j].Count {
+			return patterns[i].Template < patterns[j].Template
+		}
+		return patterns[i].Count > patterns[j].Count
+	})
+	return patterns
+}
+
+// Pattern change statuses of ComparePatterns.
+const (
+	PatternNew       = "new"
+	PatternIncreased = "increased"
+	PatternDecreased = "decreased"
+	PatternSame      = "same"
+	PatternGone      = "gone"
+)
+
+// PatternChange is a pattern of the current window compared with a baseline window.
+type PatternChange struct {
+	Pattern
+	Status        string `json:"status"`
+	BaselineCount int    `json:"baselineCount"`
+}
+
+// ComparePatterns compares the patterns of the current window with a baseline. Counts are scaled
+// by the window lengths, a pattern counts as increased or decreased when the rate changed by more
+// than half. New patterns come first, then the rest by count.
+func ComparePatterns(baseline, current []Pattern, baselineWindow, currentWindow time.Duration) []PatternChange {
+	scale := 1.0
+	if baselineWindow > 0 && currentWindow > 0 {
+		scale = float64(currentWindow) / float64(baselineWindow)
+	}
+	before := map[string]Pattern{}
+	for _, p := range baseline {
+		before[p.Template] = p
+	}
+
+	var changes []PatternChange
+	for _, p := range current {
+		b, ok := before[p.Template]
+		delete(before, p.Template)
+		change := PatternChange{Pattern: p, BaselineCount: b.Count, Status: PatternSame}
+		expected := float64(b.Count) * scale
+		switch {
+		case !ok:
+			change.Status = PatternNew
+		case float64(p.Count) > expected*1.5:
+			change.Status = PatternIncreased
+		case float64(p.Count) < expected*0.5:
+			change.Status = PatternDecreased
+		}
+		changes = append(changes, change)
+	}
+	for _, b := range before {
+		changes = append(changes, PatternChange{Pattern: Pattern{Template: b.Template, FirstSeen: b.FirstSeen, LastSeen: b.LastSeen, Example: b.Example}, BaselineCount: b.Count, Status: PatternGone})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if (changes[i].Status == PatternNew) != (changes[j].Status == PatternNew) {
+			return changes[i].Status == PatternNew
+		}
+		if changes[i].Count == changes[j].Count {
+			return changes[i].BaselineCount > changes[j].BaselineCount
+		}
+		return changes[i].Count > changes[j].Count
+	})
+	return changes
+}
+
+// WritePatterns writes patterns as a table or json.
+func WritePatterns(w io.Writer, patterns []Pattern, format string) error {
+	changes := make([]PatternChange, len(patterns))
+	for i, p := range patterns {
+		changes[i] = PatternChange{Pattern: p}
+	}
+	if format == "json" {
+		return json.NewEncoder(w).Encode(patterns)
+	}
+	return writePatternTable(w, changes, false, format)
+}
+
+// WritePatternChanges writes compared patterns as a table or json.
+func WritePatternChanges(w io.Writer, changes []PatternChange, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(changes)
+	}
+	return writePatternTable(w, changes, true, format)
+}
+
+func writePatternTable(w io.Writer, changes []PatternChange, compared bool, format string) error {
+	if format != "table" {
+		return fmt.Errorf("unknown output %s, use table or json", format)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if compared {
+		fmt.Fprint(tw, "STATUS\tBASELINE\t")
+	}
+	fmt.Fprintln(tw, "COUNT\tFIRST SEEN\tLAST SEEN\tTEMPLATE\tEXAMPLE")
+	for _, c := range changes {
+		if compared {
+			fmt.Fprintf(tw, "%s\t%d\t", c.Status, c.BaselineCount)
+		}
+		example := whitespace.ReplaceAllString(c.Example, " ")
+		if len(example) > 120 {
+			example = strings.ToValidUTF8(example[:120], "") + "..."
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", c.Count, c.FirstSeen.Format(time.RFC3339), c.LastSeen.Format(time.RFC3339), c.Template, example)
+	}
+	return tw.Flush()
+}
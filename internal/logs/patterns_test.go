@@ -0,0 +1,87 @@
+package logs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		message  string
+		template string
+	}{
+		{"request 3f2b6c1e-8a4d-4e2f-9b1a-0c5d7e8f9a2b took 125 ms", "request <uuid> took <num> ms"},
+		{"2024-03-01T10:15:30.123Z connected to 10.0.1.12:5432", "<ts> connected to <ip>"},
+		{"commit deadbeef1234 accepted", "commit <hex> accepted"},
+		{"  retry   -3 of 5\n", "retry <num> of <num>"},
+		{"user deadbeef logged in", "user deadbeef logged in"},
+		{"took 125ms size=10KB user123 order-7", "took <num>ms size=<num>KB user<num> order-<num>"},
+	} {
+		t.Run("Should mask "+tc.message, func(t *testing.T) {
+			assert.Equal(t, tc.template, Template(tc.message))
+		})
+	}
+
+	t.Run("Should truncate long messages", func(t *testing.T) {
+		template := Template(strings.Repeat("a", 500))
+
+		assert.Equal(t, maxTemplateLength+3, len(template))
+	})
+}
+
+func TestPatterns(t *testing.T) {
+	p := &Patterns{}
+	p.Add(3000, "request 1 failed")
+	p.Add(1000, "request 2 failed")
+	p.Add(2000, "started")
+
+	patterns := p.List()
+
+	assert.Equal(t, 2, len(patterns))
+	assert.Equal(t, "request <num> failed", patterns[0].Template)
+	assert.Equal(t, 2, patterns[0].Count)
+	assert.Equal(t, int64(1000), patterns[0].FirstSeen.UnixMilli())
+	assert.Equal(t, int64(3000), patterns[0].LastSeen.UnixMilli())
+	assert.Equal(t, "request 1 failed", patterns[0].Example)
+}
+
+func TestComparePatterns(t *testing.T) {
+	baseline := []Pattern{
+		{Template: "steady", Count: 20},
+		{Template: "growing", Count: 10},
+		{Template: "removed", Count: 5},
+	}
+	current := []Pattern{
+		{Template: "steady", Count: 10},
+		{Template: "growing", Count: 30},
+		{Template: "panic <num>", Count: 1},
+	}
+
+	changes := ComparePatterns(baseline, current, 2*time.Hour, time.Hour)
+
+	status := map[string]string{}
+	for _, c := range changes {
+		status[c.Template] = c.Status
+	}
+	assert.Equal(t, "panic <num>", changes[0].Template)
+	assert.Equal(t, map[string]string{
+		"steady":      PatternSame,
+		"growing":     PatternIncreased,
+		"removed":     PatternGone,
+		"panic <num>": PatternNew,
+	}, status)
+}
+
+func TestWritePatterns(t *testing.T) {
+	p := &Patterns{}
+	p.Add(0, "started")
+	var buf bytes.Buffer
+
+	assert.Nil(t, WritePatterns(&buf, p.List(), "table"))
+	assert.Contains(t, buf.String(), "started")
+	assert.NotNil(t, WritePatterns(&buf, p.List(), "csv"))
+}
@@ -0,0 +1,161 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// PutLogEvents limits, every event counts its message plus putEventOverhead bytes.
+const (
+	maxPutEventsCount = 10000
+	maxPutEventsBytes = 1048576
+	maxPutEventsSpan  = 24 * time.Hour
+	maxEventBytes     = 256*1024 - putEventOverhead
+	putEventOverhead  = 26
+)
+
+// EnsureLogStream creates the log group and the log stream unless they exist.
+func EnsureLogStream(ctx context.Context, logGroupName, logStreamName string, c cloudwatchlogsiface.CloudWatchLogsAPI) error {
+	_, err := c.CreateLogGroupWithContext(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(logGroupName),
+	})
+	if err != nil && !isAlreadyExists(err) {
+		return fmt.Errorf("unable to create log group %s: %v", logGroupName, err)
+	}
+	_, err = c.CreateLogStreamWithContext(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(logGroupName),
+		LogStreamName: aws.String(logStreamName),
+	})
+	if err != nil && !isAlreadyExists(err) {
+		return fmt.Errorf("unable to create log stream %s: %v", logStreamName, err)
+	}
+	return nil
+}
+
+func isAlreadyExists(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException
+}
+
+// InputEvents turns records into events in chronological order. Records without a timestamp get
+// now. With rebase the timestamps are shifted so the latest event is at now and the spacing
+// between events is kept. Empty messages are dropped and messages over the event size limit are
+// truncated.
+func InputEvents(records []*Record, rebase bool, now time.Time) []*cloudwatchlogs.InputLogEvent {
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	offset := int64(0)
+	if rebase {
+		latest := int64(0)
+		for _, r := range records {
+			latest = max(latest, r.Timestamp)
+		}
+		if latest > 0 {
+			offset = nowMs - latest
+		}
+	}
+
+	var events []*cloudwatchlogs.InputLogEvent
+	for _, r := range records {
+		if r.Message == "" {
+			continue
+		}
+		ts := nowMs
+		if r.Timestamp > 0 {
+			ts = r.Timestamp + offset
+		}
+		message := r.Message
+		if len(message) > maxEventBytes {
+			message = strings.ToValidUTF8(message[:maxEventBytes], "")
+		}
+		events = append(events, &cloudwatchlogs.InputLogEvent{
+			Timestamp: aws.Int64(ts),
+			Message:   aws.String(message),
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return *events[i].Timestamp < *events[j].Timestamp
+	})
+	return events
+}
+
+// PutEventsResult counts the events sent and the ones CloudWatch Logs rejected.
+type PutEventsResult struct {
+	// Sent counts the events CloudWatch Logs accepted.
+	Sent     int
+	Batches  int
+	TooOld   int
+	TooNew   int
+	Expired  int
+	Rejected int
+}
+
+// PutEvents sends chronologically ordered events to a log stream in batches within the
+// PutLogEvents count, size and time span limits.
+func PutEvents(ctx context.Context, logGroupName, logStreamName string, events []*cloudwatchlogs.InputLogEvent, c cloudwatchlogsiface.CloudWatchLogsAPI) (*PutEventsResult, error) {
+	result := &PutEventsResult{}
+	for _, batch := range putEventsBatches(events) {
+		output, err := c.PutLogEventsWithContext(ctx, &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String(logGroupName),
+			LogStreamName: aws.String(logStreamName),
+			LogEvents:     batch,
+		})
+		if err != nil {
+			return result, fmt.Errorf("unable to put log events: %v", err)
+		}
+		result.Batches++
+		rejected := 0
+		if info := output.RejectedLogEventsInfo; info != nil {
+			// events before the end indexes and from the start index on were rejected
+			tooOld, expired, tooNew := 0, 0, 0
+			if info.TooOldLogEventEndIndex != nil {
+				tooOld = int(*info.TooOldLogEventEndIndex)
+			}
+			if info.ExpiredLogEventEndIndex != nil {
+				expired = int(*info.ExpiredLogEventEndIndex)
+			}
+			if info.TooNewLogEventStartIndex != nil {
+				tooNew = len(batch) - int(*info.TooNewLogEventStartIndex)
+			}
+			result.TooOld += tooOld
+			result.Expired += expired
+			result.TooNew += tooNew
+			// expired events can be too old as well, both ranges start at the first event of the batch
+			rejected = max(tooOld, expired) + tooNew
+		}
+		result.Rejected += rejected
+		result.Sent += len(batch) - rejected
+	}
+	return result, nil
+}
+
+func putEventsBatches(events []*cloudwatchlogs.InputLogEvent) [][]*cloudwatchlogs.InputLogEvent {
+	var batches [][]*cloudwatchlogs.InputLogEvent
+	var batch []*cloudwatchlogs.InputLogEvent
+	size := 0
+	for _, e := range events {
+		eventSize := len(*e.Message) + putEventOverhead
+		full := len(batch) == maxPutEventsCount || size+eventSize > maxPutEventsBytes
+		if len(batch) > 0 && !full {
+			full = *e.Timestamp-*batch[0].Timestamp >= int64(maxPutEventsSpan/time.Millisecond)
+		}
+		if len(batch) > 0 && full {
+			batches = append(batches, batch)
+			batch = nil
+			size = 0
+		}
+		batch = append(batch, e)
+		size += eventSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
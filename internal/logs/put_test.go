@@ -0,0 +1,105 @@
+package logs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type putMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	createdStream bool
+	batches       []int
+}
+
+func (m *putMock) CreateLogGroupWithContext(ctx aws.Context, input *cloudwatchlogs.CreateLogGroupInput, opts ...request.Option) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return nil, awserr.New(cloudwatchlogs.ErrCodeResourceAlreadyExistsException, "exists", nil)
+}
+
+func (m *putMock) CreateLogStreamWithContext(ctx aws.Context, input *cloudwatchlogs.CreateLogStreamInput, opts ...request.Option) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	m.createdStream = true
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func (m *putMock) PutLogEventsWithContext(ctx aws.Context, input *cloudwatchlogs.PutLogEventsInput, opts ...request.Option) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	m.batches = append(m.batches, len(input.LogEvents))
+	output := &cloudwatchlogs.PutLogEventsOutput{}
+	switch len(m.batches) {
+	case 1:
+		output.RejectedLogEventsInfo = &cloudwatchlogs.RejectedLogEventsInfo{TooOldLogEventEndIndex: aws.Int64(2)}
+	case 2:
+		output.RejectedLogEventsInfo = &cloudwatchlogs.RejectedLogEventsInfo{
+			TooOldLogEventEndIndex:   aws.Int64(1),
+			ExpiredLogEventEndIndex:  aws.Int64(3),
+			TooNewLogEventStartIndex: aws.Int64(5),
+		}
+	}
+	return output, nil
+}
+
+func TestEnsureLogStream(t *testing.T) {
+	mock := &putMock{}
+
+	assert.Nil(t, EnsureLogStream(context.Background(), "group", "stream", mock))
+	assert.True(t, mock.createdStream)
+}
+
+func TestInputEvents(t *testing.T) {
+	now := time.Unix(10000, 0)
+	records := []*Record{
+		NewRecord(3000, "", "c"),
+		NewRecord(1000, "", "a"),
+		NewRecord(0, "", "plain"),
+		NewRecord(2000, "", ""),
+	}
+
+	t.Run("Should keep timestamps and order events", func(t *testing.T) {
+		events := InputEvents(records, false, now)
+
+		assert.Equal(t, 3, len(events))
+		assert.Equal(t, "a", *events[0].Message)
+		assert.Equal(t, "c", *events[1].Message)
+		assert.Equal(t, int64(10000000), *events[2].Timestamp)
+	})
+
+	t.Run("Should rebase timestamps to now", func(t *testing.T) {
+		events := InputEvents(records, true, now)
+
+		assert.Equal(t, int64(10000000-2000), *events[0].Timestamp)
+		assert.Equal(t, int64(10000000), *events[1].Timestamp)
+	})
+}
+
+func TestPutEvents(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	var events []*cloudwatchlogs.InputLogEvent
+	add := func(n int, ts int64, size int) {
+		for i := 0; i < n; i++ {
+			events = append(events, &cloudwatchlogs.InputLogEvent{Timestamp: aws.Int64(ts), Message: aws.String(strings.Repeat("x", size))})
+		}
+	}
+	// count limit, size limit and then the 24 hour span
+	add(10001, 1, 1)
+	add(9, hour, 200000)
+	add(1, 30*hour, 1)
+
+	mock := &putMock{}
+	result, err := PutEvents(context.Background(), "group", "stream", events, mock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10000, 6, 4, 1}, mock.batches)
+	// the ranges overlap within a batch, 2 rejected in the first one and max(1, 3) + 1 in the second
+	assert.Equal(t, 6, result.Rejected)
+	assert.Equal(t, 10005, result.Sent)
+	assert.Equal(t, 3, result.TooOld)
+	assert.Equal(t, 3, result.Expired)
+	assert.Equal(t, 1, result.TooNew)
+}
@@ -0,0 +1,382 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// maxLiveTailLogGroups is the number of log groups a single StartLiveTail session accepts.
+const maxLiveTailLogGroups = 10
+
+// TailEvent is a single log event received while tailing.
+type TailEvent struct {
+	EventId       string
+	LogGroupName  string
+	LogStreamName string
+	Timestamp     time.Time
+	Message       string
+}
+
+// TailOptions configures Tail.
+type TailOptions struct {
+	// StartTime is the time from which existing events are printed before following.
+	StartTime time.Time
+	// Follow keeps the tail running until the context is cancelled.
+	Follow bool
+	// Poll disables StartLiveTail and always polls FilterLogEvents.
+	Poll bool
+	// PollInterval is the delay between FilterLogEvents calls when polling.
+	PollInterval  time.Duration
+	FilterPattern string
+	// LogStreamNames and LogStreamNamePrefix are mutually exclusive.
+	LogStreamNames      []string
+	LogStreamNamePrefix string
+}
+
+// ResolveLogGroups returns the log groups with the given names and the log groups starting with prefix.
+func ResolveLogGroups(ctx context.Context, names []string, prefix string, c cloudwatchlogsiface.CloudWatchLogsAPI) ([]*cloudwatchlogs.LogGroup, error) {
+	seen := map[string]bool{}
+	var logGroups []*cloudwatchlogs.LogGroup
+	add := func(lg *cloudwatchlogs.LogGroup) {
+		if !seen[*lg.LogGroupName] {
+			seen[*lg.LogGroupName] = true
+			logGroups = append(logGroups, lg)
+		}
+	}
+
+	for _, name := range names {
+		found, err := Describe(ctx, aws.String(name), c)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe log group %s: %v", name, err)
+		}
+		var match *cloudwatchlogs.LogGroup
+		for _, lg := range found {
+			if *lg.LogGroupName == name {
+				match = lg
+				break
+			}
+		}
+		if match == nil {
+			return nil, fmt.Errorf("log group %s not found", name)
+		}
+		add(match)
+	}
+
+	if prefix != "" {
+		found, err := Describe(ctx, aws.String(prefix), c)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe log groups with prefix %s: %v", prefix, err)
+		}
+		for _, lg := range found {
+			add(lg)
+		}
+	}
+
+	if len(logGroups) == 0 {
+		return nil, fmt.Errorf("no log groups found")
+	}
+
+	return logGroups, nil
+}
+
+// Tail calls fn for every event in logGroups since opts.StartTime. With opts.Follow it keeps
+// receiving new events using StartLiveTail, and falls back to polling FilterLogEvents when live
+// tail can not be started.
+func Tail(ctx context.Context, logGroups []*cloudwatchlogs.LogGroup, opts TailOptions, c cloudwatchlogsiface.CloudWatchLogsAPI, fn func(TailEvent) error) error {
+	startTime := opts.StartTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+	p := &poller{
+		c:        c,
+		opts:     opts,
+		cursor:   startTime.UnixNano() / int64(time.Millisecond),
+		seen:     map[string]int64{},
+		callback: fn,
+	}
+
+	if err := p.poll(ctx, logGroups); err != nil {
+		return err
+	}
+	if !opts.Follow {
+		return nil
+	}
+
+	if !opts.Poll && liveTailSupported(logGroups, opts) {
+		// live tail only returns events from the start of the session, the ones written since the poll are read
+		// with another poll once it started. Live events it returned as well are dropped.
+		polled := map[string]int{}
+		catchUp := func() error {
+			p.callback = func(e TailEvent) error {
+				polled[liveTailKey(e)]++
+				return fn(e)
+			}
+			defer func() { p.callback = fn }()
+			return p.poll(ctx, logGroups)
+		}
+		err := liveTail(ctx, logGroups, opts, c, catchUp, func(e TailEvent) error {
+			if k := liveTailKey(e); polled[k] > 0 {
+				polled[k]--
+				return nil
+			}
+			return fn(e)
+		})
+		if err != errLiveTailUnavailable {
+			return err
+		}
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.poll(ctx, logGroups); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
+
+var errLiveTailUnavailable = fmt.Errorf("live tail unavailable")
+
+// liveTailSupported reports whether the log groups and stream filters fit in a single live tail session.
+func liveTailSupported(logGroups []*cloudwatchlogs.LogGroup, opts TailOptions) bool {
+	if len(logGroups) > maxLiveTailLogGroups {
+		return false
+	}
+	if len(opts.LogStreamNames) > 0 || opts.LogStreamNamePrefix != "" {
+		// stream filters are only accepted together with a single log group
+		return len(logGroups) == 1
+	}
+	return true
+}
+
+// liveTailKey identifies an event in live tail results, they have no event id.
+func liveTailKey(e TailEvent) string {
+	return fmt.Sprintf("%s\x00%s\x00%d\x00%s", e.LogGroupName, e.LogStreamName, e.Timestamp.UnixNano()/int64(time.Millisecond), e.Message)
+}
+
+// liveTail calls fn for the events of live tail sessions, onStart is called once the first session started.
+func liveTail(ctx context.Context, logGroups []*cloudwatchlogs.LogGroup, opts TailOptions, c cloudwatchlogsiface.CloudWatchLogsAPI, onStart func() error, fn func(TailEvent) error) error {
+	names := map[string]string{}
+	input := &cloudwatchlogs.StartLiveTailInput{}
+	for _, lg := range logGroups {
+		arn := aws.StringValue(lg.LogGroupArn)
+		if arn == "" {
+			arn = strings.TrimSuffix(aws.StringValue(lg.Arn), ":*")
+		}
+		if arn == "" {
+			return errLiveTailUnavailable
+		}
+		names[arn] = *lg.LogGroupName
+		input.LogGroupIdentifiers = append(input.LogGroupIdentifiers, aws.String(arn))
+	}
+	if opts.FilterPattern != "" {
+		input.LogEventFilterPattern = aws.String(opts.FilterPattern)
+	}
+	if len(opts.LogStreamNames) > 0 {
+		input.LogStreamNames = aws.StringSlice(opts.LogStreamNames)
+	}
+	if opts.LogStreamNamePrefix != "" {
+		input.LogStreamNamePrefixes = aws.StringSlice([]string{opts.LogStreamNamePrefix})
+	}
+
+	started := false
+	for {
+		output, err := c.StartLiveTailWithContext(ctx, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !started {
+				return errLiveTailUnavailable
+			}
+			return fmt.Errorf("unable to start live tail: %v", err)
+		}
+		if !started {
+			started = true
+			if err := onStart(); err != nil {
+				output.GetStream().Close()
+				return err
+			}
+		}
+
+		err = readLiveTail(ctx, output.GetStream(), names, fn)
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		// sessions end after a few hours, start a new one
+	}
+}
+
+func readLiveTail(ctx context.Context, stream *cloudwatchlogs.StartLiveTailEventStream, names map[string]string, fn func(TailEvent) error) error {
+	defer stream.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-stream.Events():
+			if !ok {
+				if err := stream.Err(); err != nil && ctx.Err() == nil {
+					return fmt.Errorf("live tail failed: %v", err)
+				}
+				return nil
+			}
+			update, ok := event.(*cloudwatchlogs.LiveTailSessionUpdate)
+			if !ok {
+				continue
+			}
+			for _, r := range update.SessionResults {
+				name, ok := names[aws.StringValue(r.LogGroupIdentifier)]
+				if !ok {
+					name = aws.StringValue(r.LogGroupIdentifier)
+				}
+				err := fn(TailEvent{
+					LogGroupName:  name,
+					LogStreamName: aws.StringValue(r.LogStreamName),
+					Timestamp:     msToTime(aws.Int64Value(r.Timestamp)),
+					Message:       aws.StringValue(r.Message),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// poller reads events with FilterLogEvents and drops events it has already seen.
+type poller struct {
+	c        cloudwatchlogsiface.CloudWatchLogsAPI
+	opts     TailOptions
+	cursor   int64
+	seen     map[string]int64
+	callback func(TailEvent) error
+}
+
+func (p *poller) poll(ctx context.Context, logGroups []*cloudwatchlogs.LogGroup) error {
+	var events []TailEvent
+	for _, lg := range logGroups {
+		input := &cloudwatchlogs.FilterLogEventsInput{
+			LogGroupName: lg.LogGroupName,
+			StartTime:    aws.Int64(p.cursor),
+		}
+		if p.opts.FilterPattern != "" {
+			input.FilterPattern = aws.String(p.opts.FilterPattern)
+		}
+		if len(p.opts.LogStreamNames) > 0 {
+			input.LogStreamNames = aws.StringSlice(p.opts.LogStreamNames)
+		}
+		if p.opts.LogStreamNamePrefix != "" {
+			input.LogStreamNamePrefix = aws.String(p.opts.LogStreamNamePrefix)
+		}
+		err := p.c.FilterLogEventsPagesWithContext(ctx, input, func(output *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+			for _, e := range output.Events {
+				id := aws.StringValue(e.EventId)
+				if _, ok := p.seen[id]; ok {
+					continue
+				}
+				p.seen[id] = aws.Int64Value(e.Timestamp)
+				events = append(events, TailEvent{
+					EventId:       id,
+					LogGroupName:  *lg.LogGroupName,
+					LogStreamName: aws.StringValue(e.LogStreamName),
+					Timestamp:     msToTime(aws.Int64Value(e.Timestamp)),
+					Message:       aws.StringValue(e.Message),
+				})
+			}
+			return lastPage == false
+		})
+		if err != nil {
+			return fmt.Errorf("unable to filter log events for %s: %v", *lg.LogGroupName, err)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	for _, e := range events {
+		if err := p.callback(e); err != nil {
+			return err
+		}
+	}
+
+	// events arrive late, so the next poll starts a bit before the newest event and relies on the ids
+	for _, ts := range p.seen {
+		if ts-int64(time.Minute/time.Millisecond) > p.cursor {
+			p.cursor = ts - int64(time.Minute/time.Millisecond)
+		}
+	}
+	for id, ts := range p.seen {
+		if ts < p.cursor {
+			delete(p.seen, id)
+		}
+	}
+
+	return nil
+}
+
+func msToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// ansi colours used for stream names, bright ones are easier to tell apart
+var streamColors = []string{"31", "32", "33", "34", "35", "36", "91", "92", "93", "94", "95", "96"}
+
+// EventPrinter writes tail events, one per line, prefixed with time and stream.
+type EventPrinter struct {
+	W io.Writer
+	// Color enables ansi colours per log stream.
+	Color bool
+	// ShowGroup prefixes the stream name with the log group name.
+	ShowGroup bool
+}
+
+// Print writes e to p.W. JSON messages are indented.
+func (p *EventPrinter) Print(e TailEvent) error {
+	source := e.LogStreamName
+	if p.ShowGroup {
+		source = e.LogGroupName + "/" + e.LogStreamName
+	}
+	if p.Color {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(e.LogGroupName + "/" + e.LogStreamName))
+		source = fmt.Sprintf("\x1b[%sm%s\x1b[0m", streamColors[h.Sum32()%uint32(len(streamColors))], source)
+	}
+
+	_, err := fmt.Fprintf(p.W, "%s %s %s\n", e.Timestamp.UTC().Format(time.RFC3339Nano), source, FormatMessage(e.Message))
+	return err
+}
+
+// FormatMessage indents messages that are JSON objects and trims trailing new lines from others.
+func FormatMessage(message string) string {
+	trimmed := strings.TrimSpace(message)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(trimmed), "", "  "); err == nil {
+			return buf.String()
+		}
+	}
+	return strings.TrimRight(message, "\r\n")
+}
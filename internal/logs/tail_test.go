@@ -0,0 +1,149 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type tailMock struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	mu       sync.Mutex
+	polls    [][]*cloudwatchlogs.FilteredLogEvent
+	inputs   []*cloudwatchlogs.FilterLogEventsInput
+	liveTail int
+}
+
+func (m *tailMock) FilterLogEventsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.FilterLogEventsInput, f func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool, opts ...request.Option) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+	var events []*cloudwatchlogs.FilteredLogEvent
+	if len(m.polls) > 0 {
+		events = m.polls[0]
+		m.polls = m.polls[1:]
+	}
+	f(&cloudwatchlogs.FilterLogEventsOutput{Events: events}, true)
+	return nil
+}
+
+func (m *tailMock) StartLiveTailWithContext(ctx aws.Context, input *cloudwatchlogs.StartLiveTailInput, opts ...request.Option) (*cloudwatchlogs.StartLiveTailOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.liveTail++
+	return nil, fmt.Errorf("AccessDeniedException")
+}
+
+func (m *tailMock) DescribeLogGroupsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.DescribeLogGroupsInput, f func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool, opts ...request.Option) error {
+	f(&cloudwatchlogs.DescribeLogGroupsOutput{
+		LogGroups: []*cloudwatchlogs.LogGroup{
+			{LogGroupName: aws.String("/aws/lambda/a"), Arn: aws.String("arn:aws:logs:eu-west-1:1:log-group:/aws/lambda/a:*")},
+			{LogGroupName: aws.String("/aws/lambda/ab"), Arn: aws.String("arn:aws:logs:eu-west-1:1:log-group:/aws/lambda/ab:*")},
+		},
+	}, true)
+	return nil
+}
+
+func filteredEvent(id string, ts int64, message string) *cloudwatchlogs.FilteredLogEvent {
+	return &cloudwatchlogs.FilteredLogEvent{
+		EventId:       aws.String(id),
+		Timestamp:     aws.Int64(ts),
+		Message:       aws.String(message),
+		LogStreamName: aws.String("stream"),
+	}
+}
+
+func TestResolveLogGroups(t *testing.T) {
+	t.Run("Should resolve exact names and prefix without duplicates", func(t *testing.T) {
+		logGroups, err := ResolveLogGroups(context.Background(), []string{"/aws/lambda/a"}, "/aws/lambda/", &tailMock{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(logGroups))
+		assert.Equal(t, "/aws/lambda/a", *logGroups[0].LogGroupName)
+	})
+
+	t.Run("Should fail for unknown name", func(t *testing.T) {
+		_, err := ResolveLogGroups(context.Background(), []string{"/aws/lambda/c"}, "", &tailMock{})
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestTail(t *testing.T) {
+	logGroups := []*cloudwatchlogs.LogGroup{{LogGroupName: aws.String("group"), Arn: aws.String("arn:aws:logs:eu-west-1:1:log-group:group:*")}}
+
+	t.Run("Should print events since start time without following", func(t *testing.T) {
+		mock := &tailMock{polls: [][]*cloudwatchlogs.FilteredLogEvent{
+			{filteredEvent("2", 2000, "b"), filteredEvent("1", 1000, "a")},
+		}}
+		var messages []string
+		err := Tail(context.Background(), logGroups, TailOptions{StartTime: time.Unix(1, 0), FilterPattern: "ERROR"}, mock, func(e TailEvent) error {
+			messages = append(messages, e.Message)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, messages)
+		assert.Equal(t, int64(1000), *mock.inputs[0].StartTime)
+		assert.Equal(t, "ERROR", *mock.inputs[0].FilterPattern)
+		assert.Equal(t, 0, mock.liveTail)
+	})
+
+	t.Run("Should fall back to polling and drop duplicate events", func(t *testing.T) {
+		mock := &tailMock{polls: [][]*cloudwatchlogs.FilteredLogEvent{
+			{filteredEvent("1", 1000, "a")},
+			{filteredEvent("1", 1000, "a"), filteredEvent("2", 2000, "b")},
+			{filteredEvent("2", 2000, "b"), filteredEvent("3", 3000, "c")},
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var messages []string
+		err := Tail(ctx, logGroups, TailOptions{StartTime: time.Unix(1, 0), Follow: true, PollInterval: time.Millisecond}, mock, func(e TailEvent) error {
+			messages = append(messages, e.Message)
+			if len(messages) == 3 {
+				cancel()
+			}
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, messages)
+		assert.Equal(t, 1, mock.liveTail)
+	})
+}
+
+func TestEventPrinter(t *testing.T) {
+	e := TailEvent{
+		LogGroupName:  "group",
+		LogStreamName: "stream",
+		Timestamp:     time.Unix(0, 0),
+		Message:       `{"level":"ERROR"}`,
+	}
+
+	t.Run("Should pretty print json messages", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := &EventPrinter{W: &buf}
+
+		assert.Nil(t, p.Print(e))
+		assert.Equal(t, "1970-01-01T00:00:00Z stream {\n  \"level\": \"ERROR\"\n}\n", buf.String())
+	})
+
+	t.Run("Should colour stream names", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := &EventPrinter{W: &buf, Color: true, ShowGroup: true}
+		e.Message = "plain\n"
+
+		assert.Nil(t, p.Print(e))
+		assert.Contains(t, buf.String(), "group/stream\x1b[0m plain\n")
+		assert.Contains(t, buf.String(), "\x1b[")
+	})
+}
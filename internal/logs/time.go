@@ -0,0 +1,41 @@
+package logs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTime parses an absolute time in RFC3339 or 2006-01-02 format, "now", or a duration
+// relative to now such as 15m, 2h or 7d.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "now" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDuration(strings.TrimPrefix(s, "-"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, use RFC3339, 2006-01-02 or a relative duration like 15m, 2h or 7d", s)
+	}
+	return now.Add(-d), nil
+}
+
+// ParseDuration parses a duration like time.ParseDuration and in addition accepts days, e.g. 7d.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
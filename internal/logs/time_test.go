@@ -0,0 +1,38 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"", now},
+		{"now", now},
+		{"15m", now.Add(-15 * time.Minute)},
+		{"-2h", now.Add(-2 * time.Hour)},
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"2024-03-01T10:00:00Z", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		t.Run("Should parse "+tc.in, func(t *testing.T) {
+			got, err := ParseTime(tc.in, now)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("Should fail on invalid time", func(t *testing.T) {
+		_, err := ParseTime("yesterday", now)
+
+		assert.NotNil(t, err)
+	})
+}
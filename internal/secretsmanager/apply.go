@@ -0,0 +1,363 @@
+package secretsmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// RSAValue is the value of rsa secrets, stored as JSON in the SecretBinary.
+type RSAValue struct {
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+}
+
+// Secret is a secret in the input files of apply, create-secrets and update. Type is value,
+// password or rsa.
+type Secret struct {
+	Id       string
+	Name     string
+	Type     string
+	Value    string
+	RSAValue RSAValue `json:"rsaValue,omitempty"`
+	// KmsKeyId is a key id, ARN or alias, the account default key when empty.
+	KmsKeyId       string            `json:",omitempty"`
+	Description    string            `json:",omitempty"`
+	Tags           map[string]string `json:",omitempty"`
+	ResourcePolicy json.RawMessage   `json:",omitempty"`
+}
+
+// ReadSecrets reads secrets, in the input format or the format of Export, and checks their names
+// and types.
+func ReadSecrets(r io.Reader) ([]Secret, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("unable to read secrets: %v", err)
+	}
+	secrets := make([]Secret, len(raw))
+	for i, b := range raw {
+		var exported SecretOutput
+		if err := json.Unmarshal(b, &exported); err == nil && exported.Entry != nil {
+			s, err := exported.secret()
+			if err != nil {
+				return nil, err
+			}
+			secrets[i] = s
+			continue
+		}
+		if err := json.Unmarshal(b, &secrets[i]); err != nil {
+			return nil, fmt.Errorf("unable to read secret %d: %v", i, err)
+		}
+	}
+	names := map[string]bool{}
+	for i, s := range secrets {
+		if s.Name == "" {
+			return nil, fmt.Errorf("secret %d has no name", i)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("secret %s is defined twice", s.Name)
+		}
+		names[s.Name] = true
+		if _, _, err := s.value(); err != nil {
+			return nil, err
+		}
+	}
+	return secrets, nil
+}
+
+// value returns the SecretString or the SecretBinary of the secret.
+func (s Secret) value() (*string, []byte, error) {
+	switch s.Type {
+	case "value", "password":
+		return aws.String(s.Value), nil, nil
+	case "rsa":
+		b, err := json.Marshal(s.RSAValue)
+		return nil, b, err
+	default:
+		return nil, nil, fmt.Errorf("secret %s has unknown type %s, use value, password or rsa", s.Name, s.Type)
+	}
+}
+
+// Apply actions of PlanApply.
+const (
+	ApplyCreate = "create"
+	ApplyUpdate = "update"
+	// ApplyRestore is a secret scheduled for deletion, it is restored and updated.
+	ApplyRestore = "restore"
+	ApplyNoop    = "no-op"
+)
+
+// SecretChange is what PlanApply found for a secret. Changes are the differing fields of an update.
+type SecretChange struct {
+	Secret  Secret
+	Action  string
+	Changes []string
+}
+
+// PlanApply compares the secrets with the existing ones and returns what Apply does for each of
+// them, sorted by name. Values are compared by their SHA-256 hashes. Tags that are not in a
+// secret are left as they are, and so is the resource policy when a secret has none.
+func PlanApply(ctx context.Context, secrets []Secret, c secretsmanageriface.SecretsManagerAPI) ([]SecretChange, error) {
+	var changes []SecretChange
+	for _, s := range secrets {
+		change := SecretChange{Secret: s}
+		describe, err := c.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(s.Name)})
+		if isNotFound(err) {
+			change.Action = ApplyCreate
+			changes = append(changes, change)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe secret %s: %v", s.Name, err)
+		}
+
+		if describe.DeletedDate != nil {
+			// the value of a deleted secret can not be read
+			change.Changes = append(change.Changes, "value")
+		} else {
+			same, err := sameValue(ctx, s, c)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				change.Changes = append(change.Changes, "value")
+			}
+		}
+		if s.Description != aws.StringValue(describe.Description) {
+			change.Changes = append(change.Changes, "description")
+		}
+		if !sameKmsKey(aws.StringValue(describe.KmsKeyId), s.KmsKeyId) {
+			change.Changes = append(change.Changes, "kms-key-id")
+		}
+		existing := map[string]string{}
+		for _, t := range describe.Tags {
+			existing[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+		for k, v := range s.Tags {
+			if value, ok := existing[k]; !ok || value != v {
+				change.Changes = append(change.Changes, "tags")
+				break
+			}
+		}
+		if len(s.ResourcePolicy) > 0 {
+			same, err := samePolicy(ctx, s, c)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				change.Changes = append(change.Changes, "resource-policy")
+			}
+		}
+
+		switch {
+		case describe.DeletedDate != nil:
+			change.Action = ApplyRestore
+		case len(change.Changes) > 0:
+			change.Action = ApplyUpdate
+		default:
+			change.Action = ApplyNoop
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Secret.Name < changes[j].Secret.Name
+	})
+	return changes, nil
+}
+
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException
+}
+
+// sameValue compares the hash of the current value of the secret with the hash of its value.
+func sameValue(ctx context.Context, s Secret, c secretsmanageriface.SecretsManagerAPI) (bool, error) {
+	output, err := c.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(s.Name)})
+	if isNotFound(err) {
+		// a secret without a current version
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get secret value of %s: %v", s.Name, err)
+	}
+	str, binary, err := s.value()
+	if err != nil {
+		return false, err
+	}
+	if (str != nil) != (output.SecretString != nil) {
+		return false, nil
+	}
+	if str != nil {
+		return sha256.Sum256([]byte(*str)) == sha256.Sum256([]byte(*output.SecretString)), nil
+	}
+	return sha256.Sum256(binary) == sha256.Sum256(output.SecretBinary), nil
+}
+
+// sameKmsKey compares the key of a secret with a key id, ARN or alias. A key id or alias matches
+// the end of the ARN.
+func sameKmsKey(current, key string) bool {
+	if current == key {
+		return true
+	}
+	if current == "" || key == "" {
+		return current == "alias/aws/secretsmanager" || key == "alias/aws/secretsmanager"
+	}
+	return strings.HasSuffix(current, ":"+key) || strings.HasSuffix(current, "/"+key)
+}
+
+// samePolicy compares the resource policies as JSON, AWS does not keep the formatting.
+func samePolicy(ctx context.Context, s Secret, c secretsmanageriface.SecretsManagerAPI) (bool, error) {
+	output, err := c.GetResourcePolicyWithContext(ctx, &secretsmanager.GetResourcePolicyInput{SecretId: aws.String(s.Name)})
+	if err != nil {
+		return false, fmt.Errorf("unable to get resource policy of %s: %v", s.Name, err)
+	}
+	if output.ResourcePolicy == nil {
+		return false, nil
+	}
+	var current, policy interface{}
+	if err := json.Unmarshal([]byte(*output.ResourcePolicy), &current); err != nil {
+		return false, fmt.Errorf("unable to read resource policy of %s: %v", s.Name, err)
+	}
+	if err := json.Unmarshal(s.ResourcePolicy, &policy); err != nil {
+		return false, fmt.Errorf("secret %s has an invalid resource policy: %v", s.Name, err)
+	}
+	return reflect.DeepEqual(current, policy), nil
+}
+
+// Apply creates, restores and updates the secrets of the changes. No-ops are skipped.
+func Apply(ctx context.Context, changes []SecretChange, c secretsmanageriface.SecretsManagerAPI) error {
+	for _, change := range changes {
+		s := change.Secret
+		str, binary, err := s.value()
+		if err != nil {
+			return err
+		}
+		switch change.Action {
+		case ApplyCreate:
+			input := &secretsmanager.CreateSecretInput{
+				Name:         aws.String(s.Name),
+				SecretString: str,
+				SecretBinary: binary,
+			}
+			if s.Description != "" {
+				input.Description = aws.String(s.Description)
+			}
+			if s.KmsKeyId != "" {
+				input.KmsKeyId = aws.String(s.KmsKeyId)
+			}
+			input.Tags = s.tags()
+			if _, err := c.CreateSecretWithContext(ctx, input); err != nil {
+				return fmt.Errorf("unable to create secret %s: %v", s.Name, err)
+			}
+			if len(s.ResourcePolicy) > 0 {
+				if err := putResourcePolicy(ctx, s, c); err != nil {
+					return err
+				}
+			}
+		case ApplyRestore:
+			if _, err := c.RestoreSecretWithContext(ctx, &secretsmanager.RestoreSecretInput{SecretId: aws.String(s.Name)}); err != nil {
+				return fmt.Errorf("unable to restore secret %s: %v", s.Name, err)
+			}
+			if err := update(ctx, change, str, binary, c); err != nil {
+				return err
+			}
+		case ApplyUpdate:
+			if err := update(ctx, change, str, binary, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// update changes the fields of the change that differ.
+func update(ctx context.Context, change SecretChange, str *string, binary []byte, c secretsmanageriface.SecretsManagerAPI) error {
+	s := change.Secret
+	changed := map[string]bool{}
+	for _, field := range change.Changes {
+		changed[field] = true
+	}
+
+	if changed["value"] || changed["description"] || changed["kms-key-id"] {
+		input := &secretsmanager.UpdateSecretInput{SecretId: aws.String(s.Name)}
+		if changed["value"] {
+			input.SecretString, input.SecretBinary = str, binary
+		}
+		if changed["description"] {
+			input.Description = aws.String(s.Description)
+		}
+		if changed["kms-key-id"] {
+			key := s.KmsKeyId
+			if key == "" {
+				key = "alias/aws/secretsmanager"
+			}
+			input.KmsKeyId = aws.String(key)
+		}
+		if _, err := c.UpdateSecretWithContext(ctx, input); err != nil {
+			return fmt.Errorf("unable to update secret %s: %v", s.Name, err)
+		}
+	}
+	if changed["tags"] {
+		input := &secretsmanager.TagResourceInput{SecretId: aws.String(s.Name), Tags: s.tags()}
+		if _, err := c.TagResourceWithContext(ctx, input); err != nil {
+			return fmt.Errorf("unable to tag secret %s: %v", s.Name, err)
+		}
+	}
+	if changed["resource-policy"] {
+		return putResourcePolicy(ctx, s, c)
+	}
+	return nil
+}
+
+// putResourcePolicy sets the resource policy of the secret, policies granting public access are
+// rejected.
+func putResourcePolicy(ctx context.Context, s Secret, c secretsmanageriface.SecretsManagerAPI) error {
+	_, err := c.PutResourcePolicyWithContext(ctx, &secretsmanager.PutResourcePolicyInput{
+		SecretId:          aws.String(s.Name),
+		ResourcePolicy:    aws.String(string(s.ResourcePolicy)),
+		BlockPublicPolicy: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to put resource policy of %s: %v", s.Name, err)
+	}
+	return nil
+}
+
+// tags returns the tags of the secret sorted by key.
+func (s Secret) tags() []*secretsmanager.Tag {
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var tags []*secretsmanager.Tag
+	for _, k := range keys {
+		tags = append(tags, &secretsmanager.Tag{Key: aws.String(k), Value: aws.String(s.Tags[k])})
+	}
+	return tags
+}
+
+// WriteApplyTable writes the changes as a table, values are not printed.
+func WriteApplyTable(w io.Writer, changes []SecretChange) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tNAME\tTYPE\tCHANGES")
+	for _, c := range changes {
+		fields := strings.Join(c.Changes, ", ")
+		if fields == "" {
+			fields = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Action, c.Secret.Name, c.Secret.Type, fields)
+	}
+	return tw.Flush()
+}
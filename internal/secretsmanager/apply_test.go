@@ -0,0 +1,165 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+)
+
+type applyMock struct {
+	secretsMock
+	described map[string]*secretsmanager.DescribeSecretOutput
+	policies  map[string]string
+	calls     []string
+}
+
+func (m *applyMock) DescribeSecretWithContext(ctx aws.Context, input *secretsmanager.DescribeSecretInput, opts ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+	output, ok := m.described[*input.SecretId]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return output, nil
+}
+
+func (m *applyMock) GetResourcePolicyWithContext(ctx aws.Context, input *secretsmanager.GetResourcePolicyInput, opts ...request.Option) (*secretsmanager.GetResourcePolicyOutput, error) {
+	output := &secretsmanager.GetResourcePolicyOutput{}
+	if policy, ok := m.policies[*input.SecretId]; ok {
+		output.ResourcePolicy = aws.String(policy)
+	}
+	return output, nil
+}
+
+func (m *applyMock) CreateSecretWithContext(ctx aws.Context, input *secretsmanager.CreateSecretInput, opts ...request.Option) (*secretsmanager.CreateSecretOutput, error) {
+	m.calls = append(m.calls, "create "+*input.Name)
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (m *applyMock) UpdateSecretWithContext(ctx aws.Context, input *secretsmanager.UpdateSecretInput, opts ...request.Option) (*secretsmanager.UpdateSecretOutput, error) {
+	m.calls = append(m.calls, "update "+*input.SecretId)
+	return &secretsmanager.UpdateSecretOutput{}, nil
+}
+
+func (m *applyMock) RestoreSecretWithContext(ctx aws.Context, input *secretsmanager.RestoreSecretInput, opts ...request.Option) (*secretsmanager.RestoreSecretOutput, error) {
+	m.calls = append(m.calls, "restore "+*input.SecretId)
+	return &secretsmanager.RestoreSecretOutput{}, nil
+}
+
+func (m *applyMock) TagResourceWithContext(ctx aws.Context, input *secretsmanager.TagResourceInput, opts ...request.Option) (*secretsmanager.TagResourceOutput, error) {
+	m.calls = append(m.calls, "tag "+*input.SecretId)
+	return &secretsmanager.TagResourceOutput{}, nil
+}
+
+func (m *applyMock) PutResourcePolicyWithContext(ctx aws.Context, input *secretsmanager.PutResourcePolicyInput, opts ...request.Option) (*secretsmanager.PutResourcePolicyOutput, error) {
+	m.calls = append(m.calls, "policy "+*input.SecretId)
+	return &secretsmanager.PutResourcePolicyOutput{}, nil
+}
+
+func TestReadSecrets(t *testing.T) {
+	_, err := ReadSecrets(strings.NewReader(`[{"Name":"a","Type":"value","Value":"1"},{"Name":"a","Type":"value"}]`))
+	assert.EqualError(t, err, "secret a is defined twice")
+
+	_, err = ReadSecrets(strings.NewReader(`[{"Name":"a","Type":"token"}]`))
+	assert.EqualError(t, err, "secret a has unknown type token, use value, password or rsa")
+
+	t.Run("Should read secrets of export", func(t *testing.T) {
+		var buf bytes.Buffer
+		rsa, _ := json.Marshal(RSAValue{PrivateKey: "private", PublicKey: "public"})
+		assert.Nil(t, json.NewEncoder(&buf).Encode([]SecretOutput{
+			{
+				Entry: &secretsmanager.SecretListEntry{Name: aws.String("app"), Description: aws.String("db"), Tags: []*secretsmanager.Tag{{Key: aws.String("env"), Value: aws.String("dev")}}},
+				Value: &secretsmanager.GetSecretValueOutput{SecretString: aws.String("p4ss")},
+			},
+			{
+				Entry: &secretsmanager.SecretListEntry{Name: aws.String("key")},
+				Value: &secretsmanager.GetSecretValueOutput{SecretBinary: rsa},
+			},
+		}))
+
+		secrets, err := ReadSecrets(&buf)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []Secret{
+			{Name: "app", Type: "value", Value: "p4ss", Description: "db", Tags: map[string]string{"env": "dev"}},
+			{Name: "key", Type: "rsa", RSAValue: RSAValue{PrivateKey: "private", PublicKey: "public"}},
+		}, secrets)
+	})
+
+	t.Run("Should fail on redacted export", func(t *testing.T) {
+		_, err := ReadSecrets(strings.NewReader(`[{"Entry":{"Name":"app"}}]`))
+
+		assert.EqualError(t, err, "secret app has no value, export it without redact")
+	})
+}
+
+func TestApply(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"secretsmanager:DeleteSecret","Resource":"*"}]}`
+	rsa, _ := json.Marshal(RSAValue{PrivateKey: "private", PublicKey: "public"})
+	mock := &applyMock{
+		secretsMock: secretsMock{values: map[string]*secretsmanager.GetSecretValueOutput{
+			"app/db":    {SecretString: aws.String("p4ss")},
+			"app/keys":  {SecretBinary: rsa},
+			"app/token": {SecretString: aws.String("old")},
+		}},
+		described: map[string]*secretsmanager.DescribeSecretOutput{
+			"app/db": {
+				Description: aws.String("database"),
+				KmsKeyId:    aws.String("arn:aws:kms:eu-west-1:123456789012:alias/dev"),
+				Tags:        []*secretsmanager.Tag{{Key: aws.String("team"), Value: aws.String("orders")}, {Key: aws.String("owner"), Value: aws.String("alice")}},
+			},
+			"app/keys":    {},
+			"app/token":   {Tags: []*secretsmanager.Tag{{Key: aws.String("team"), Value: aws.String("billing")}}},
+			"app/deleted": {DeletedDate: aws.Time(time.Now())},
+		},
+		policies: map[string]string{"app/db": "{\n  \"Version\" : \"2012-10-17\",\n  \"Statement\" : [ {\n    \"Effect\" : \"Deny\",\n    \"Principal\" : \"*\",\n    \"Action\" : \"secretsmanager:DeleteSecret\",\n    \"Resource\" : \"*\"\n  } ]\n}"},
+	}
+	secrets := []Secret{
+		{Name: "app/db", Type: "password", Value: "p4ss", Description: "database", KmsKeyId: "alias/dev", Tags: map[string]string{"team": "orders"}, ResourcePolicy: json.RawMessage(policy)},
+		{Name: "app/keys", Type: "rsa", RSAValue: RSAValue{PrivateKey: "private", PublicKey: "public"}},
+		{Name: "app/token", Type: "value", Value: "new", Tags: map[string]string{"team": "orders"}},
+		{Name: "app/deleted", Type: "value", Value: "v"},
+		{Name: "app/new", Type: "value", Value: "v", ResourcePolicy: json.RawMessage(policy)},
+	}
+
+	changes, err := PlanApply(context.Background(), secrets, mock)
+	assert.Nil(t, err)
+
+	var plan []string
+	for _, c := range changes {
+		plan = append(plan, c.Action+" "+c.Secret.Name+" "+strings.Join(c.Changes, ","))
+	}
+	assert.Equal(t, []string{
+		"no-op app/db ",
+		"restore app/deleted value",
+		"no-op app/keys ",
+		"create app/new ",
+		"update app/token value,tags",
+	}, plan)
+
+	t.Run("Should not print values", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, WriteApplyTable(&buf, changes))
+		assert.NotContains(t, buf.String(), "p4ss")
+	})
+
+	t.Run("Should apply changes", func(t *testing.T) {
+		assert.Nil(t, Apply(context.Background(), changes, mock))
+		assert.Equal(t, []string{
+			"restore app/deleted",
+			"update app/deleted",
+			"create app/new",
+			"policy app/new",
+			"update app/token",
+			"tag app/token",
+		}, mock.calls)
+	})
+}
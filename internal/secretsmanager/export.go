@@ -0,0 +1,72 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// SecretOutput is a secret in the export format, its metadata and the current value.
+type SecretOutput struct {
+	Entry *secretsmanager.SecretListEntry
+	Value *secretsmanager.GetSecretValueOutput `json:",omitempty"`
+}
+
+// Export returns all secrets, with redact only their metadata.
+func Export(ctx context.Context, redact bool, c secretsmanageriface.SecretsManagerAPI) ([]SecretOutput, error) {
+	var entries []*secretsmanager.SecretListEntry
+	err := c.ListSecretsPagesWithContext(ctx, &secretsmanager.ListSecretsInput{}, func(output *secretsmanager.ListSecretsOutput, lastPage bool) bool {
+		entries = append(entries, output.SecretList...)
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list secrets: %v", err)
+	}
+
+	var secrets []SecretOutput
+	for _, entry := range entries {
+		secret := SecretOutput{Entry: entry}
+		if !redact {
+			value, err := c.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: entry.ARN})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get secret value of %s: %v", aws.StringValue(entry.Name), err)
+			}
+			secret.Value = value
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// secret returns the exported secret in the input format of apply. String values are value
+// secrets and binary values rsa secrets, a redacted secret has no value and is an error.
+func (s SecretOutput) secret() (Secret, error) {
+	secret := Secret{
+		Name:        aws.StringValue(s.Entry.Name),
+		Description: aws.StringValue(s.Entry.Description),
+		KmsKeyId:    aws.StringValue(s.Entry.KmsKeyId),
+	}
+	if len(s.Entry.Tags) > 0 {
+		secret.Tags = map[string]string{}
+		for _, t := range s.Entry.Tags {
+			secret.Tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+	}
+	switch {
+	case s.Value == nil:
+		return Secret{}, fmt.Errorf("secret %s has no value, export it without redact", secret.Name)
+	case s.Value.SecretString != nil:
+		secret.Type = "value"
+		secret.Value = aws.StringValue(s.Value.SecretString)
+	default:
+		if err := json.Unmarshal(s.Value.SecretBinary, &secret.RSAValue); err != nil || secret.RSAValue.PrivateKey == "" {
+			return Secret{}, fmt.Errorf("secret %s has a binary value that is not an rsa key", secret.Name)
+		}
+		secret.Type = "rsa"
+	}
+	return secret, nil
+}
@@ -0,0 +1,210 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	flowenv "github.com/flow-lab/flow/internal/env"
+)
+
+// SecretValue is a version of a secret.
+type SecretValue struct {
+	Name          string
+	ARN           string
+	VersionId     string
+	VersionStages []string
+	CreatedDate   time.Time
+	// String is the SecretString, Binary the SecretBinary, one of them is set.
+	String *string
+	Binary []byte
+}
+
+// GetSecretValue returns a version of a secret, the current one when versionStage and versionId
+// are empty.
+func GetSecretValue(ctx context.Context, secretId, versionStage, versionId string, c secretsmanageriface.SecretsManagerAPI) (*SecretValue, error) {
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	}
+	if versionStage != "" {
+		input.VersionStage = aws.String(versionStage)
+	}
+	if versionId != "" {
+		input.VersionId = aws.String(versionId)
+	}
+	output, err := c.GetSecretValueWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get secret value of %s: %v", secretId, err)
+	}
+	return &SecretValue{
+		Name:          aws.StringValue(output.Name),
+		ARN:           aws.StringValue(output.ARN),
+		VersionId:     aws.StringValue(output.VersionId),
+		VersionStages: aws.StringValueSlice(output.VersionStages),
+		CreatedDate:   aws.TimeValue(output.CreatedDate),
+		String:        output.SecretString,
+		Binary:        output.SecretBinary,
+	}, nil
+}
+
+// GetSecretString returns the current value of a secret as text.
+func GetSecretString(ctx context.Context, secretId string, c secretsmanageriface.SecretsManagerAPI) (string, error) {
+	v, err := GetSecretValue(ctx, secretId, "", "", c)
+	if err != nil {
+		return "", err
+	}
+	text, ok := v.Text()
+	if !ok {
+		return "", fmt.Errorf("secret %s has a binary value", secretId)
+	}
+	return text, nil
+}
+
+// Text returns the string value, or the binary value when it is UTF-8 text like the RSA keys
+// written by create-secrets. It is false for other binary values.
+func (v *SecretValue) Text() (string, bool) {
+	if v.String != nil {
+		return *v.String, true
+	}
+	if !utf8.Valid(v.Binary) {
+		return "", false
+	}
+	for _, r := range string(v.Binary) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return "", false
+		}
+	}
+	return string(v.Binary), true
+}
+
+func (v *SecretValue) object() (map[string]json.RawMessage, error) {
+	text, ok := v.Text()
+	if !ok {
+		return nil, fmt.Errorf("secret %s has a binary value", v.Name)
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &object); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object", v.Name)
+	}
+	return object, nil
+}
+
+// Fields returns the fields of a JSON object value, values other than strings as JSON.
+func (v *SecretValue) Fields() (map[string]string, error) {
+	object, err := v.object()
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for k, raw := range object {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			fields[k] = s
+		} else {
+			fields[k] = string(raw)
+		}
+	}
+	return fields, nil
+}
+
+// Field returns a field of a JSON object value.
+func (v *SecretValue) Field(key string) (string, error) {
+	fields, err := v.Fields()
+	if err != nil {
+		return "", err
+	}
+	value, ok := fields[key]
+	if !ok {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return "", fmt.Errorf("secret %s has no key %s, keys are %s", v.Name, key, strings.Join(keys, ", "))
+	}
+	return value, nil
+}
+
+// WriteSecretValue writes the value of a secret, or its field jsonKey:
+//
+//   - value writes the text, or the raw bytes of a binary value.
+//   - json writes the value as JSON: JSON values as they are, text as a string and binary values
+//     base64 encoded.
+//   - env writes export statements for shells, the keys of JSON objects are separate variables.
+func WriteSecretValue(w io.Writer, v *SecretValue, format, jsonKey string) error {
+	switch format {
+	case "value":
+		if jsonKey != "" {
+			value, err := v.Field(jsonKey)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(w, value)
+			return err
+		}
+		if text, ok := v.Text(); ok {
+			_, err := fmt.Fprintln(w, text)
+			return err
+		}
+		_, err := w.Write(v.Binary)
+		return err
+	case "json":
+		var out []byte
+		if jsonKey != "" {
+			// the field is checked first for the error listing the keys
+			if _, err := v.Field(jsonKey); err != nil {
+				return err
+			}
+			object, _ := v.object()
+			out = object[jsonKey]
+		} else if text, ok := v.Text(); ok && json.Valid([]byte(text)) {
+			out = []byte(text)
+		} else {
+			var err error
+			if ok {
+				out, err = json.Marshal(text)
+			} else {
+				out, err = json.Marshal(base64.StdEncoding.EncodeToString(v.Binary))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		var b bytes.Buffer
+		if err := json.Indent(&b, out, "", "  "); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w, b.String())
+		return err
+	case "env":
+		text, ok := v.Text()
+		if !ok {
+			return fmt.Errorf("secret %s has a binary value, use value or json output", v.Name)
+		}
+		if jsonKey != "" {
+			value, err := v.Field(jsonKey)
+			if err != nil {
+				return err
+			}
+			return flowenv.WriteExports(w, map[string]string{flowenv.Name(jsonKey): value})
+		}
+		vars, err := flowenv.FromSecret(v.Name, text)
+		if err != nil {
+			return err
+		}
+		return flowenv.WriteExports(w, vars)
+	default:
+		return fmt.Errorf("unknown output %s, use value, json or env", format)
+	}
+}
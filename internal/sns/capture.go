@@ -0,0 +1,404 @@
+package sns
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	flowsqs "github.com/flow-lab/flow/internal/sqs"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeNotification             = "Notification"
+	TypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	TypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// fifoSuffix ends the names of FIFO topics and queues.
+const fifoSuffix = ".fifo"
+
+// signingCertHost matches hosts SNS serves signing certificates from.
+var signingCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// MessageAttribute is a message attribute as delivered to http endpoints and in sqs envelopes.
+type MessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Message is a message delivered by SNS to http endpoints, and in the envelope of messages delivered to SQS without
+// raw message delivery.
+type Message struct {
+	Type              string                      `json:"Type"`
+	MessageId         string                      `json:"MessageId"`
+	Token             string                      `json:"Token,omitempty"`
+	TopicArn          string                      `json:"TopicArn"`
+	Subject           string                      `json:"Subject,omitempty"`
+	Message           string                      `json:"Message"`
+	Timestamp         string                      `json:"Timestamp"`
+	SignatureVersion  string                      `json:"SignatureVersion"`
+	Signature         string                      `json:"Signature"`
+	SigningCertURL    string                      `json:"SigningCertURL"`
+	SubscribeURL      string                      `json:"SubscribeURL,omitempty"`
+	UnsubscribeURL    string                      `json:"UnsubscribeURL,omitempty"`
+	MessageAttributes map[string]MessageAttribute `json:"MessageAttributes,omitempty"`
+}
+
+// stringToSign builds the string SNS signs, see
+// https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html
+func (m Message) stringToSign() string {
+	var s string
+	add := func(key, value string) {
+		s += key + "\n" + value + "\n"
+	}
+	add("Message", m.Message)
+	add("MessageId", m.MessageId)
+	if m.Type == TypeNotification {
+		if m.Subject != "" {
+			add("Subject", m.Subject)
+		}
+		add("Timestamp", m.Timestamp)
+		add("TopicArn", m.TopicArn)
+		add("Type", m.Type)
+		return s
+	}
+	add("SubscribeURL", m.SubscribeURL)
+	add("Timestamp", m.Timestamp)
+	add("Token", m.Token)
+	add("TopicArn", m.TopicArn)
+	add("Type", m.Type)
+	return s
+}
+
+// CertFetcher returns the certificate for SigningCertURL.
+type CertFetcher func(certURL string) (*x509.Certificate, error)
+
+// Verify verifies the message signature with the certificate returned by fetch.
+func (m Message) Verify(fetch CertFetcher) error {
+	u, err := url.Parse(m.SigningCertURL)
+	if err != nil {
+		return fmt.Errorf("invalid signing cert url: %v", err)
+	}
+	if u.Scheme != "https" || !signingCertHost.MatchString(u.Host) {
+		return fmt.Errorf("signing cert url %s is not an sns url", m.SigningCertURL)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+
+	cert, err := fetch(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("signing cert does not contain rsa public key")
+	}
+
+	var hash crypto.Hash
+	var digest []byte
+	switch m.SignatureVersion {
+	case "1":
+		sum := sha1.Sum([]byte(m.stringToSign()))
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256([]byte(m.stringToSign()))
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return fmt.Errorf("unsupported signature version %s", m.SignatureVersion)
+	}
+
+	if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return nil
+}
+
+// NewCertFetcher returns a CertFetcher downloading certificates over https and caching them.
+func NewCertFetcher(client *http.Client) CertFetcher {
+	var mu sync.Mutex
+	certs := map[string]*x509.Certificate{}
+	return func(certURL string) (*x509.Certificate, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cert, ok := certs[certURL]; ok {
+			return cert, nil
+		}
+
+		resp, err := client.Get(certURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get signing cert: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unable to get signing cert: %s", resp.Status)
+		}
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read signing cert: %v", err)
+		}
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, fmt.Errorf("signing cert is not pem encoded")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse signing cert: %v", err)
+		}
+		certs[certURL] = cert
+		return cert, nil
+	}
+}
+
+// Capture subscribes an http(s) endpoint to a topic and hands over received notifications. It implements
+// http.Handler for the endpoint.
+type Capture struct {
+	snsc     snsiface.SNSAPI
+	topicArn string
+	fetch    CertFetcher
+	out      func(Message) error
+
+	mu              sync.Mutex
+	subscriptionArn string
+	confirmed       chan struct{}
+	confirmOnce     sync.Once
+}
+
+// NewCapture creates a Capture for the topic. Messages are verified with certificates from fetch, nil disables
+// signature verification. out is called for every notification.
+func NewCapture(snsc snsiface.SNSAPI, topicArn string, fetch CertFetcher, out func(Message) error) *Capture {
+	return &Capture{
+		snsc:      snsc,
+		topicArn:  topicArn,
+		fetch:     fetch,
+		out:       out,
+		confirmed: make(chan struct{}),
+	}
+}
+
+// Subscribe subscribes endpoint to the topic. The subscription is confirmed by ServeHTTP once SNS delivers the
+// SubscriptionConfirmation, use Confirmed to wait for it.
+func (c *Capture) Subscribe(ctx context.Context, protocol string, endpoint string) error {
+	out, err := c.snsc.SubscribeWithContext(ctx, &sns.SubscribeInput{
+		TopicArn: aws.String(c.topicArn),
+		Protocol: aws.String(protocol),
+		Endpoint: aws.String(endpoint),
+		// the arn of pending subscriptions, so they can be removed without confirmation
+		ReturnSubscriptionArn: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to subscribe %s: %v", endpoint, err)
+	}
+	c.mu.Lock()
+	c.subscriptionArn = aws.StringValue(out.SubscriptionArn)
+	c.mu.Unlock()
+	return nil
+}
+
+// Confirmed is closed when the subscription got confirmed.
+func (c *Capture) Confirmed() <-chan struct{} {
+	return c.confirmed
+}
+
+// Unsubscribe removes the subscription, confirmed or pending.
+func (c *Capture) Unsubscribe(ctx context.Context) error {
+	c.mu.Lock()
+	subscriptionArn := c.subscriptionArn
+	c.mu.Unlock()
+	if subscriptionArn == "" {
+		return nil
+	}
+
+	_, err := c.snsc.UnsubscribeWithContext(ctx, &sns.UnsubscribeInput{
+		SubscriptionArn: aws.String(subscriptionArn),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to unsubscribe %s: %v", subscriptionArn, err)
+	}
+	return nil
+}
+
+func (c *Capture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var m Message
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&m); err != nil {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+	if m.TopicArn != c.topicArn {
+		http.Error(w, "unknown topic", http.StatusBadRequest)
+		return
+	}
+	if c.fetch != nil {
+		if err := m.Verify(c.fetch); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	switch m.Type {
+	case TypeSubscriptionConfirmation:
+		out, err := c.snsc.ConfirmSubscriptionWithContext(r.Context(), &sns.ConfirmSubscriptionInput{
+			TopicArn: aws.String(m.TopicArn),
+			Token:    aws.String(m.Token),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.mu.Lock()
+		if c.subscriptionArn == "" {
+			c.subscriptionArn = aws.StringValue(out.SubscriptionArn)
+		}
+		c.mu.Unlock()
+		c.confirmOnce.Do(func() { close(c.confirmed) })
+	case TypeNotification:
+		if err := c.out(m); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// CaptureSQS captures notifications using a temporary queue subscribed to the topic. The queue is created with a
+// policy allowing the topic to send messages, and is deleted together with the subscription when ctx is done.
+// An existing queue is never used, it would be deleted. FIFO topics get a FIFO queue, .fifo is added to its name.
+func CaptureSQS(ctx context.Context, snsc snsiface.SNSAPI, sqsc sqsiface.SQSAPI, topicArn string, queueName string, out func(Message) error) (err error) {
+	input := &sqs.CreateQueueInput{}
+	if strings.HasSuffix(topicArn, fifoSuffix) {
+		if !strings.HasSuffix(queueName, fifoSuffix) {
+			queueName += fifoSuffix
+		}
+		input.Attributes = map[string]*string{sqs.QueueAttributeNameFifoQueue: aws.String("true")}
+	}
+	input.QueueName = aws.String(queueName)
+
+	// CreateQueue returns the url of an existing queue with the same attributes
+	_, err = sqsc.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if err == nil {
+		return fmt.Errorf("queue %s already exists, use a name of a queue that does not exist", queueName)
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != sqs.ErrCodeQueueDoesNotExist {
+		return fmt.Errorf("unable to get url of queue %s: %v", queueName, err)
+	}
+
+	createQueueOutput, err := sqsc.CreateQueueWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("unable to create queue %s: %v", queueName, err)
+	}
+	qUrl := createQueueOutput.QueueUrl
+
+	// clean up with a fresh context, ctx is done by then
+	cleanupCtx := context.Background()
+	defer func() {
+		if _, derr := sqsc.DeleteQueueWithContext(cleanupCtx, &sqs.DeleteQueueInput{QueueUrl: qUrl}); derr != nil && err == nil {
+			err = fmt.Errorf("unable to delete queue %s: %v", queueName, derr)
+		}
+	}()
+
+	attrOutput, err := sqsc.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       qUrl,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get queue arn: %v", err)
+	}
+	queueArn := aws.StringValue(attrOutput.Attributes[sqs.QueueAttributeNameQueueArn])
+
+	policy, _, err := flowsqs.AllowSendMessagePolicy("", queueArn, topicArn)
+	if err != nil {
+		return err
+	}
+	_, err = sqsc.SetQueueAttributesWithContext(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   qUrl,
+		Attributes: map[string]*string{sqs.QueueAttributeNamePolicy: aws.String(policy)},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set queue policy: %v", err)
+	}
+
+	subscribeOutput, err := snsc.SubscribeWithContext(ctx, &sns.SubscribeInput{
+		TopicArn:              aws.String(topicArn),
+		Protocol:              aws.String("sqs"),
+		Endpoint:              aws.String(queueArn),
+		ReturnSubscriptionArn: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to subscribe %s: %v", queueName, err)
+	}
+	defer func() {
+		if _, uerr := snsc.UnsubscribeWithContext(cleanupCtx, &sns.UnsubscribeInput{SubscriptionArn: subscribeOutput.SubscriptionArn}); uerr != nil && err == nil {
+			err = fmt.Errorf("unable to unsubscribe %s: %v", queueName, uerr)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		receiveOutput, err := sqsc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            qUrl,
+			MaxNumberOfMessages: aws.Int64(10),
+			WaitTimeSeconds:     aws.Int64(20),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to receive messages: %v", err)
+		}
+
+		var entries []*sqs.DeleteMessageBatchRequestEntry
+		for i, msg := range receiveOutput.Messages {
+			var m Message
+			if err := json.Unmarshal([]byte(aws.StringValue(msg.Body)), &m); err != nil {
+				return fmt.Errorf("unable to parse message: %v", err)
+			}
+			if err := out(m); err != nil {
+				return err
+			}
+			entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(fmt.Sprintf("%d", i)),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+		}
+		if len(entries) > 0 {
+			_, err = sqsc.DeleteMessageBatchWithContext(cleanupCtx, &sqs.DeleteMessageBatchInput{
+				QueueUrl: qUrl,
+				Entries:  entries,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to delete messages: %v", err)
+			}
+		}
+	}
+}
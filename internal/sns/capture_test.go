@@ -0,0 +1,232 @@
+package sns
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testTopicArn = "arn:aws:sns:eu-west-1:111111111111:orders"
+	testCertURL  = "https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-test.pem"
+)
+
+func signingCert(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return key, cert
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, m Message) Message {
+	m.SignatureVersion = "2"
+	m.SigningCertURL = testCertURL
+	sum := sha256.Sum256([]byte(m.stringToSign()))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	assert.Nil(t, err)
+	m.Signature = base64.StdEncoding.EncodeToString(sig)
+	return m
+}
+
+func TestMessage_Verify(t *testing.T) {
+	key, cert := signingCert(t)
+	fetch := func(string) (*x509.Certificate, error) { return cert, nil }
+	m := sign(t, key, Message{
+		Type:      TypeNotification,
+		MessageId: "1",
+		TopicArn:  testTopicArn,
+		Message:   `{"id":"1"}`,
+		Timestamp: "2024-01-01T00:00:00.000Z",
+	})
+
+	assert.Nil(t, m.Verify(fetch))
+
+	tampered := m
+	tampered.Message = `{"id":"2"}`
+	assert.NotNil(t, tampered.Verify(fetch))
+
+	foreign := m
+	foreign.SigningCertURL = "https://example.com/cert.pem"
+	assert.NotNil(t, foreign.Verify(fetch))
+}
+
+type captureSNSMock struct {
+	snsMock
+	confirmed    string
+	unsubscribed []string
+}
+
+func (s *captureSNSMock) SubscribeWithContext(_ aws.Context, input *sns.SubscribeInput, _ ...request.Option) (*sns.SubscribeOutput, error) {
+	if !aws.BoolValue(input.ReturnSubscriptionArn) {
+		return &sns.SubscribeOutput{SubscriptionArn: aws.String("pending confirmation")}, nil
+	}
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String(testTopicArn + ":sub")}, nil
+}
+
+func (s *captureSNSMock) ConfirmSubscriptionWithContext(_ aws.Context, input *sns.ConfirmSubscriptionInput, _ ...request.Option) (*sns.ConfirmSubscriptionOutput, error) {
+	s.confirmed = aws.StringValue(input.Token)
+	return &sns.ConfirmSubscriptionOutput{SubscriptionArn: aws.String(testTopicArn + ":sub")}, nil
+}
+
+func (s *captureSNSMock) UnsubscribeWithContext(_ aws.Context, input *sns.UnsubscribeInput, _ ...request.Option) (*sns.UnsubscribeOutput, error) {
+	s.unsubscribed = append(s.unsubscribed, aws.StringValue(input.SubscriptionArn))
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+func TestCapture_Unsubscribe(t *testing.T) {
+	m := &captureSNSMock{}
+	c := NewCapture(m, testTopicArn, nil, func(Message) error { return nil })
+
+	assert.Nil(t, c.Subscribe(context.Background(), "https", "https://example.com/sns"))
+	assert.Nil(t, c.Unsubscribe(context.Background()))
+
+	assert.Equal(t, []string{testTopicArn + ":sub"}, m.unsubscribed)
+}
+
+func TestCapture_ServeHTTP(t *testing.T) {
+	key, cert := signingCert(t)
+	m := &captureSNSMock{}
+	var received []Message
+	c := NewCapture(m, testTopicArn, func(string) (*x509.Certificate, error) { return cert, nil }, func(msg Message) error {
+		received = append(received, msg)
+		return nil
+	})
+
+	post := func(msg Message) int {
+		b, err := json.Marshal(msg)
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b)))
+		return rec.Code
+	}
+
+	code := post(sign(t, key, Message{
+		Type:         TypeSubscriptionConfirmation,
+		MessageId:    "0",
+		Token:        "token",
+		TopicArn:     testTopicArn,
+		Message:      "confirm",
+		SubscribeURL: "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription",
+		Timestamp:    "2024-01-01T00:00:00.000Z",
+	}))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "token", m.confirmed)
+	select {
+	case <-c.Confirmed():
+	default:
+		t.Fatal("subscription not confirmed")
+	}
+
+	notification := sign(t, key, Message{
+		Type:      TypeNotification,
+		MessageId: "1",
+		TopicArn:  testTopicArn,
+		Message:   "hello",
+		Timestamp: "2024-01-01T00:00:01.000Z",
+	})
+	assert.Equal(t, http.StatusOK, post(notification))
+
+	unsigned := notification
+	unsigned.Signature = base64.StdEncoding.EncodeToString([]byte("invalid"))
+	assert.Equal(t, http.StatusForbidden, post(unsigned))
+
+	assert.Len(t, received, 1)
+	assert.Equal(t, "hello", received[0].Message)
+	assert.Nil(t, c.Unsubscribe(context.Background()))
+}
+
+type captureSQSMock struct {
+	sqsiface.SQSAPI
+	created *sqs.CreateQueueInput
+	deleted bool
+}
+
+func (m *captureSQSMock) GetQueueUrlWithContext(_ aws.Context, input *sqs.GetQueueUrlInput, _ ...request.Option) (*sqs.GetQueueUrlOutput, error) {
+	if aws.StringValue(input.QueueName) == "orders-queue" {
+		return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/111111111111/orders-queue")}, nil
+	}
+	return nil, awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist", nil)
+}
+
+func (m *captureSQSMock) CreateQueueWithContext(_ aws.Context, input *sqs.CreateQueueInput, _ ...request.Option) (*sqs.CreateQueueOutput, error) {
+	m.created = input
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/111111111111/" + aws.StringValue(input.QueueName))}, nil
+}
+
+func (m *captureSQSMock) GetQueueAttributesWithContext(_ aws.Context, _ *sqs.GetQueueAttributesInput, _ ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{sqs.QueueAttributeNameQueueArn: aws.String(testQueueArn)}}, nil
+}
+
+func (m *captureSQSMock) SetQueueAttributesWithContext(_ aws.Context, _ *sqs.SetQueueAttributesInput, _ ...request.Option) (*sqs.SetQueueAttributesOutput, error) {
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (m *captureSQSMock) DeleteQueueWithContext(_ aws.Context, _ *sqs.DeleteQueueInput, _ ...request.Option) (*sqs.DeleteQueueOutput, error) {
+	m.deleted = true
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+func TestCaptureSQS(t *testing.T) {
+	out := func(Message) error { return nil }
+	// done before the first receive, so only setup and cleanup run
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("Should refuse an existing queue", func(t *testing.T) {
+		m := &captureSQSMock{}
+		err := CaptureSQS(ctx, &captureSNSMock{}, m, testTopicArn, "orders-queue", out)
+
+		assert.EqualError(t, err, "queue orders-queue already exists, use a name of a queue that does not exist")
+		assert.Nil(t, m.created)
+		assert.False(t, m.deleted)
+	})
+
+	t.Run("Should create and delete a temporary queue", func(t *testing.T) {
+		m := &captureSQSMock{}
+		s := &captureSNSMock{}
+		err := CaptureSQS(ctx, s, m, testTopicArn, "flow-capture", out)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "flow-capture", aws.StringValue(m.created.QueueName))
+		assert.Nil(t, m.created.Attributes)
+		assert.True(t, m.deleted)
+		assert.Equal(t, []string{testTopicArn + ":sub"}, s.unsubscribed)
+	})
+
+	t.Run("Should create a fifo queue for a fifo topic", func(t *testing.T) {
+		m := &captureSQSMock{}
+		err := CaptureSQS(ctx, &captureSNSMock{}, m, testTopicArn+".fifo", "flow-capture", out)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "flow-capture.fifo", aws.StringValue(m.created.QueueName))
+		assert.Equal(t, "true", aws.StringValue(m.created.Attributes[sqs.QueueAttributeNameFifoQueue]))
+	})
+}
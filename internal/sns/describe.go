@@ -0,0 +1,218 @@
+package sns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// pendingConfirmation is the subscription arn of subscriptions that are not confirmed yet.
+const pendingConfirmation = "PendingConfirmation"
+
+// Subscription represents topic subscription with its delivery settings.
+type Subscription struct {
+	SubscriptionArn    string `json:"subscriptionArn"`
+	Protocol           string `json:"protocol"`
+	Endpoint           string `json:"endpoint"`
+	FilterPolicy       string `json:"filterPolicy,omitempty"`
+	FilterPolicyScope  string `json:"filterPolicyScope,omitempty"`
+	RawMessageDelivery bool   `json:"rawMessageDelivery"`
+	DeadLetterQueueArn string `json:"deadLetterQueueArn,omitempty"`
+}
+
+// Topic represents topic with its subscriptions.
+type Topic struct {
+	TopicArn      string         `json:"topicArn"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// Name returns topic name.
+func (t Topic) Name() string {
+	return arnResource(t.TopicArn)
+}
+
+// arnResource returns the last part of an arn, e.g. topic or queue name.
+func arnResource(arn string) string {
+	split := strings.Split(arn, ":")
+	return split[len(split)-1]
+}
+
+// Describe returns topics with their subscriptions. The topic is given by name or arn, or all topics with the name
+// prefix are described when topic is empty.
+func (f flowSNSClient) Describe(ctx context.Context, topic string, prefix string) ([]Topic, error) {
+	var topicArns []string
+	if topic != "" {
+		topicArn, err := f.TopicArn(ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+		topicArns = append(topicArns, topicArn)
+	} else {
+		err := f.ListTopicsPagesWithContext(ctx, &sns.ListTopicsInput{}, func(output *sns.ListTopicsOutput, lastPage bool) bool {
+			for _, t := range output.Topics {
+				if strings.HasPrefix(arnResource(aws.StringValue(t.TopicArn)), prefix) {
+					topicArns = append(topicArns, aws.StringValue(t.TopicArn))
+				}
+			}
+			return lastPage == false
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list topics: %v", err)
+		}
+	}
+
+	var topics []Topic
+	for _, topicArn := range topicArns {
+		t := Topic{TopicArn: topicArn, Subscriptions: []Subscription{}}
+		var subs []*sns.Subscription
+		err := f.ListSubscriptionsByTopicPagesWithContext(ctx, &sns.ListSubscriptionsByTopicInput{
+			TopicArn: aws.String(topicArn),
+		}, func(output *sns.ListSubscriptionsByTopicOutput, lastPage bool) bool {
+			subs = append(subs, output.Subscriptions...)
+			return lastPage == false
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list subscriptions of %s: %v", topicArn, err)
+		}
+
+		for _, sub := range subs {
+			s := Subscription{
+				SubscriptionArn: aws.StringValue(sub.SubscriptionArn),
+				Protocol:        aws.StringValue(sub.Protocol),
+				Endpoint:        aws.StringValue(sub.Endpoint),
+			}
+			if s.SubscriptionArn != pendingConfirmation {
+				out, err := f.GetSubscriptionAttributesWithContext(ctx, &sns.GetSubscriptionAttributesInput{
+					SubscriptionArn: sub.SubscriptionArn,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("unable to get attributes of %s: %v", s.SubscriptionArn, err)
+				}
+				s.FilterPolicy = aws.StringValue(out.Attributes["FilterPolicy"])
+				s.FilterPolicyScope = aws.StringValue(out.Attributes["FilterPolicyScope"])
+				s.RawMessageDelivery = aws.StringValue(out.Attributes["RawMessageDelivery"]) == "true"
+				if rp := aws.StringValue(out.Attributes["RedrivePolicy"]); rp != "" {
+					var redrivePolicy struct {
+						DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+					}
+					if err := json.Unmarshal([]byte(rp), &redrivePolicy); err == nil {
+						s.DeadLetterQueueArn = redrivePolicy.DeadLetterTargetArn
+					}
+				}
+			}
+			t.Subscriptions = append(t.Subscriptions, s)
+		}
+		topics = append(topics, t)
+	}
+
+	return topics, nil
+}
+
+// WriteTopicsTable writes one row per subscription.
+func WriteTopicsTable(w io.Writer, topics []Topic) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "TOPIC\tPROTOCOL\tENDPOINT\tRAW\tDLQ\tFILTER POLICY"); err != nil {
+		return err
+	}
+	for _, t := range topics {
+		if len(t.Subscriptions) == 0 {
+			if _, err := fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t-\n", t.Name()); err != nil {
+				return err
+			}
+		}
+		for _, s := range t.Subscriptions {
+			dlq := "-"
+			if s.DeadLetterQueueArn != "" {
+				dlq = arnResource(s.DeadLetterQueueArn)
+			}
+			filter := "-"
+			if s.FilterPolicy != "" {
+				filter = compactJSON(s.FilterPolicy)
+				if s.FilterPolicyScope == FilterPolicyScopeMessageBody {
+					filter = "body:" + filter
+				}
+			}
+			if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\n", t.Name(), s.Protocol, s.Endpoint, s.RawMessageDelivery, dlq, filter); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteTopicsDot writes fan-out graph in Graphviz dot format.
+func WriteTopicsDot(w io.Writer, topics []Topic) error {
+	var b strings.Builder
+	b.WriteString("digraph sns {\n  rankdir=LR;\n")
+	for _, t := range topics {
+		fmt.Fprintf(&b, "  %q [shape=box];\n", t.Name())
+		for _, s := range t.Subscriptions {
+			label := s.Protocol
+			if s.FilterPolicy != "" {
+				label += " " + compactJSON(s.FilterPolicy)
+			}
+			fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", t.Name(), endpointName(s), label)
+			if s.DeadLetterQueueArn != "" {
+				fmt.Fprintf(&b, "  %q -> %q [label=\"dlq\", style=dashed];\n", endpointName(s), arnResource(s.DeadLetterQueueArn))
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteTopicsMermaid writes fan-out graph as mermaid flowchart.
+func WriteTopicsMermaid(w io.Writer, topics []Topic) error {
+	ids := map[string]string{}
+	id := func(name string) string {
+		if v, ok := ids[name]; ok {
+			return v
+		}
+		ids[name] = fmt.Sprintf("n%d", len(ids))
+		return ids[name]
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, t := range topics {
+		fmt.Fprintf(&b, "  %s[%q]\n", id(t.Name()), t.Name())
+		for _, s := range t.Subscriptions {
+			label := s.Protocol
+			if s.FilterPolicy != "" {
+				label += " filtered"
+			}
+			fmt.Fprintf(&b, "  %s -->|%s| %s([%q])\n", id(t.Name()), label, id(endpointName(s)), endpointName(s))
+			if s.DeadLetterQueueArn != "" {
+				fmt.Fprintf(&b, "  %s -.->|dlq| %s[(%q)]\n", id(endpointName(s)), id(arnResource(s.DeadLetterQueueArn)), arnResource(s.DeadLetterQueueArn))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// endpointName returns short name of the endpoint, e.g. queue or function name for arns.
+func endpointName(s Subscription) string {
+	if strings.HasPrefix(s.Endpoint, "arn:") {
+		return arnResource(s.Endpoint)
+	}
+	return s.Endpoint
+}
+
+func compactJSON(s string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
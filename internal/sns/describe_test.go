@@ -0,0 +1,55 @@
+package sns
+
+import (
+	"bytes"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type describeSNSMock struct {
+	snsMock
+}
+
+func (s *describeSNSMock) ListSubscriptionsByTopicPagesWithContext(_ aws.Context, input *sns.ListSubscriptionsByTopicInput, fn func(*sns.ListSubscriptionsByTopicOutput, bool) bool, _ ...request.Option) error {
+	fn(&sns.ListSubscriptionsByTopicOutput{Subscriptions: []*sns.Subscription{
+		{SubscriptionArn: aws.String(aws.StringValue(input.TopicArn) + ":1"), Protocol: aws.String("sqs"), Endpoint: aws.String("arn:aws:sqs:eu-west-1:111111111111:orders-queue")},
+		{SubscriptionArn: aws.String(pendingConfirmation), Protocol: aws.String("https"), Endpoint: aws.String("https://example.com")},
+	}}, true)
+	return nil
+}
+
+func (s *describeSNSMock) GetSubscriptionAttributesWithContext(_ aws.Context, input *sns.GetSubscriptionAttributesInput, _ ...request.Option) (*sns.GetSubscriptionAttributesOutput, error) {
+	return &sns.GetSubscriptionAttributesOutput{Attributes: map[string]*string{
+		"FilterPolicy":       aws.String(`{"eventType": ["order_placed"]}`),
+		"RawMessageDelivery": aws.String("true"),
+		"RedrivePolicy":      aws.String(`{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:111111111111:orders-dlq"}`),
+	}}, nil
+}
+
+func Test_flowSNSClient_Describe(t *testing.T) {
+	client, err := NewSNSClient(&describeSNSMock{})
+	assert.Nil(t, err)
+
+	topics, err := client.Describe(context.Background(), "", "orders")
+
+	assert.Nil(t, err)
+	assert.Len(t, topics, 2)
+	assert.Equal(t, "orders-dlq", topics[0].Name())
+	sub := topics[1].Subscriptions[0]
+	assert.Equal(t, `{"eventType": ["order_placed"]}`, sub.FilterPolicy)
+	assert.True(t, sub.RawMessageDelivery)
+	assert.Equal(t, "arn:aws:sqs:eu-west-1:111111111111:orders-dlq", sub.DeadLetterQueueArn)
+	assert.Equal(t, Subscription{SubscriptionArn: pendingConfirmation, Protocol: "https", Endpoint: "https://example.com"}, topics[1].Subscriptions[1])
+
+	var table, dot, mermaid bytes.Buffer
+	assert.Nil(t, WriteTopicsTable(&table, topics))
+	assert.Nil(t, WriteTopicsDot(&dot, topics))
+	assert.Nil(t, WriteTopicsMermaid(&mermaid, topics))
+	assert.Contains(t, table.String(), `{"eventType":["order_placed"]}`)
+	assert.Contains(t, dot.String(), `"orders" -> "orders-queue"`)
+	assert.Contains(t, mermaid.String(), "-.->|dlq|")
+}
@@ -0,0 +1,239 @@
+package sns
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	FilterPolicyScopeMessageAttributes = "MessageAttributes"
+	FilterPolicyScopeMessageBody       = "MessageBody"
+)
+
+// MatchFilterPolicy evaluates a subscription filter policy against message and its attributes the way SNS does, see
+// https://docs.aws.amazon.com/sns/latest/dg/sns-subscription-filter-policies.html. An empty policy matches every
+// message. Attributes are used with MessageAttributes scope, message body must be json with MessageBody scope.
+func MatchFilterPolicy(policy string, scope string, message string, attributes map[string]MessageAttribute) (bool, error) {
+	if strings.TrimSpace(policy) == "" {
+		return true, nil
+	}
+
+	var p map[string]interface{}
+	if err := json.Unmarshal([]byte(policy), &p); err != nil {
+		return false, fmt.Errorf("invalid filter policy: %v", err)
+	}
+
+	if scope == FilterPolicyScopeMessageBody {
+		var body interface{}
+		if err := json.Unmarshal([]byte(message), &body); err != nil {
+			// SNS does not deliver messages with non json body to subscriptions filtering on body
+			return false, nil
+		}
+		return matchObject(p, body)
+	}
+
+	values := map[string]interface{}{}
+	for name, attr := range attributes {
+		switch attr.Type {
+		case "String":
+			values[name] = attr.Value
+		case "Number":
+			n, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return false, fmt.Errorf("attribute %s is not a number: %s", name, attr.Value)
+			}
+			values[name] = n
+		case "String.Array":
+			var arr []interface{}
+			if err := json.Unmarshal([]byte(attr.Value), &arr); err != nil {
+				return false, fmt.Errorf("attribute %s is not a json array: %s", name, attr.Value)
+			}
+			values[name] = arr
+		}
+	}
+	return matchObject(p, values)
+}
+
+// matchObject matches policy against json object. Every key of the policy must match, $or matches when any of its
+// policies match.
+func matchObject(policy map[string]interface{}, obj interface{}) (bool, error) {
+	fields, _ := obj.(map[string]interface{})
+	for key, rule := range policy {
+		if key == "$or" {
+			alternatives, ok := rule.([]interface{})
+			if !ok {
+				return false, fmt.Errorf("$or must be an array")
+			}
+			matched := false
+			for _, alt := range alternatives {
+				altPolicy, ok := alt.(map[string]interface{})
+				if !ok {
+					return false, fmt.Errorf("$or must contain objects")
+				}
+				m, err := matchObject(altPolicy, obj)
+				if err != nil {
+					return false, err
+				}
+				if m {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false, nil
+			}
+			continue
+		}
+
+		value, present := fields[key]
+		var m bool
+		var err error
+		switch r := rule.(type) {
+		case map[string]interface{}:
+			m, err = matchObject(r, value)
+		case []interface{}:
+			m, err = matchRules(r, value, present)
+		default:
+			return false, fmt.Errorf("rule for %s must be an array or object", key)
+		}
+		if err != nil || !m {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchRules matches value against rules, any rule has to match. Arrays match when any element matches.
+func matchRules(rules []interface{}, value interface{}, present bool) (bool, error) {
+	values := []interface{}{value}
+	if arr, ok := value.([]interface{}); ok {
+		values = arr
+	}
+
+	for _, rule := range rules {
+		if op, ok := rule.(map[string]interface{}); ok {
+			if exists, ok := op["exists"]; ok {
+				if exists == present {
+					return true, nil
+				}
+				continue
+			}
+		}
+		if !present {
+			continue
+		}
+		for _, v := range values {
+			m, err := matchRule(rule, v)
+			if err != nil {
+				return false, err
+			}
+			if m {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func matchRule(rule interface{}, value interface{}) (bool, error) {
+	op, ok := rule.(map[string]interface{})
+	if !ok {
+		return equal(rule, value), nil
+	}
+
+	for name, arg := range op {
+		switch name {
+		case "prefix":
+			s, ok := value.(string)
+			return ok && strings.HasPrefix(s, fmt.Sprint(arg)), nil
+		case "suffix":
+			s, ok := value.(string)
+			return ok && strings.HasSuffix(s, fmt.Sprint(arg)), nil
+		case "equals-ignore-case":
+			s, ok := value.(string)
+			return ok && strings.EqualFold(s, fmt.Sprint(arg)), nil
+		case "anything-but":
+			switch a := arg.(type) {
+			case []interface{}:
+				for _, elem := range a {
+					if equal(elem, value) {
+						return false, nil
+					}
+				}
+				return true, nil
+			case map[string]interface{}:
+				m, err := matchRule(a, value)
+				return !m, err
+			default:
+				return !equal(a, value), nil
+			}
+		case "numeric":
+			return matchNumeric(arg, value)
+		case "cidr":
+			_, ipNet, err := net.ParseCIDR(fmt.Sprint(arg))
+			if err != nil {
+				return false, fmt.Errorf("invalid cidr %v", arg)
+			}
+			s, ok := value.(string)
+			ip := net.ParseIP(s)
+			return ok && ip != nil && ipNet.Contains(ip), nil
+		default:
+			return false, fmt.Errorf("unsupported operator %s", name)
+		}
+	}
+	return false, nil
+}
+
+// matchNumeric matches conditions like [">", 0, "<=", 150].
+func matchNumeric(arg interface{}, value interface{}) (bool, error) {
+	conditions, ok := arg.([]interface{})
+	if !ok || len(conditions)%2 != 0 {
+		return false, fmt.Errorf("numeric must be an array of operator and value pairs")
+	}
+	n, ok := value.(float64)
+	if !ok {
+		return false, nil
+	}
+
+	for i := 0; i < len(conditions); i += 2 {
+		op, _ := conditions[i].(string)
+		limit, ok := conditions[i+1].(float64)
+		if !ok {
+			return false, fmt.Errorf("numeric value must be a number")
+		}
+		var m bool
+		switch op {
+		case "=":
+			m = n == limit
+		case "<":
+			m = n < limit
+		case "<=":
+			m = n <= limit
+		case ">":
+			m = n > limit
+		case ">=":
+			m = n >= limit
+		default:
+			return false, fmt.Errorf("unsupported numeric operator %s", op)
+		}
+		if !m {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func equal(rule interface{}, value interface{}) bool {
+	switch r := rule.(type) {
+	case float64:
+		v, ok := value.(float64)
+		// SNS compares numbers with 5 digits of precision
+		return ok && math.Abs(r-v) < 1e-5
+	default:
+		return rule == value
+	}
+}
@@ -0,0 +1,57 @@
+package sns
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatchFilterPolicy(t *testing.T) {
+	attributes := map[string]MessageAttribute{
+		"eventType": {Type: "String", Value: "order_placed"},
+		"price":     {Type: "Number", Value: "100"},
+		"stores":    {Type: "String.Array", Value: `["oslo","bergen"]`},
+		"ip":        {Type: "String", Value: "10.0.0.12"},
+	}
+	body := `{"order":{"status":"PLACED","total":42},"source":"web"}`
+
+	tests := []struct {
+		name   string
+		policy string
+		scope  string
+		want   bool
+	}{
+		{"empty policy", "", "", true},
+		{"exact match", `{"eventType":["order_placed","order_cancelled"]}`, "", true},
+		{"exact mismatch", `{"eventType":["order_cancelled"]}`, "", false},
+		{"missing attribute", `{"customer":["vip"]}`, "", false},
+		{"prefix", `{"eventType":[{"prefix":"order_"}]}`, "", true},
+		{"suffix", `{"eventType":[{"suffix":"_cancelled"}]}`, "", false},
+		{"equals ignore case", `{"eventType":[{"equals-ignore-case":"ORDER_PLACED"}]}`, "", true},
+		{"anything but", `{"eventType":[{"anything-but":["order_cancelled"]}]}`, "", true},
+		{"anything but prefix", `{"eventType":[{"anything-but":{"prefix":"order"}}]}`, "", false},
+		{"numeric range", `{"price":[{"numeric":[">",0,"<=",100]}]}`, "", true},
+		{"numeric exact", `{"price":[100]}`, "", true},
+		{"numeric out of range", `{"price":[{"numeric":["<",100]}]}`, "", false},
+		{"string array", `{"stores":["bergen"]}`, "", true},
+		{"exists", `{"price":[{"exists":true}],"customer":[{"exists":false}]}`, "", true},
+		{"cidr", `{"ip":[{"cidr":"10.0.0.0/24"}]}`, "", true},
+		{"all keys must match", `{"eventType":["order_placed"],"price":[{"numeric":[">",500]}]}`, "", false},
+		{"or", `{"$or":[{"price":[{"numeric":[">",500]}]},{"stores":["oslo"]}]}`, "", true},
+		{"body nested", `{"order":{"status":["PLACED"],"total":[{"numeric":[">=",40]}]}}`, FilterPolicyScopeMessageBody, true},
+		{"body mismatch", `{"source":["mobile"]}`, FilterPolicyScopeMessageBody, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchFilterPolicy(tt.policy, tt.scope, body, attributes)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("invalid policy", func(t *testing.T) {
+		_, err := MatchFilterPolicy(`{"eventType":[{"unknown":1}]}`, "", body, attributes)
+
+		assert.NotNil(t, err)
+	})
+}
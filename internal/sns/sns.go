@@ -0,0 +1,301 @@
+package sns
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"golang.org/x/time/rate"
+	"io"
+	"strings"
+	"sync"
+)
+
+// maxBatchSize is the maximum number of entries SNS accepts in a single PublishBatch request.
+const maxBatchSize = 10
+
+// maxBatchBytes is the maximum total payload of a PublishBatch request.
+const maxBatchBytes = 256 * 1024
+
+// maxErrors is the number of error messages kept in PublishResult.
+const maxErrors = 10
+
+// FlowSNSClient is a client for interacting with SNS API and wraps the standard client.
+type FlowSNSClient interface {
+	TopicArn(ctx context.Context, topic string) (string, error)
+	Publish(ctx context.Context, topicArn string, entries []PublishEntry, opts PublishOptions) *PublishResult
+	Describe(ctx context.Context, topic string, prefix string) ([]Topic, error)
+	SubscribeQueue(ctx context.Context, topicArn string, queueArn string, qs QueueSubscription) (string, error)
+	UnsubscribeQueue(ctx context.Context, topicArn string, queueArn string) ([]string, error)
+}
+
+// PublishEntry is a single message to publish. It is also the line format of jsonl input files.
+type PublishEntry struct {
+	Message                string                                `json:"message"`
+	Subject                string                                `json:"subject,omitempty"`
+	MessageAttributes      map[string]*sns.MessageAttributeValue `json:"messageAttributes,omitempty"`
+	MessageGroupId         string                                `json:"messageGroupId,omitempty"`
+	MessageDeduplicationId string                                `json:"messageDeduplicationId,omitempty"`
+}
+
+// PublishOptions controls throughput of Publish.
+type PublishOptions struct {
+	// Rate is the max number of messages per second, 0 means no limit.
+	Rate float64
+	// Concurrency is the number of concurrent requests.
+	Concurrency int
+	// BatchSize is the number of messages in one request, 1 uses Publish and up to 10 uses PublishBatch.
+	BatchSize int
+}
+
+// PublishResult summarises Publish.
+type PublishResult struct {
+	Published int      `json:"published"`
+	Failed    int      `json:"failed"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// NewSNSClient creates a new flow sns client.
+func NewSNSClient(s snsiface.SNSAPI) (FlowSNSClient, error) {
+	client := flowSNSClient{s}
+	return &client, nil
+}
+
+type flowSNSClient struct {
+	snsiface.SNSAPI
+}
+
+// TopicArn resolves topic given by arn or exact name to topic arn.
+func (f flowSNSClient) TopicArn(ctx context.Context, topic string) (string, error) {
+	if strings.HasPrefix(topic, "arn:") {
+		return topic, nil
+	}
+
+	var topicArn string
+	err := f.ListTopicsPagesWithContext(ctx, &sns.ListTopicsInput{}, func(output *sns.ListTopicsOutput, lastPage bool) bool {
+		for _, t := range output.Topics {
+			if strings.HasSuffix(aws.StringValue(t.TopicArn), ":"+topic) {
+				topicArn = aws.StringValue(t.TopicArn)
+				return false
+			}
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to list topics: %v", err)
+	}
+	if topicArn == "" {
+		return "", fmt.Errorf("topic %s not found", topic)
+	}
+
+	return topicArn, nil
+}
+
+// Publish publishes entries to the topic with at most opts.Concurrency requests in flight and at most opts.Rate
+// messages per second. Failed messages do not stop publishing, they are counted in the result.
+func (f flowSNSClient) Publish(ctx context.Context, topicArn string, entries []PublishEntry, opts PublishOptions) *PublishResult {
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if batchSize > maxBatchSize {
+		batchSize = maxBatchSize
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limiter := rate.NewLimiter(rate.Inf, batchSize)
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), batchSize)
+	}
+
+	batches := make(chan []PublishEntry)
+	go func() {
+		defer close(batches)
+		for _, batch := range splitBatches(entries, batchSize) {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	result := &PublishResult{}
+	var mu sync.Mutex
+	record := func(published int, failed int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Published += published
+		result.Failed += failed
+		if err != nil && len(result.Errors) < maxErrors {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := limiter.WaitN(ctx, len(batch)); err != nil {
+					record(0, len(batch), err)
+					continue
+				}
+				if batchSize == 1 {
+					record(f.publish(ctx, topicArn, batch[0]))
+				} else {
+					record(f.publishBatch(ctx, topicArn, batch))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+// size returns the payload size of the entry as SNS counts it, the message and its attributes.
+func (e PublishEntry) size() int {
+	n := len(e.Message)
+	for name, attr := range e.MessageAttributes {
+		n += len(name) + len(aws.StringValue(attr.DataType)) + len(aws.StringValue(attr.StringValue)) + len(attr.BinaryValue)
+	}
+	return n
+}
+
+// splitBatches splits entries into batches of at most batchSize entries and maxBatchBytes. An entry larger than
+// maxBatchBytes gets a batch of its own, SNS rejects it.
+func splitBatches(entries []PublishEntry, batchSize int) [][]PublishEntry {
+	var batches [][]PublishEntry
+	var batch []PublishEntry
+	bytes := 0
+	for _, e := range entries {
+		if len(batch) == batchSize || (len(batch) > 0 && bytes+e.size() > maxBatchBytes) {
+			batches = append(batches, batch)
+			batch, bytes = nil, 0
+		}
+		batch = append(batch, e)
+		bytes += e.size()
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// WithDefaults sets subject, message group id and message attributes of entries that do not have them, attributes
+// of an entry take precedence over the defaults.
+func WithDefaults(entries []PublishEntry, defaults PublishEntry) []PublishEntry {
+	for i, e := range entries {
+		if e.Subject == "" {
+			entries[i].Subject = defaults.Subject
+		}
+		if e.MessageGroupId == "" {
+			entries[i].MessageGroupId = defaults.MessageGroupId
+		}
+		if len(defaults.MessageAttributes) > 0 {
+			attributes := make(map[string]*sns.MessageAttributeValue, len(defaults.MessageAttributes)+len(e.MessageAttributes))
+			for k, v := range defaults.MessageAttributes {
+				attributes[k] = v
+			}
+			for k, v := range e.MessageAttributes {
+				attributes[k] = v
+			}
+			entries[i].MessageAttributes = attributes
+		}
+	}
+	return entries
+}
+
+func (f flowSNSClient) publish(ctx context.Context, topicArn string, e PublishEntry) (int, int, error) {
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(topicArn),
+		Message:           aws.String(e.Message),
+		MessageAttributes: e.MessageAttributes,
+	}
+	if e.Subject != "" {
+		input.Subject = aws.String(e.Subject)
+	}
+	if e.MessageGroupId != "" {
+		input.MessageGroupId = aws.String(e.MessageGroupId)
+	}
+	if e.MessageDeduplicationId != "" {
+		input.MessageDeduplicationId = aws.String(e.MessageDeduplicationId)
+	}
+
+	if _, err := f.PublishWithContext(ctx, input); err != nil {
+		return 0, 1, fmt.Errorf("unable to publish: %v", err)
+	}
+	return 1, 0, nil
+}
+
+func (f flowSNSClient) publishBatch(ctx context.Context, topicArn string, batch []PublishEntry) (int, int, error) {
+	input := &sns.PublishBatchInput{
+		TopicArn: aws.String(topicArn),
+	}
+	for i, e := range batch {
+		entry := &sns.PublishBatchRequestEntry{
+			Id:                aws.String(fmt.Sprintf("%d", i)),
+			Message:           aws.String(e.Message),
+			MessageAttributes: e.MessageAttributes,
+		}
+		if e.Subject != "" {
+			entry.Subject = aws.String(e.Subject)
+		}
+		if e.MessageGroupId != "" {
+			entry.MessageGroupId = aws.String(e.MessageGroupId)
+		}
+		if e.MessageDeduplicationId != "" {
+			entry.MessageDeduplicationId = aws.String(e.MessageDeduplicationId)
+		}
+		input.PublishBatchRequestEntries = append(input.PublishBatchRequestEntries, entry)
+	}
+
+	out, err := f.PublishBatchWithContext(ctx, input)
+	if err != nil {
+		return 0, len(batch), fmt.Errorf("unable to publish batch: %v", err)
+	}
+	if len(out.Failed) > 0 {
+		return len(out.Successful), len(out.Failed), fmt.Errorf("unable to publish %d message(s): %s", len(out.Failed), aws.StringValue(out.Failed[0].Message))
+	}
+	return len(out.Successful), 0, nil
+}
+
+// ReadEntries reads jsonl input, one message per line. Lines that are json objects with a "message" field are read as
+// PublishEntry, any other line is published as is.
+func ReadEntries(r io.Reader) ([]PublishEntry, error) {
+	var entries []PublishEntry
+	scanner := bufio.NewScanner(r)
+	// sns messages can be up to 256 KB
+	scanner.Buffer(make([]byte, 64*1024), 512*1024)
+	for lineNr := 1; scanner.Scan(); lineNr++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &fields); err == nil {
+			if _, ok := fields["message"]; ok {
+				var e PublishEntry
+				if err := json.Unmarshal([]byte(line), &e); err != nil {
+					return nil, fmt.Errorf("line %d: %v", lineNr, err)
+				}
+				entries = append(entries, e)
+				continue
+			}
+		}
+		entries = append(entries, PublishEntry{Message: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
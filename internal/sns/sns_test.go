@@ -0,0 +1,149 @@
+package sns
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type snsMock struct {
+	snsiface.SNSAPI
+	mu        sync.Mutex
+	published []string
+}
+
+func (s *snsMock) ListTopicsPagesWithContext(_ aws.Context, _ *sns.ListTopicsInput, fn func(*sns.ListTopicsOutput, bool) bool, _ ...request.Option) error {
+	fn(&sns.ListTopicsOutput{Topics: []*sns.Topic{
+		{TopicArn: aws.String("arn:aws:sns:eu-west-1:111111111111:orders-dlq")},
+		{TopicArn: aws.String("arn:aws:sns:eu-west-1:111111111111:orders")},
+	}}, true)
+	return nil
+}
+
+func (s *snsMock) PublishWithContext(_ aws.Context, input *sns.PublishInput, _ ...request.Option) (*sns.PublishOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if aws.StringValue(input.Message) == "fail" {
+		return nil, fmt.Errorf("failed")
+	}
+	s.published = append(s.published, aws.StringValue(input.Message))
+	return &sns.PublishOutput{}, nil
+}
+
+func (s *snsMock) PublishBatchWithContext(_ aws.Context, input *sns.PublishBatchInput, _ ...request.Option) (*sns.PublishBatchOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := &sns.PublishBatchOutput{}
+	for _, e := range input.PublishBatchRequestEntries {
+		if aws.StringValue(e.Message) == "fail" {
+			out.Failed = append(out.Failed, &sns.BatchResultErrorEntry{Id: e.Id, Message: aws.String("failed")})
+			continue
+		}
+		s.published = append(s.published, aws.StringValue(e.Message))
+		out.Successful = append(out.Successful, &sns.PublishBatchResultEntry{Id: e.Id})
+	}
+	return out, nil
+}
+
+func Test_flowSNSClient_TopicArn(t *testing.T) {
+	client, err := NewSNSClient(&snsMock{})
+	assert.Nil(t, err)
+
+	arn, err := client.TopicArn(context.Background(), "orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "arn:aws:sns:eu-west-1:111111111111:orders", arn)
+
+	arn, err = client.TopicArn(context.Background(), "arn:aws:sns:eu-west-1:111111111111:other")
+	assert.Nil(t, err)
+	assert.Equal(t, "arn:aws:sns:eu-west-1:111111111111:other", arn)
+
+	_, err = client.TopicArn(context.Background(), "order")
+	assert.NotNil(t, err)
+}
+
+func Test_flowSNSClient_Publish(t *testing.T) {
+	entries := []PublishEntry{{Message: "1"}, {Message: "fail"}, {Message: "3"}, {Message: "4"}, {Message: "5"}}
+
+	t.Run("Should publish one by one", func(t *testing.T) {
+		m := &snsMock{}
+		client, err := NewSNSClient(m)
+		assert.Nil(t, err)
+
+		res := client.Publish(context.Background(), "arn", entries, PublishOptions{Concurrency: 3})
+
+		assert.Equal(t, 4, res.Published)
+		assert.Equal(t, 1, res.Failed)
+		assert.Len(t, res.Errors, 1)
+		assert.Len(t, m.published, 4)
+	})
+
+	t.Run("Should publish in batches", func(t *testing.T) {
+		m := &snsMock{}
+		client, err := NewSNSClient(m)
+		assert.Nil(t, err)
+
+		res := client.Publish(context.Background(), "arn", entries, PublishOptions{Concurrency: 2, BatchSize: 2, Rate: 1000})
+
+		assert.Equal(t, 4, res.Published)
+		assert.Equal(t, 1, res.Failed)
+		assert.Len(t, m.published, 4)
+	})
+}
+
+func TestReadEntries(t *testing.T) {
+	input := `{"message":"hello","subject":"greeting","messageGroupId":"g1"}
+
+{"id":"1","status":"ACTIVE"}
+plain text
+`
+	entries, err := ReadEntries(strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []PublishEntry{
+		{Message: "hello", Subject: "greeting", MessageGroupId: "g1"},
+		{Message: `{"id":"1","status":"ACTIVE"}`},
+		{Message: "plain text"},
+	}, entries)
+}
+
+func TestSplitBatches(t *testing.T) {
+	large := strings.Repeat("x", 100*1024)
+	entries := []PublishEntry{{Message: large}, {Message: large}, {Message: large}, {Message: "1"}, {Message: "2"}, {Message: "3"}}
+
+	var sizes []int
+	for _, b := range splitBatches(entries, 3) {
+		sizes = append(sizes, len(b))
+	}
+
+	assert.Equal(t, []int{2, 3, 1}, sizes)
+}
+
+func TestWithDefaults(t *testing.T) {
+	attr := func(v string) *sns.MessageAttributeValue {
+		return &sns.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+	}
+	entries := []PublishEntry{
+		{Message: "1"},
+		{Message: "2", Subject: "own", MessageAttributes: map[string]*sns.MessageAttributeValue{"eventType": attr("CREATED")}},
+	}
+
+	entries = WithDefaults(entries, PublishEntry{
+		Subject:           "default",
+		MessageGroupId:    "g1",
+		MessageAttributes: map[string]*sns.MessageAttributeValue{"eventType": attr("UPDATED"), "source": attr("flow")},
+	})
+
+	assert.Equal(t, "default", entries[0].Subject)
+	assert.Equal(t, "g1", entries[0].MessageGroupId)
+	assert.Equal(t, "UPDATED", aws.StringValue(entries[0].MessageAttributes["eventType"].StringValue))
+	assert.Equal(t, "own", entries[1].Subject)
+	assert.Equal(t, "CREATED", aws.StringValue(entries[1].MessageAttributes["eventType"].StringValue))
+	assert.Equal(t, "flow", aws.StringValue(entries[1].MessageAttributes["source"].StringValue))
+}
@@ -0,0 +1,112 @@
+package sns
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"strconv"
+)
+
+// QueueSubscription are the delivery settings of an sqs subscription.
+type QueueSubscription struct {
+	RawMessageDelivery bool
+	// FilterPolicy is removed when empty.
+	FilterPolicy string
+	// FilterPolicyScope is MessageAttributes or MessageBody, SNS uses MessageAttributes when empty.
+	FilterPolicyScope string
+}
+
+func (q QueueSubscription) attributes() map[string]*string {
+	attr := map[string]*string{
+		"RawMessageDelivery": aws.String(strconv.FormatBool(q.RawMessageDelivery)),
+	}
+	if q.FilterPolicy != "" {
+		attr["FilterPolicy"] = aws.String(q.FilterPolicy)
+		if q.FilterPolicyScope != "" {
+			attr["FilterPolicyScope"] = aws.String(q.FilterPolicyScope)
+		}
+	}
+	return attr
+}
+
+// SubscribeQueue subscribes the queue to the topic, or updates the delivery settings when the queue is subscribed
+// already. Returns the subscription arn. The queue policy has to allow the topic to send messages.
+func (f flowSNSClient) SubscribeQueue(ctx context.Context, topicArn string, queueArn string, qs QueueSubscription) (string, error) {
+	subscriptionArns, err := f.queueSubscriptions(ctx, topicArn, queueArn)
+	if err != nil {
+		return "", err
+	}
+
+	if len(subscriptionArns) == 0 {
+		out, err := f.SubscribeWithContext(ctx, &sns.SubscribeInput{
+			TopicArn:              aws.String(topicArn),
+			Protocol:              aws.String("sqs"),
+			Endpoint:              aws.String(queueArn),
+			Attributes:            qs.attributes(),
+			ReturnSubscriptionArn: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to subscribe %s: %v", queueArn, err)
+		}
+		return aws.StringValue(out.SubscriptionArn), nil
+	}
+
+	subscriptionArn := subscriptionArns[0]
+	attr := qs.attributes()
+	if qs.FilterPolicy == "" {
+		// empty json object removes the filter policy
+		attr["FilterPolicy"] = aws.String("{}")
+	}
+	for name, value := range attr {
+		_, err := f.SetSubscriptionAttributesWithContext(ctx, &sns.SetSubscriptionAttributesInput{
+			SubscriptionArn: aws.String(subscriptionArn),
+			AttributeName:   aws.String(name),
+			AttributeValue:  value,
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to set %s of %s: %v", name, subscriptionArn, err)
+		}
+	}
+
+	return subscriptionArn, nil
+}
+
+// UnsubscribeQueue removes all subscriptions of the queue to the topic and returns their arns.
+func (f flowSNSClient) UnsubscribeQueue(ctx context.Context, topicArn string, queueArn string) ([]string, error) {
+	subscriptionArns, err := f.queueSubscriptions(ctx, topicArn, queueArn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscriptionArn := range subscriptionArns {
+		_, err := f.UnsubscribeWithContext(ctx, &sns.UnsubscribeInput{
+			SubscriptionArn: aws.String(subscriptionArn),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to unsubscribe %s: %v", subscriptionArn, err)
+		}
+	}
+
+	return subscriptionArns, nil
+}
+
+// queueSubscriptions returns arns of the confirmed sqs subscriptions of the queue to the topic.
+func (f flowSNSClient) queueSubscriptions(ctx context.Context, topicArn string, queueArn string) ([]string, error) {
+	var subscriptionArns []string
+	err := f.ListSubscriptionsByTopicPagesWithContext(ctx, &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(topicArn),
+	}, func(output *sns.ListSubscriptionsByTopicOutput, lastPage bool) bool {
+		for _, s := range output.Subscriptions {
+			if aws.StringValue(s.Protocol) == "sqs" && aws.StringValue(s.Endpoint) == queueArn && aws.StringValue(s.SubscriptionArn) != pendingConfirmation {
+				subscriptionArns = append(subscriptionArns, aws.StringValue(s.SubscriptionArn))
+			}
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list subscriptions of %s: %v", topicArn, err)
+	}
+
+	return subscriptionArns, nil
+}
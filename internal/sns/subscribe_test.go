@@ -0,0 +1,65 @@
+package sns
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const testQueueArn = "arn:aws:sqs:eu-west-1:111111111111:orders-queue"
+
+type subscribeSNSMock struct {
+	snsMock
+	subscriptions map[string]map[string]string
+}
+
+func (s *subscribeSNSMock) ListSubscriptionsByTopicPagesWithContext(_ aws.Context, input *sns.ListSubscriptionsByTopicInput, fn func(*sns.ListSubscriptionsByTopicOutput, bool) bool, _ ...request.Option) error {
+	out := &sns.ListSubscriptionsByTopicOutput{}
+	for arn := range s.subscriptions {
+		out.Subscriptions = append(out.Subscriptions, &sns.Subscription{SubscriptionArn: aws.String(arn), Protocol: aws.String("sqs"), Endpoint: aws.String(testQueueArn)})
+	}
+	fn(out, true)
+	return nil
+}
+
+func (s *subscribeSNSMock) SubscribeWithContext(_ aws.Context, input *sns.SubscribeInput, _ ...request.Option) (*sns.SubscribeOutput, error) {
+	s.subscriptions["sub-1"] = aws.StringValueMap(input.Attributes)
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String("sub-1")}, nil
+}
+
+func (s *subscribeSNSMock) SetSubscriptionAttributesWithContext(_ aws.Context, input *sns.SetSubscriptionAttributesInput, _ ...request.Option) (*sns.SetSubscriptionAttributesOutput, error) {
+	s.subscriptions[aws.StringValue(input.SubscriptionArn)][aws.StringValue(input.AttributeName)] = aws.StringValue(input.AttributeValue)
+	return &sns.SetSubscriptionAttributesOutput{}, nil
+}
+
+func (s *subscribeSNSMock) UnsubscribeWithContext(_ aws.Context, input *sns.UnsubscribeInput, _ ...request.Option) (*sns.UnsubscribeOutput, error) {
+	delete(s.subscriptions, aws.StringValue(input.SubscriptionArn))
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+func Test_flowSNSClient_SubscribeQueue(t *testing.T) {
+	m := &subscribeSNSMock{subscriptions: map[string]map[string]string{}}
+	client, err := NewSNSClient(m)
+	assert.Nil(t, err)
+
+	arn, err := client.SubscribeQueue(context.Background(), testTopicArn, testQueueArn, QueueSubscription{
+		RawMessageDelivery: true,
+		FilterPolicy:       `{"eventType":["order_placed"]}`,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "sub-1", arn)
+	assert.Equal(t, map[string]string{"RawMessageDelivery": "true", "FilterPolicy": `{"eventType":["order_placed"]}`}, m.subscriptions["sub-1"])
+
+	arn, err = client.SubscribeQueue(context.Background(), testTopicArn, testQueueArn, QueueSubscription{})
+	assert.Nil(t, err)
+	assert.Equal(t, "sub-1", arn)
+	assert.Equal(t, map[string]string{"RawMessageDelivery": "false", "FilterPolicy": "{}"}, m.subscriptions["sub-1"])
+
+	arns, err := client.UnsubscribeQueue(context.Background(), testTopicArn, testQueueArn)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sub-1"}, arns)
+	assert.Empty(t, m.subscriptions)
+}
@@ -0,0 +1,227 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// policyVersion is the IAM policy language version used for new policies.
+const policyVersion = "2012-10-17"
+
+// Policy is an IAM resource policy. Statements are kept as raw maps, so statements written by others are preserved
+// as they are.
+type Policy struct {
+	Version   string                   `json:"Version"`
+	Id        string                   `json:"Id,omitempty"`
+	Statement []map[string]interface{} `json:"Statement"`
+}
+
+// sendMessageStatementId returns id of the statement allowing sourceArn to send messages. It follows the id used by
+// the AWS console for topic subscriptions.
+func sendMessageStatementId(sourceArn string) string {
+	return fmt.Sprintf("topic-subscription-%s", sourceArn)
+}
+
+// allowsSendMessage reports whether stmt is the statement allowing sourceArn to send messages, either added by flow
+// or written by hand with an aws:SourceArn condition.
+func allowsSendMessage(stmt map[string]interface{}, sourceArn string) bool {
+	if isSendMessageStatement(stmt, sourceArn) {
+		return true
+	}
+	if stmt["Effect"] != "Allow" || !hasAction(stmt["Action"], "sqs:SendMessage") {
+		return false
+	}
+	condition, _ := stmt["Condition"].(map[string]interface{})
+	for _, op := range []string{"ArnEquals", "ArnLike", "StringEquals"} {
+		values, _ := condition[op].(map[string]interface{})
+		if hasValue(values["aws:SourceArn"], sourceArn) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSendMessageStatement reports whether stmt is the statement added by flow for sourceArn.
+func isSendMessageStatement(stmt map[string]interface{}, sourceArn string) bool {
+	return stmt["Sid"] == sendMessageStatementId(sourceArn)
+}
+
+// hasValue reports whether a condition value, a string or a list of strings, contains value.
+func hasValue(v interface{}, value string) bool {
+	switch a := v.(type) {
+	case string:
+		return a == value
+	case []interface{}:
+		for _, elem := range a {
+			if elem == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasAction(action interface{}, name string) bool {
+	switch a := action.(type) {
+	case string:
+		return a == name || a == "sqs:*"
+	case []interface{}:
+		for _, elem := range a {
+			if hasAction(elem, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sendMessageStatement allows the AWS service resource given by sourceArn, e.g. SNS topic, to send messages to the
+// queue.
+func sendMessageStatement(queueArn string, sourceArn string) map[string]interface{} {
+	return map[string]interface{}{
+		"Sid":       sendMessageStatementId(sourceArn),
+		"Effect":    "Allow",
+		"Principal": map[string]interface{}{"Service": "sns.amazonaws.com"},
+		"Action":    "sqs:SendMessage",
+		"Resource":  queueArn,
+		"Condition": map[string]interface{}{
+			"ArnEquals": map[string]interface{}{"aws:SourceArn": sourceArn},
+		},
+	}
+}
+
+func parsePolicy(policy string) (*Policy, error) {
+	p := &Policy{Version: policyVersion}
+	if policy == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(policy), p); err != nil {
+		return nil, fmt.Errorf("unable to parse queue policy: %v", err)
+	}
+	return p, nil
+}
+
+// AllowSendMessagePolicy adds a statement allowing sourceArn to send messages to the queue to the existing queue
+// policy. Returns the policy unchanged and false when the statement is already there.
+func AllowSendMessagePolicy(policy string, queueArn string, sourceArn string) (string, bool, error) {
+	p, err := parsePolicy(policy)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, stmt := range p.Statement {
+		if allowsSendMessage(stmt, sourceArn) {
+			return policy, false, nil
+		}
+	}
+	p.Statement = append(p.Statement, sendMessageStatement(queueArn, sourceArn))
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
+
+// RevokeSendMessagePolicy removes the statement added by AllowSendMessagePolicy for sourceArn, statements written by
+// hand are kept. Returns empty policy when no statements are left, and false when there was nothing to remove.
+func RevokeSendMessagePolicy(policy string, sourceArn string) (string, bool, error) {
+	p, err := parsePolicy(policy)
+	if err != nil {
+		return "", false, err
+	}
+
+	var statements []map[string]interface{}
+	for _, stmt := range p.Statement {
+		if !isSendMessageStatement(stmt, sourceArn) {
+			statements = append(statements, stmt)
+		}
+	}
+	if len(statements) == len(p.Statement) {
+		return policy, false, nil
+	}
+	if len(statements) == 0 {
+		return "", true, nil
+	}
+	p.Statement = statements
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
+
+// AllowSendMessage merges a statement allowing sourceArn to send messages into the queue policy and returns the queue
+// arn. The policy is only updated when the statement is missing.
+func (f flowSQSClient) AllowSendMessage(ctx context.Context, queueName string, sourceArn string) (string, error) {
+	qUrl, attr, err := f.queueAttributes(ctx, queueName, sqs.QueueAttributeNameQueueArn, sqs.QueueAttributeNamePolicy)
+	if err != nil {
+		return "", err
+	}
+	queueArn := attr[sqs.QueueAttributeNameQueueArn]
+
+	policy, changed, err := AllowSendMessagePolicy(attr[sqs.QueueAttributeNamePolicy], queueArn, sourceArn)
+	if err != nil || !changed {
+		return queueArn, err
+	}
+
+	return queueArn, f.setPolicy(ctx, qUrl, policy)
+}
+
+// RevokeSendMessage removes the statement allowing sourceArn to send messages added by AllowSendMessage from the queue
+// policy and returns the queue arn.
+func (f flowSQSClient) RevokeSendMessage(ctx context.Context, queueName string, sourceArn string) (string, error) {
+	qUrl, attr, err := f.queueAttributes(ctx, queueName, sqs.QueueAttributeNameQueueArn, sqs.QueueAttributeNamePolicy)
+	if err != nil {
+		return "", err
+	}
+	queueArn := attr[sqs.QueueAttributeNameQueueArn]
+
+	policy, changed, err := RevokeSendMessagePolicy(attr[sqs.QueueAttributeNamePolicy], sourceArn)
+	if err != nil || !changed {
+		return queueArn, err
+	}
+
+	return queueArn, f.setPolicy(ctx, qUrl, policy)
+}
+
+// QueueArn returns arn of the queue with exactly the given name.
+func (f flowSQSClient) QueueArn(ctx context.Context, queueName string) (string, error) {
+	_, attr, err := f.queueAttributes(ctx, queueName, sqs.QueueAttributeNameQueueArn)
+	if err != nil {
+		return "", err
+	}
+	return attr[sqs.QueueAttributeNameQueueArn], nil
+}
+
+func (f flowSQSClient) queueAttributes(ctx context.Context, queueName string, names ...string) (string, map[string]string, error) {
+	qUrl, err := f.QueueURL(ctx, queueName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	out, err := f.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(qUrl),
+		AttributeNames: aws.StringSlice(names),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to get attributes of %s: %v", queueName, err)
+	}
+
+	return qUrl, aws.StringValueMap(out.Attributes), nil
+}
+
+func (f flowSQSClient) setPolicy(ctx context.Context, qUrl string, policy string) error {
+	_, err := f.SetQueueAttributesWithContext(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   aws.String(qUrl),
+		Attributes: map[string]*string{sqs.QueueAttributeNamePolicy: aws.String(policy)},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set queue policy: %v", err)
+	}
+	return nil
+}
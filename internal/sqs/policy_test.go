@@ -0,0 +1,138 @@
+package sqs
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const (
+	testQueueArn = "arn:aws:sqs:eu-west-1:111111111111:orders"
+	testTopicArn = "arn:aws:sns:eu-west-1:111111111111:orders"
+)
+
+func TestAllowSendMessagePolicy(t *testing.T) {
+	t.Run("Should create policy", func(t *testing.T) {
+		policy, changed, err := AllowSendMessagePolicy("", testQueueArn, testTopicArn)
+
+		assert.Nil(t, err)
+		assert.True(t, changed)
+		assert.Contains(t, policy, `"aws:SourceArn":"arn:aws:sns:eu-west-1:111111111111:orders"`)
+		assert.Contains(t, policy, `"Version":"2012-10-17"`)
+	})
+
+	t.Run("Should keep existing statements and not duplicate", func(t *testing.T) {
+		existing := `{"Version":"2012-10-17","Statement":[{"Sid":"other","Effect":"Allow","Principal":"*","Action":"sqs:ReceiveMessage","Resource":"arn"}]}`
+
+		policy, changed, err := AllowSendMessagePolicy(existing, testQueueArn, testTopicArn)
+		assert.Nil(t, err)
+		assert.True(t, changed)
+		assert.Contains(t, policy, `"Sid":"other"`)
+
+		again, changed, err := AllowSendMessagePolicy(policy, testQueueArn, testTopicArn)
+		assert.Nil(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, policy, again)
+	})
+
+	t.Run("Should detect statement written by hand", func(t *testing.T) {
+		existing := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":["sqs:SendMessage"],"Resource":"arn","Condition":{"ArnLike":{"aws:SourceArn":"arn:aws:sns:eu-west-1:111111111111:orders"}}}]}`
+
+		policy, changed, err := AllowSendMessagePolicy(existing, testQueueArn, testTopicArn)
+
+		assert.Nil(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, existing, policy)
+	})
+
+	t.Run("Should detect source arn in a list", func(t *testing.T) {
+		existing := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"sqs:SendMessage","Resource":"arn","Condition":{"ArnEquals":{"aws:SourceArn":["arn:aws:sns:eu-west-1:111111111111:other","arn:aws:sns:eu-west-1:111111111111:orders"]}}}]}`
+
+		_, changed, err := AllowSendMessagePolicy(existing, testQueueArn, testTopicArn)
+
+		assert.Nil(t, err)
+		assert.False(t, changed)
+	})
+
+	t.Run("Should fail on invalid policy", func(t *testing.T) {
+		_, _, err := AllowSendMessagePolicy("{", testQueueArn, testTopicArn)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRevokeSendMessagePolicy(t *testing.T) {
+	existing := `{"Version":"2012-10-17","Statement":[{"Sid":"other","Effect":"Allow","Principal":"*","Action":"sqs:ReceiveMessage","Resource":"arn"}]}`
+	policy, _, err := AllowSendMessagePolicy(existing, testQueueArn, testTopicArn)
+	assert.Nil(t, err)
+
+	revoked, changed, err := RevokeSendMessagePolicy(policy, testTopicArn)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, revoked, `"Sid":"other"`)
+	assert.NotContains(t, revoked, testTopicArn)
+
+	_, changed, err = RevokeSendMessagePolicy(revoked, testTopicArn)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	t.Run("Should keep statements written by hand", func(t *testing.T) {
+		handWritten := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"sqs:SendMessage","Resource":"arn","Condition":{"ArnEquals":{"aws:SourceArn":"arn:aws:sns:eu-west-1:111111111111:orders"}}}]}`
+
+		policy, changed, err := RevokeSendMessagePolicy(handWritten, testTopicArn)
+
+		assert.Nil(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, handWritten, policy)
+	})
+
+	only, _, err := AllowSendMessagePolicy("", testQueueArn, testTopicArn)
+	assert.Nil(t, err)
+	empty, changed, err := RevokeSendMessagePolicy(only, testTopicArn)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "", empty)
+}
+
+type policySQSMock struct {
+	SQSMock
+	policy  string
+	updates int
+}
+
+func (f *policySQSMock) GetQueueAttributesWithContext(_ aws.Context, input *sqs.GetQueueAttributesInput, _ ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
+	attr := map[string]*string{sqs.QueueAttributeNameQueueArn: aws.String(testQueueArn)}
+	if f.policy != "" {
+		attr[sqs.QueueAttributeNamePolicy] = aws.String(f.policy)
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: attr}, nil
+}
+
+func (f *policySQSMock) SetQueueAttributesWithContext(_ aws.Context, input *sqs.SetQueueAttributesInput, _ ...request.Option) (*sqs.SetQueueAttributesOutput, error) {
+	f.policy = aws.StringValue(input.Attributes[sqs.QueueAttributeNamePolicy])
+	f.updates++
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func Test_flowSQSClient_AllowSendMessage(t *testing.T) {
+	m := &policySQSMock{}
+	client, err := NewSQSClient(m)
+	assert.Nil(t, err)
+
+	queueArn, err := client.AllowSendMessage(context.Background(), "test-queue-name", testTopicArn)
+	assert.Nil(t, err)
+	assert.Equal(t, testQueueArn, queueArn)
+	assert.Contains(t, m.policy, testTopicArn)
+
+	_, err = client.AllowSendMessage(context.Background(), "test-queue-name", testTopicArn)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, m.updates)
+
+	_, err = client.RevokeSendMessage(context.Background(), "test-queue-name", testTopicArn)
+	assert.Nil(t, err)
+	assert.Equal(t, "", m.policy)
+	assert.Equal(t, 2, m.updates)
+}
@@ -0,0 +1,216 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"strconv"
+	"strings"
+)
+
+const (
+	fifoSuffix = ".fifo"
+	// dlqRetentionPeriod is the retention of created dead-letter queues, the maximum SQS allows (14 days).
+	dlqRetentionPeriod = int64(1209600)
+)
+
+// QueueConfig describes queue settings. Nil and empty fields are left unchanged.
+type QueueConfig struct {
+	// FIFO creates a FIFO queue, the queue name must end with .fifo.
+	FIFO                      bool
+	ContentBasedDeduplication *bool
+	// DeadLetterQueueName is created when it does not exist and set as redrive target.
+	DeadLetterQueueName string
+	MaxReceiveCount     int64
+	// VisibilityTimeout in seconds.
+	VisibilityTimeout *int64
+	// MessageRetentionPeriod in seconds.
+	MessageRetentionPeriod *int64
+	// DelaySeconds is the default delivery delay in seconds.
+	DelaySeconds *int64
+	// KmsMasterKeyId enables SSE-KMS with the given key id, alias or arn.
+	KmsMasterKeyId string
+	// SqsManagedSSE enables SSE-SQS, it is ignored when KmsMasterKeyId is set.
+	SqsManagedSSE *bool
+	Tags          map[string]string
+}
+
+// attributes returns queue attributes for the config. dlqArn is used for redrive policy when not empty.
+func (q QueueConfig) attributes(dlqArn string) (map[string]*string, error) {
+	attr := map[string]*string{}
+	if q.FIFO {
+		attr[sqs.QueueAttributeNameFifoQueue] = aws.String("true")
+	}
+	if q.ContentBasedDeduplication != nil {
+		attr[sqs.QueueAttributeNameContentBasedDeduplication] = aws.String(strconv.FormatBool(*q.ContentBasedDeduplication))
+	}
+	if q.VisibilityTimeout != nil {
+		attr[sqs.QueueAttributeNameVisibilityTimeout] = aws.String(strconv.FormatInt(*q.VisibilityTimeout, 10))
+	}
+	if q.MessageRetentionPeriod != nil {
+		attr[sqs.QueueAttributeNameMessageRetentionPeriod] = aws.String(strconv.FormatInt(*q.MessageRetentionPeriod, 10))
+	}
+	if q.DelaySeconds != nil {
+		attr[sqs.QueueAttributeNameDelaySeconds] = aws.String(strconv.FormatInt(*q.DelaySeconds, 10))
+	}
+	if q.KmsMasterKeyId != "" {
+		attr[sqs.QueueAttributeNameKmsMasterKeyId] = aws.String(q.KmsMasterKeyId)
+	} else if q.SqsManagedSSE != nil {
+		attr[sqs.QueueAttributeNameSqsManagedSseEnabled] = aws.String(strconv.FormatBool(*q.SqsManagedSSE))
+	}
+	if dlqArn != "" {
+		if q.MaxReceiveCount < 1 {
+			return nil, fmt.Errorf("max receive count must be greater than 0")
+		}
+		rp, err := json.Marshal(struct {
+			DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+			MaxReceiveCount     string `json:"maxReceiveCount"`
+		}{
+			DeadLetterTargetArn: dlqArn,
+			MaxReceiveCount:     strconv.FormatInt(q.MaxReceiveCount, 10),
+		})
+		if err != nil {
+			return nil, err
+		}
+		attr[sqs.QueueAttributeNameRedrivePolicy] = aws.String(string(rp))
+	}
+
+	return attr, nil
+}
+
+// Create creates a queue with the given config and returns its url. The dead-letter queue is created first
+// when it is configured and does not exist yet, it gets the same type, encryption and tags as the queue.
+func (f flowSQSClient) Create(ctx context.Context, queueName string, cfg QueueConfig) (string, error) {
+	if cfg.FIFO != strings.HasSuffix(queueName, fifoSuffix) {
+		return "", fmt.Errorf("fifo queue name must end with %s", fifoSuffix)
+	}
+
+	dlqArn, err := f.ensureDeadLetterQueue(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	attr, err := cfg.attributes(dlqArn)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := f.CreateQueueWithContext(ctx, &sqs.CreateQueueInput{
+		QueueName:  aws.String(queueName),
+		Attributes: attr,
+		Tags:       aws.StringMap(cfg.Tags),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create queue %s: %v", queueName, err)
+	}
+
+	return aws.StringValue(out.QueueUrl), nil
+}
+
+// Update updates attributes and tags of an existing queue.
+func (f flowSQSClient) Update(ctx context.Context, queueName string, cfg QueueConfig) error {
+	err, qUrl := f.resolveSQSURL(ctx, queueName)
+	if err != nil {
+		return err
+	}
+
+	dlqArn, err := f.ensureDeadLetterQueue(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	// FifoQueue can only be set on create
+	cfg.FIFO = false
+	attr, err := cfg.attributes(dlqArn)
+	if err != nil {
+		return err
+	}
+
+	if len(attr) > 0 {
+		_, err = f.SetQueueAttributesWithContext(ctx, &sqs.SetQueueAttributesInput{
+			QueueUrl:   aws.String(qUrl),
+			Attributes: attr,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to set attributes for %s: %v", queueName, err)
+		}
+	}
+
+	if len(cfg.Tags) > 0 {
+		_, err = f.TagQueueWithContext(ctx, &sqs.TagQueueInput{
+			QueueUrl: aws.String(qUrl),
+			Tags:     aws.StringMap(cfg.Tags),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to tag %s: %v", queueName, err)
+		}
+	}
+
+	return nil
+}
+
+// Remove deletes the queue.
+func (f flowSQSClient) Remove(ctx context.Context, queueName string) error {
+	err, qUrl := f.resolveSQSURL(ctx, queueName)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.DeleteQueueWithContext(ctx, &sqs.DeleteQueueInput{
+		QueueUrl: aws.String(qUrl),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete queue %s: %v", queueName, err)
+	}
+
+	return nil
+}
+
+// ensureDeadLetterQueue creates the dead-letter queue from cfg when it does not exist and returns its arn. Returns
+// empty arn when no dead-letter queue is configured.
+func (f flowSQSClient) ensureDeadLetterQueue(ctx context.Context, cfg QueueConfig) (string, error) {
+	if cfg.DeadLetterQueueName == "" {
+		return "", nil
+	}
+
+	err, dlqUrl := f.resolveSQSURL(ctx, cfg.DeadLetterQueueName)
+	if err != nil && err != errQueueNotFound {
+		return "", err
+	}
+	if err == errQueueNotFound {
+		if cfg.FIFO != strings.HasSuffix(cfg.DeadLetterQueueName, fifoSuffix) {
+			return "", fmt.Errorf("dead-letter queue must be of the same type as the queue")
+		}
+		dlqCfg := QueueConfig{
+			FIFO:                   cfg.FIFO,
+			MessageRetentionPeriod: aws.Int64(dlqRetentionPeriod),
+			KmsMasterKeyId:         cfg.KmsMasterKeyId,
+			SqsManagedSSE:          cfg.SqsManagedSSE,
+		}
+		attr, err := dlqCfg.attributes("")
+		if err != nil {
+			return "", err
+		}
+		out, err := f.CreateQueueWithContext(ctx, &sqs.CreateQueueInput{
+			QueueName:  aws.String(cfg.DeadLetterQueueName),
+			Attributes: attr,
+			Tags:       aws.StringMap(cfg.Tags),
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to create dead-letter queue %s: %v", cfg.DeadLetterQueueName, err)
+		}
+		dlqUrl = aws.StringValue(out.QueueUrl)
+	}
+
+	out, err := f.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(dlqUrl),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to get dead-letter queue arn: %v", err)
+	}
+
+	return aws.StringValue(out.Attributes[sqs.QueueAttributeNameQueueArn]), nil
+}
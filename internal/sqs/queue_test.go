@@ -0,0 +1,141 @@
+package sqs
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type queueSQSMock struct {
+	SQSMock
+	// getUrlErr fails every queue url lookup
+	getUrlErr  error
+	created    map[string]*sqs.CreateQueueInput
+	attributes *sqs.SetQueueAttributesInput
+	tags       *sqs.TagQueueInput
+	deleted    *sqs.DeleteQueueInput
+}
+
+func (f *queueSQSMock) GetQueueUrlWithContext(ctx aws.Context, input *sqs.GetQueueUrlInput, opts ...request.Option) (*sqs.GetQueueUrlOutput, error) {
+	if f.getUrlErr != nil {
+		return nil, f.getUrlErr
+	}
+	return f.SQSMock.GetQueueUrlWithContext(ctx, input, opts...)
+}
+
+func (f *queueSQSMock) CreateQueueWithContext(_ aws.Context, input *sqs.CreateQueueInput, _ ...request.Option) (*sqs.CreateQueueOutput, error) {
+	if f.created == nil {
+		f.created = map[string]*sqs.CreateQueueInput{}
+	}
+	f.created[aws.StringValue(input.QueueName)] = input
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/111111111111/" + aws.StringValue(input.QueueName))}, nil
+}
+
+func (f *queueSQSMock) GetQueueAttributesWithContext(_ aws.Context, input *sqs.GetQueueAttributesInput, _ ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{
+		sqs.QueueAttributeNameQueueArn: aws.String("arn:aws:sqs:eu-west-1:111111111111:test-dlq"),
+	}}, nil
+}
+
+func (f *queueSQSMock) SetQueueAttributesWithContext(_ aws.Context, input *sqs.SetQueueAttributesInput, _ ...request.Option) (*sqs.SetQueueAttributesOutput, error) {
+	f.attributes = input
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *queueSQSMock) TagQueueWithContext(_ aws.Context, input *sqs.TagQueueInput, _ ...request.Option) (*sqs.TagQueueOutput, error) {
+	f.tags = input
+	return &sqs.TagQueueOutput{}, nil
+}
+
+func (f *queueSQSMock) DeleteQueueWithContext(_ aws.Context, input *sqs.DeleteQueueInput, _ ...request.Option) (*sqs.DeleteQueueOutput, error) {
+	f.deleted = input
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+func Test_flowSQSClient_Create(t *testing.T) {
+	t.Run("Should create queue with dead-letter queue", func(t *testing.T) {
+		m := &queueSQSMock{}
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		qUrl, err := client.Create(context.Background(), "orders", QueueConfig{
+			DeadLetterQueueName: "test-dlq",
+			MaxReceiveCount:     5,
+			VisibilityTimeout:   aws.Int64(30),
+			SqsManagedSSE:       aws.Bool(true),
+			Tags:                map[string]string{"team": "flow"},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/111111111111/orders", qUrl)
+		assert.Contains(t, m.created, "test-dlq")
+		attr := m.created["orders"].Attributes
+		assert.Equal(t, `{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:111111111111:test-dlq","maxReceiveCount":"5"}`, aws.StringValue(attr[sqs.QueueAttributeNameRedrivePolicy]))
+		assert.Equal(t, "30", aws.StringValue(attr[sqs.QueueAttributeNameVisibilityTimeout]))
+		assert.Equal(t, "true", aws.StringValue(attr[sqs.QueueAttributeNameSqsManagedSseEnabled]))
+		assert.Equal(t, "flow", aws.StringValue(m.created["orders"].Tags["team"]))
+	})
+
+	t.Run("Should reject fifo queue without suffix", func(t *testing.T) {
+		client, err := NewSQSClient(&queueSQSMock{})
+		assert.Nil(t, err)
+
+		_, err = client.Create(context.Background(), "orders", QueueConfig{FIFO: true})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should use existing dead-letter queue", func(t *testing.T) {
+		m := &queueSQSMock{}
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		_, err = client.Create(context.Background(), "orders", QueueConfig{DeadLetterQueueName: "test-queue-name", MaxReceiveCount: 3})
+
+		assert.Nil(t, err)
+		assert.NotContains(t, m.created, "test-queue-name")
+	})
+
+	t.Run("Should not create dead-letter queue when the lookup fails", func(t *testing.T) {
+		m := &queueSQSMock{getUrlErr: awserr.New("AccessDenied", "Access to the resource is denied", nil)}
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		_, err = client.Create(context.Background(), "orders", QueueConfig{DeadLetterQueueName: "test-dlq", MaxReceiveCount: 3})
+
+		assert.NotNil(t, err)
+		assert.Empty(t, m.created)
+	})
+}
+
+func Test_flowSQSClient_Update(t *testing.T) {
+	m := &queueSQSMock{}
+	client, err := NewSQSClient(m)
+	assert.Nil(t, err)
+
+	err = client.Update(context.Background(), "test-queue-name", QueueConfig{
+		MessageRetentionPeriod: aws.Int64(3600),
+		KmsMasterKeyId:         "alias/aws/sqs",
+		Tags:                   map[string]string{"team": "flow"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "3600", aws.StringValue(m.attributes.Attributes[sqs.QueueAttributeNameMessageRetentionPeriod]))
+	assert.Equal(t, "alias/aws/sqs", aws.StringValue(m.attributes.Attributes[sqs.QueueAttributeNameKmsMasterKeyId]))
+	assert.Equal(t, "flow", aws.StringValue(m.tags.Tags["team"]))
+}
+
+func Test_flowSQSClient_Remove(t *testing.T) {
+	m := &queueSQSMock{}
+	client, err := NewSQSClient(m)
+	assert.Nil(t, err)
+
+	err = client.Remove(context.Background(), "test-queue-name")
+
+	assert.Nil(t, err)
+	assert.NotNil(t, m.deleted)
+}
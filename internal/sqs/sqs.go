@@ -5,14 +5,78 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
+// maxBatchSize is the maximum number of entries SQS accepts in a single batch request.
+const maxBatchSize = 10
+
+// errQueueNotFound is returned by resolveSQSURL for queues that do not exist.
+var errQueueNotFound = errors.New("sqs url not found")
+
 // FlowSQSClient is a client for interacting with SQS API and wraps the standard client.
 type FlowSQSClient interface {
 	Delete(ctx context.Context, queueName string, receiptHandles []string) error
+	DeleteMatching(ctx context.Context, queueName string, filter MessageFilter, opts DeleteMatchingOptions) (*DeleteMatchingResult, error)
+	Create(ctx context.Context, queueName string, cfg QueueConfig) (string, error)
+	Update(ctx context.Context, queueName string, cfg QueueConfig) error
+	Remove(ctx context.Context, queueName string) error
+	Stats(ctx context.Context, queueNamePrefix string) ([]QueueStats, error)
+	QueueURL(ctx context.Context, queueName string) (string, error)
+	QueueArn(ctx context.Context, queueName string) (string, error)
+	AllowSendMessage(ctx context.Context, queueName string, sourceArn string) (string, error)
+	RevokeSendMessage(ctx context.Context, queueName string, sourceArn string) (string, error)
+}
+
+// MessageFilter selects messages by body and attributes. All given conditions must match, an empty filter
+// matches every message.
+type MessageFilter struct {
+	// Body is matched against the message body.
+	Body *regexp.Regexp
+	// Attributes are matched by exact value against message attributes and system attributes.
+	Attributes map[string]string
+}
+
+// Match reports whether message matches the filter.
+func (m MessageFilter) Match(msg *sqs.Message) bool {
+	if m.Body != nil && !m.Body.MatchString(aws.StringValue(msg.Body)) {
+		return false
+	}
+	for name, value := range m.Attributes {
+		if mav, ok := msg.MessageAttributes[name]; ok && aws.StringValue(mav.StringValue) == value {
+			continue
+		}
+		if av, ok := msg.Attributes[name]; ok && aws.StringValue(av) == value {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// DeleteMatchingOptions controls how the queue is drained.
+type DeleteMatchingOptions struct {
+	// MaxMessages is the maximum number of messages to inspect.
+	MaxMessages int
+	// VisibilityTimeout in seconds for received messages, must be long enough to drain the queue.
+	VisibilityTimeout int64
+	// WaitTimeSeconds is the long polling time for a single receive.
+	WaitTimeSeconds int64
+	// DryRun only counts matching messages without deleting them.
+	DryRun bool
+}
+
+// DeleteMatchingResult summarises DeleteMatching.
+type DeleteMatchingResult struct {
+	Received int `json:"received"`
+	Matched  int `json:"matched"`
+	Deleted  int `json:"deleted"`
+	Released int `json:"released"`
 }
 
 // NewSQSClient creates a new flow sqs client.
@@ -27,6 +91,10 @@ type flowSQSClient struct {
 
 func (f flowSQSClient) Delete(ctx context.Context, queueName string, receiptHandles []string) error {
 	err, qUrl := f.resolveSQSURL(ctx, queueName)
+	if err == errQueueNotFound {
+		// delete-message always matched queue names ignoring case
+		err, qUrl = f.findSQSURL(ctx, queueName)
+	}
 	if err != nil {
 		return err
 	}
@@ -48,20 +116,211 @@ func (f flowSQSClient) Delete(ctx context.Context, queueName string, receiptHand
 	return err
 }
 
-func (f flowSQSClient) resolveSQSURL(ctx context.Context, queueName string) (error, string) {
-	resp, err := f.ListQueues(&sqs.ListQueuesInput{})
+// DeleteMatching drains the queue with receive calls and deletes only the messages matching the filter. Messages that
+// do not match are kept invisible until draining is done, so they are not received twice, and are then made visible
+// again. Draining stops when the queue returns no new messages or opts.MaxMessages have been inspected.
+func (f flowSQSClient) DeleteMatching(ctx context.Context, queueName string, filter MessageFilter, opts DeleteMatchingOptions) (result *DeleteMatchingResult, err error) {
+	if opts.MaxMessages < 1 {
+		return nil, errors.New("max messages must be greater than 0")
+	}
+	err, qUrl := f.resolveSQSURL(ctx, queueName)
 	if err != nil {
-		return err, ""
+		return nil, err
 	}
 
-	for _, elem := range resp.QueueUrls {
-		qUrl := aws.StringValue(elem)
-		split := strings.Split(qUrl, "/")
-		qName := split[len(split)-1]
-		if strings.EqualFold(qName, queueName) {
-			return nil, qUrl
+	result = &DeleteMatchingResult{}
+	seen := make(map[string]bool)
+	// messages to release by MessageId, a redelivered message replaces the stale receipt handle
+	pending := make(map[string]*sqs.Message)
+	var pendingIds []string
+	keep := func(msg *sqs.Message) {
+		id := aws.StringValue(msg.MessageId)
+		if _, ok := pending[id]; !ok {
+			pendingIds = append(pendingIds, id)
 		}
+		pending[id] = msg
 	}
+	defer func() {
+		release := make([]*sqs.Message, 0, len(pendingIds))
+		for _, id := range pendingIds {
+			release = append(release, pending[id])
+		}
+		// release even when draining failed, otherwise messages stay hidden for the whole visibility timeout
+		n, rerr := f.release(qUrl, release)
+		result.Released += n
+		if err == nil && rerr != nil {
+			err = rerr
+		}
+	}()
+
+	for result.Received < opts.MaxMessages {
+		n := opts.MaxMessages - result.Received
+		if n > maxBatchSize {
+			n = maxBatchSize
+		}
+		var out *sqs.ReceiveMessageOutput
+		out, err = f.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(qUrl),
+			MaxNumberOfMessages:   aws.Int64(int64(n)),
+			AttributeNames:        []*string{aws.String(sqs.QueueAttributeNameAll)},
+			MessageAttributeNames: []*string{aws.String(sqs.QueueAttributeNameAll)},
+			VisibilityTimeout:     aws.Int64(opts.VisibilityTimeout),
+			WaitTimeSeconds:       aws.Int64(opts.WaitTimeSeconds),
+		})
+		if err != nil {
+			return result, fmt.Errorf("unable to receive messages: %v", err)
+		}
+
+		var matched []*sqs.Message
+		newMessages := 0
+		for _, msg := range out.Messages {
+			id := aws.StringValue(msg.MessageId)
+			if seen[id] {
+				// message already inspected and got redelivered, make sure the latest receipt handle is used
+				keep(msg)
+				continue
+			}
+			seen[id] = true
+			newMessages++
+			result.Received++
+			match := filter.Match(msg)
+			if match {
+				result.Matched++
+			}
+			if match && !opts.DryRun {
+				matched = append(matched, msg)
+			} else {
+				keep(msg)
+			}
+		}
+
+		if len(matched) > 0 {
+			var deleted int
+			var failed []*sqs.Message
+			deleted, failed, err = f.deleteMessages(ctx, qUrl, matched)
+			result.Deleted += deleted
+			// messages that were not deleted are still hidden and get released with the others
+			for _, msg := range failed {
+				keep(msg)
+			}
+			if err != nil {
+				return result, err
+			}
+		}
 
-	return errors.New("sqs url not found"), ""
+		if newMessages == 0 {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// deleteMessages deletes a batch of messages and returns the number deleted and the messages that failed.
+func (f flowSQSClient) deleteMessages(ctx context.Context, qUrl string, messages []*sqs.Message) (int, []*sqs.Message, error) {
+	var entries []*sqs.DeleteMessageBatchRequestEntry
+	for i, msg := range messages {
+		entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(fmt.Sprintf("%d", i)),
+			ReceiptHandle: msg.ReceiptHandle,
+		})
+	}
+
+	out, err := f.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(qUrl),
+		Entries:  entries,
+	})
+	if err != nil {
+		return 0, messages, fmt.Errorf("unable to delete messages: %v", err)
+	}
+	if len(out.Failed) > 0 {
+		var failed []*sqs.Message
+		for _, e := range out.Failed {
+			i, err := strconv.Atoi(aws.StringValue(e.Id))
+			if err == nil && i >= 0 && i < len(messages) {
+				failed = append(failed, messages[i])
+			}
+		}
+		return len(out.Successful), failed, fmt.Errorf("unable to delete %d message(s): %s", len(out.Failed), aws.StringValue(out.Failed[0].Message))
+	}
+
+	return len(out.Successful), nil, nil
+}
+
+// release makes messages visible again by setting their visibility timeout to 0.
+// The messages are released with a fresh context, so they are not left hidden when ctx got cancelled.
+// Messages that failed to be released are reported in the error after all batches were tried.
+func (f flowSQSClient) release(qUrl string, messages []*sqs.Message) (int, error) {
+	released := 0
+	var failed []*sqs.BatchResultErrorEntry
+	for start := 0; start < len(messages); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		var entries []*sqs.ChangeMessageVisibilityBatchRequestEntry
+		for i, msg := range messages[start:end] {
+			entries = append(entries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(fmt.Sprintf("%d", i)),
+				ReceiptHandle:     msg.ReceiptHandle,
+				VisibilityTimeout: aws.Int64(0),
+			})
+		}
+
+		out, err := f.ChangeMessageVisibilityBatchWithContext(context.Background(), &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(qUrl),
+			Entries:  entries,
+		})
+		if err != nil {
+			return released, fmt.Errorf("unable to change message visibility: %v", err)
+		}
+		released += len(out.Successful)
+		failed = append(failed, out.Failed...)
+	}
+	if len(failed) > 0 {
+		return released, fmt.Errorf("unable to release %d message(s), they stay hidden until the visibility timeout ends: %s", len(failed), aws.StringValue(failed[0].Message))
+	}
+
+	return released, nil
+}
+
+// QueueURL returns url of the queue with exactly the given name.
+func (f flowSQSClient) QueueURL(ctx context.Context, queueName string) (string, error) {
+	err, qUrl := f.resolveSQSURL(ctx, queueName)
+	return qUrl, err
+}
+
+func (f flowSQSClient) resolveSQSURL(ctx context.Context, queueName string) (error, string) {
+	resp, err := f.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sqs.ErrCodeQueueDoesNotExist {
+		return errQueueNotFound, ""
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get url of queue %s: %v", queueName, err), ""
+	}
+
+	return nil, aws.StringValue(resp.QueueUrl)
+}
+
+// findSQSURL returns url of the queue with the name, compared ignoring case.
+func (f flowSQSClient) findSQSURL(ctx context.Context, queueName string) (error, string) {
+	var qUrl string
+	err := f.ListQueuesPagesWithContext(ctx, &sqs.ListQueuesInput{MaxResults: aws.Int64(1000)}, func(output *sqs.ListQueuesOutput, lastPage bool) bool {
+		for _, u := range output.QueueUrls {
+			split := strings.Split(aws.StringValue(u), "/")
+			if strings.EqualFold(split[len(split)-1], queueName) {
+				qUrl = aws.StringValue(u)
+				return false
+			}
+		}
+		return lastPage == false
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list queues: %v", err), ""
+	}
+	if qUrl == "" {
+		return errQueueNotFound, ""
+	}
+	return nil, qUrl
 }
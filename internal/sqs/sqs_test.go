@@ -2,10 +2,14 @@ package sqs
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/stretchr/testify/assert"
+	"regexp"
 	"testing"
 )
 
@@ -13,8 +17,16 @@ type SQSMock struct {
 	sqsiface.SQSAPI
 }
 
-func (f SQSMock) ListQueues(*sqs.ListQueuesInput) (*sqs.ListQueuesOutput, error) {
-	return &sqs.ListQueuesOutput{QueueUrls: []*string{aws.String("	https://sqs.eu-west-1.amazonaws.com/111111111111/test-queue-name")}}, nil
+func (f SQSMock) GetQueueUrlWithContext(_ aws.Context, input *sqs.GetQueueUrlInput, _ ...request.Option) (*sqs.GetQueueUrlOutput, error) {
+	if aws.StringValue(input.QueueName) != "test-queue-name" {
+		return nil, awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist", nil)
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/111111111111/test-queue-name")}, nil
+}
+
+func (f SQSMock) ListQueuesPagesWithContext(_ aws.Context, _ *sqs.ListQueuesInput, fn func(*sqs.ListQueuesOutput, bool) bool, _ ...request.Option) error {
+	fn(&sqs.ListQueuesOutput{QueueUrls: aws.StringSlice([]string{"https://sqs.eu-west-1.amazonaws.com/111111111111/test-queue-name"})}, true)
+	return nil
 }
 
 func (f SQSMock) DeleteMessageBatch(*sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
@@ -39,4 +51,171 @@ func Test_flowSQSClient_Delete(t *testing.T) {
 
 		assert.Nil(t, err)
 	})
+
+	t.Run("Should find queue ignoring case", func(t *testing.T) {
+		client, err := NewSQSClient(SQSMock{})
+		assert.Nil(t, err)
+
+		assert.Nil(t, client.Delete(context.Background(), "Test-Queue-Name", []string{"test"}))
+		assert.Equal(t, errQueueNotFound, client.Delete(context.Background(), "other", []string{"test"}))
+	})
+}
+
+type drainSQSMock struct {
+	SQSMock
+	messages []*sqs.Message
+	deleted  []string
+	released []string
+	// failRelease rejects releasing the receipt handle
+	failRelease string
+	// failDelete rejects deleting the receipt handle
+	failDelete string
+}
+
+func (f *drainSQSMock) ReceiveMessageWithContext(_ aws.Context, input *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	n := int(aws.Int64Value(input.MaxNumberOfMessages))
+	if n > len(f.messages) {
+		n = len(f.messages)
+	}
+	out := &sqs.ReceiveMessageOutput{Messages: f.messages[:n]}
+	f.messages = f.messages[n:]
+	return out, nil
+}
+
+func (f *drainSQSMock) DeleteMessageBatchWithContext(_ aws.Context, input *sqs.DeleteMessageBatchInput, _ ...request.Option) (*sqs.DeleteMessageBatchOutput, error) {
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, e := range input.Entries {
+		if aws.StringValue(e.ReceiptHandle) == f.failDelete {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: e.Id, Message: aws.String("internal error")})
+			continue
+		}
+		f.deleted = append(f.deleted, aws.StringValue(e.ReceiptHandle))
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: e.Id})
+	}
+	return out, nil
+}
+
+func (f *drainSQSMock) ChangeMessageVisibilityBatchWithContext(_ aws.Context, input *sqs.ChangeMessageVisibilityBatchInput, _ ...request.Option) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	out := &sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, e := range input.Entries {
+		if aws.StringValue(e.ReceiptHandle) == f.failRelease {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: e.Id, Message: aws.String("receipt handle is invalid")})
+			continue
+		}
+		f.released = append(f.released, aws.StringValue(e.ReceiptHandle))
+		out.Successful = append(out.Successful, &sqs.ChangeMessageVisibilityBatchResultEntry{Id: e.Id})
+	}
+	return out, nil
+}
+
+func newDrainSQSMock(n int) *drainSQSMock {
+	m := &drainSQSMock{}
+	for i := 0; i < n; i++ {
+		body := "ok"
+		if i%3 == 0 {
+			body = "poison"
+		}
+		m.messages = append(m.messages, &sqs.Message{
+			MessageId:     aws.String(fmt.Sprintf("id-%d", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("rh-%d", i)),
+			Body:          aws.String(body),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				"eventType": {DataType: aws.String("String"), StringValue: aws.String("STATUS_UPDATED")},
+			},
+		})
+	}
+	return m
+}
+
+func Test_flowSQSClient_DeleteMatching(t *testing.T) {
+	t.Run("Should delete only matching messages", func(t *testing.T) {
+		m := newDrainSQSMock(25)
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		filter := MessageFilter{Body: regexp.MustCompile("^poison$")}
+		res, err := client.DeleteMatching(context.Background(), "test-queue-name", filter, DeleteMatchingOptions{MaxMessages: 100})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &DeleteMatchingResult{Received: 25, Matched: 9, Deleted: 9, Released: 16}, res)
+		assert.Len(t, m.deleted, 9)
+		assert.Len(t, m.released, 16)
+	})
+
+	t.Run("Should stop at max messages", func(t *testing.T) {
+		m := newDrainSQSMock(25)
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		filter := MessageFilter{Attributes: map[string]string{"eventType": "STATUS_UPDATED"}}
+		res, err := client.DeleteMatching(context.Background(), "test-queue-name", filter, DeleteMatchingOptions{MaxMessages: 12})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &DeleteMatchingResult{Received: 12, Matched: 12, Deleted: 12}, res)
+		assert.Len(t, m.messages, 13)
+	})
+
+	t.Run("Should not delete in dry run", func(t *testing.T) {
+		m := newDrainSQSMock(5)
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		res, err := client.DeleteMatching(context.Background(), "test-queue-name", MessageFilter{}, DeleteMatchingOptions{MaxMessages: 10, DryRun: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &DeleteMatchingResult{Received: 5, Matched: 5, Released: 5}, res)
+		assert.Empty(t, m.deleted)
+	})
+
+	t.Run("Should release redelivered messages once with the latest receipt handle", func(t *testing.T) {
+		m := newDrainSQSMock(2)
+		redelivered := *m.messages[1]
+		redelivered.ReceiptHandle = aws.String("rh-1-redelivered")
+		m.messages = append(m.messages, &redelivered)
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		res, err := client.DeleteMatching(context.Background(), "test-queue-name", MessageFilter{}, DeleteMatchingOptions{MaxMessages: 10, DryRun: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &DeleteMatchingResult{Received: 2, Matched: 2, Released: 2}, res)
+		assert.Equal(t, []string{"rh-0", "rh-1-redelivered"}, m.released)
+	})
+
+	t.Run("Should release messages that were not deleted", func(t *testing.T) {
+		m := newDrainSQSMock(3)
+		m.failDelete = "rh-1"
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		res, err := client.DeleteMatching(context.Background(), "test-queue-name", MessageFilter{}, DeleteMatchingOptions{MaxMessages: 10})
+
+		assert.EqualError(t, err, "unable to delete 1 message(s): internal error")
+		assert.Equal(t, &DeleteMatchingResult{Received: 3, Matched: 3, Deleted: 2, Released: 1}, res)
+		assert.Equal(t, []string{"rh-1"}, m.released)
+	})
+
+	t.Run("Should report messages that were not released", func(t *testing.T) {
+		m := newDrainSQSMock(3)
+		m.failRelease = "rh-1"
+		client, err := NewSQSClient(m)
+		assert.Nil(t, err)
+
+		res, err := client.DeleteMatching(context.Background(), "test-queue-name", MessageFilter{}, DeleteMatchingOptions{MaxMessages: 10, DryRun: true})
+
+		assert.EqualError(t, err, "unable to release 1 message(s), they stay hidden until the visibility timeout ends: receipt handle is invalid")
+		assert.Equal(t, 2, res.Released)
+	})
+}
+
+func TestMessageFilter_Match(t *testing.T) {
+	msg := &sqs.Message{
+		Body:       aws.String(`{"id":"1","status":"ACTIVE"}`),
+		Attributes: map[string]*string{"ApproximateReceiveCount": aws.String("5")},
+	}
+
+	assert.True(t, MessageFilter{}.Match(msg))
+	assert.True(t, MessageFilter{Body: regexp.MustCompile(`"status":"ACTIVE"`)}.Match(msg))
+	assert.True(t, MessageFilter{Attributes: map[string]string{"ApproximateReceiveCount": "5"}}.Match(msg))
+	assert.False(t, MessageFilter{Attributes: map[string]string{"eventType": "STATUS_UPDATED"}}.Match(msg))
 }
@@ -0,0 +1,188 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// QueueStats is a snapshot of queue depth.
+type QueueStats struct {
+	Name     string
+	URL      string
+	Visible  int64
+	InFlight int64
+	Delayed  int64
+	// OldestMessageAge in seconds, taken from CloudWatch. Nil when there are no datapoints.
+	OldestMessageAge *float64
+	Time             time.Time
+}
+
+// Thresholds for Exceeded, zero values are ignored.
+type Thresholds struct {
+	MaxVisible  int64
+	MaxInFlight int64
+	// MaxOldestMessageAge in seconds.
+	MaxOldestMessageAge float64
+}
+
+// Exceeded returns a description of every threshold crossed by s.
+func (t Thresholds) Exceeded(s QueueStats) []string {
+	var exceeded []string
+	if t.MaxVisible > 0 && s.Visible > t.MaxVisible {
+		exceeded = append(exceeded, fmt.Sprintf("%s: visible %d > %d", s.Name, s.Visible, t.MaxVisible))
+	}
+	if t.MaxInFlight > 0 && s.InFlight > t.MaxInFlight {
+		exceeded = append(exceeded, fmt.Sprintf("%s: in flight %d > %d", s.Name, s.InFlight, t.MaxInFlight))
+	}
+	if t.MaxOldestMessageAge > 0 && s.OldestMessageAge != nil && *s.OldestMessageAge > t.MaxOldestMessageAge {
+		exceeded = append(exceeded, fmt.Sprintf("%s: oldest message age %.0fs > %.0fs", s.Name, *s.OldestMessageAge, t.MaxOldestMessageAge))
+	}
+	return exceeded
+}
+
+// Stats returns depth of all queues with the name prefix.
+func (f flowSQSClient) Stats(ctx context.Context, queueNamePrefix string) ([]QueueStats, error) {
+	var qUrls []*string
+	// SQS returns more than one page only when MaxResults is set
+	err := f.ListQueuesPagesWithContext(ctx, &sqs.ListQueuesInput{
+		QueueNamePrefix: aws.String(queueNamePrefix),
+		MaxResults:      aws.Int64(1000),
+	}, func(output *sqs.ListQueuesOutput, lastPage bool) bool {
+		qUrls = append(qUrls, output.QueueUrls...)
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list queues: %v", err)
+	}
+
+	var stats []QueueStats
+	for _, qUrl := range qUrls {
+		out, err := f.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl: qUrl,
+			AttributeNames: aws.StringSlice([]string{
+				sqs.QueueAttributeNameApproximateNumberOfMessages,
+				sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+				sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+			}),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get attributes of %s: %v", aws.StringValue(qUrl), err)
+		}
+
+		split := strings.Split(aws.StringValue(qUrl), "/")
+		stats = append(stats, QueueStats{
+			Name:     split[len(split)-1],
+			URL:      aws.StringValue(qUrl),
+			Visible:  attributeInt(out.Attributes, sqs.QueueAttributeNameApproximateNumberOfMessages),
+			InFlight: attributeInt(out.Attributes, sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+			Delayed:  attributeInt(out.Attributes, sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed),
+			Time:     time.Now(),
+		})
+	}
+
+	return stats, nil
+}
+
+func attributeInt(attr map[string]*string, name string) int64 {
+	v, _ := strconv.ParseInt(aws.StringValue(attr[name]), 10, 64)
+	return v
+}
+
+// SetOldestMessageAge sets OldestMessageAge from the latest ApproximateAgeOfOldestMessage datapoint in CloudWatch.
+// SQS publishes the metric once a minute, so the value lags behind the queue attributes.
+func SetOldestMessageAge(ctx context.Context, cw cloudwatchiface.CloudWatchAPI, stats []QueueStats) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-10 * time.Minute)
+	ids := map[string]int{}
+	var queries []*cloudwatch.MetricDataQuery
+	for i, s := range stats {
+		id := fmt.Sprintf("q%d", i)
+		ids[id] = i
+		queries = append(queries, &cloudwatch.MetricDataQuery{
+			Id: aws.String(id),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: &cloudwatch.Metric{
+					Namespace:  aws.String("AWS/SQS"),
+					MetricName: aws.String("ApproximateAgeOfOldestMessage"),
+					Dimensions: []*cloudwatch.Dimension{
+						{Name: aws.String("QueueName"), Value: aws.String(s.Name)},
+					},
+				},
+				Period: aws.Int64(60),
+				Stat:   aws.String(cloudwatch.StatisticMaximum),
+			},
+		})
+	}
+
+	// GetMetricData accepts up to 500 queries per request
+	for start := 0; start < len(queries); start += 500 {
+		end := start + 500
+		if end > len(queries) {
+			end = len(queries)
+		}
+		err := cw.GetMetricDataPagesWithContext(ctx, &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: queries[start:end],
+			StartTime:         aws.Time(startTime),
+			EndTime:           aws.Time(endTime),
+			ScanBy:            aws.String(cloudwatch.ScanByTimestampDescending),
+		}, func(output *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+			for _, r := range output.MetricDataResults {
+				i, ok := ids[aws.StringValue(r.Id)]
+				if !ok || len(r.Values) == 0 || stats[i].OldestMessageAge != nil {
+					continue
+				}
+				// values are sorted by timestamp descending
+				stats[i].OldestMessageAge = aws.Float64(aws.Float64Value(r.Values[0]))
+			}
+			return lastPage == false
+		})
+		if err != nil {
+			return fmt.Errorf("unable to get metric data: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// WriteStatsTable writes stats as a table. Rates of change per second are calculated from prev, queues missing in
+// prev have no rate.
+func WriteStatsTable(w io.Writer, prev map[string]QueueStats, stats []QueueStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "QUEUE\tVISIBLE\tRATE/s\tIN FLIGHT\tRATE/s\tDELAYED\tOLDEST AGE\t")
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		visibleRate, inFlightRate := "-", "-"
+		if p, ok := prev[s.Name]; ok {
+			if elapsed := s.Time.Sub(p.Time).Seconds(); elapsed > 0 {
+				visibleRate = fmt.Sprintf("%+.1f", float64(s.Visible-p.Visible)/elapsed)
+				inFlightRate = fmt.Sprintf("%+.1f", float64(s.InFlight-p.InFlight)/elapsed)
+			}
+		}
+		age := "-"
+		if s.OldestMessageAge != nil {
+			age = (time.Duration(*s.OldestMessageAge) * time.Second).String()
+		}
+		_, err := fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%d\t%s\t\n", s.Name, s.Visible, visibleRate, s.InFlight, inFlightRate, s.Delayed, age)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
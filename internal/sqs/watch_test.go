@@ -0,0 +1,95 @@
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type statsSQSMock struct {
+	SQSMock
+}
+
+func (f *statsSQSMock) ListQueuesPagesWithContext(_ aws.Context, input *sqs.ListQueuesInput, fn func(*sqs.ListQueuesOutput, bool) bool, _ ...request.Option) error {
+	if aws.Int64Value(input.MaxResults) == 0 {
+		return errors.New("queues are not paginated without MaxResults")
+	}
+	fn(&sqs.ListQueuesOutput{QueueUrls: aws.StringSlice([]string{
+		"https://sqs.eu-west-1.amazonaws.com/111111111111/orders",
+		"https://sqs.eu-west-1.amazonaws.com/111111111111/orders-dlq",
+	})}, true)
+	return nil
+}
+
+func (f *statsSQSMock) GetQueueAttributesWithContext(_ aws.Context, input *sqs.GetQueueAttributesInput, _ ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{
+		sqs.QueueAttributeNameApproximateNumberOfMessages:           aws.String("10"),
+		sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible: aws.String("2"),
+		sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed:    aws.String("1"),
+	}}, nil
+}
+
+type cloudWatchMock struct {
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (c *cloudWatchMock) GetMetricDataPagesWithContext(_ aws.Context, input *cloudwatch.GetMetricDataInput, fn func(*cloudwatch.GetMetricDataOutput, bool) bool, _ ...request.Option) error {
+	fn(&cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{
+		{Id: aws.String("q0"), Values: aws.Float64Slice([]float64{120, 60})},
+		{Id: aws.String("q1")},
+	}}, true)
+	return nil
+}
+
+func Test_flowSQSClient_Stats(t *testing.T) {
+	client, err := NewSQSClient(&statsSQSMock{})
+	assert.Nil(t, err)
+
+	stats, err := client.Stats(context.Background(), "orders")
+
+	assert.Nil(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "orders-dlq", stats[1].Name)
+	assert.Equal(t, int64(10), stats[0].Visible)
+	assert.Equal(t, int64(2), stats[0].InFlight)
+	assert.Equal(t, int64(1), stats[0].Delayed)
+
+	err = SetOldestMessageAge(context.Background(), &cloudWatchMock{}, stats)
+
+	assert.Nil(t, err)
+	assert.Equal(t, float64(120), *stats[0].OldestMessageAge)
+	assert.Nil(t, stats[1].OldestMessageAge)
+}
+
+func TestThresholds_Exceeded(t *testing.T) {
+	s := QueueStats{Name: "orders", Visible: 100, InFlight: 5, OldestMessageAge: aws.Float64(600)}
+
+	assert.Empty(t, Thresholds{}.Exceeded(s))
+	assert.Equal(t, []string{"orders: visible 100 > 50"}, Thresholds{MaxVisible: 50, MaxInFlight: 10}.Exceeded(s))
+	assert.Len(t, Thresholds{MaxVisible: 50, MaxOldestMessageAge: 300}.Exceeded(s), 2)
+}
+
+func TestWriteStatsTable(t *testing.T) {
+	now := time.Now()
+	prev := map[string]QueueStats{"orders": {Name: "orders", Visible: 100, InFlight: 10, Time: now.Add(-10 * time.Second)}}
+	stats := []QueueStats{
+		{Name: "orders", Visible: 50, InFlight: 20, Time: now, OldestMessageAge: aws.Float64(90)},
+		{Name: "orders-dlq", Visible: 1, Time: now},
+	}
+
+	var b bytes.Buffer
+	err := WriteStatsTable(&b, prev, stats)
+
+	assert.Nil(t, err)
+	assert.Contains(t, b.String(), "-5.0")
+	assert.Contains(t, b.String(), "+1.0")
+	assert.Contains(t, b.String(), "1m30s")
+}
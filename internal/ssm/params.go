@@ -0,0 +1,240 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// maxGetParameters is the number of names GetParameters accepts in one call.
+const maxGetParameters = 10
+
+// Parameter is a parameter in the ssm export format, its metadata, the decrypted value and tags.
+type Parameter struct {
+	ParameterMetadata *ssm.ParameterMetadata
+	ParameterValue    *ssm.Parameter `json:",omitempty"`
+	Tags              []*ssm.Tag     `json:",omitempty"`
+}
+
+// Export returns the parameters under the path, or all when the path is empty, with decrypted
+// values and tags. With redact only the metadata and tags are returned.
+func Export(ctx context.Context, path string, redact bool, c ssmiface.SSMAPI) ([]Parameter, error) {
+	input := &ssm.DescribeParametersInput{}
+	if path != "" {
+		input.ParameterFilters = []*ssm.ParameterStringFilter{{
+			Key:    aws.String("Path"),
+			Option: aws.String("Recursive"),
+			Values: aws.StringSlice([]string{path}),
+		}}
+	}
+	var metadata []*ssm.ParameterMetadata
+	err := c.DescribeParametersPagesWithContext(ctx, input, func(output *ssm.DescribeParametersOutput, lastPage bool) bool {
+		metadata = append(metadata, output.Parameters...)
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe parameters: %v", err)
+	}
+
+	values := map[string]*ssm.Parameter{}
+	if !redact {
+		var names []string
+		for _, m := range metadata {
+			names = append(names, aws.StringValue(m.Name))
+		}
+		if values, err = getParameters(ctx, names, c); err != nil {
+			return nil, err
+		}
+	}
+
+	var parameters []Parameter
+	for _, m := range metadata {
+		name := aws.StringValue(m.Name)
+		value, ok := values[name]
+		if !ok && !redact {
+			return nil, fmt.Errorf("unable to get parameter %s: not found", name)
+		}
+		tags, err := c.ListTagsForResourceWithContext(ctx, &ssm.ListTagsForResourceInput{
+			ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+			ResourceId:   m.Name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list tags of %s: %v", name, err)
+		}
+		parameters = append(parameters, Parameter{
+			ParameterMetadata: m,
+			ParameterValue:    value,
+			Tags:              tags.TagList,
+		})
+	}
+	return parameters, nil
+}
+
+// getParameters returns the decrypted parameters by name, names that do not exist are missing.
+func getParameters(ctx context.Context, names []string, c ssmiface.SSMAPI) (map[string]*ssm.Parameter, error) {
+	parameters := map[string]*ssm.Parameter{}
+	for i := 0; i < len(names); i += maxGetParameters {
+		output, err := c.GetParametersWithContext(ctx, &ssm.GetParametersInput{
+			Names:          aws.StringSlice(names[i:min(i+maxGetParameters, len(names))]),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get parameters: %v", err)
+		}
+		for _, p := range output.Parameters {
+			parameters[aws.StringValue(p.Name)] = p
+		}
+	}
+	return parameters, nil
+}
+
+// ReadExport reads parameters written by Export.
+func ReadExport(r io.Reader) ([]Parameter, error) {
+	var parameters []Parameter
+	if err := json.NewDecoder(r).Decode(&parameters); err != nil {
+		return nil, fmt.Errorf("unable to read parameters: %v", err)
+	}
+	for i, p := range parameters {
+		if p.ParameterMetadata == nil {
+			return nil, fmt.Errorf("parameter %d has no metadata", i)
+		}
+		if p.ParameterValue == nil {
+			return nil, fmt.Errorf("parameter %s has no value, redacted exports can not be imported", aws.StringValue(p.ParameterMetadata.Name))
+		}
+	}
+	return parameters, nil
+}
+
+// Import actions of PlanImport.
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	// ImportExists is an existing parameter with a different value that is not overwritten.
+	ImportExists = "exists"
+)
+
+// ImportOptions configure PlanImport.
+type ImportOptions struct {
+	// Overwrite updates existing parameters with a different value or type.
+	Overwrite bool
+	// FromPrefix and ToPrefix remap names, e.g. /staging/ to /dev-alice/. With FromPrefix only
+	// parameters starting with it are imported.
+	FromPrefix string
+	ToPrefix   string
+	// KeyId replaces the KMS key of SecureString parameters, keys of other accounts can not be used.
+	KeyId string
+}
+
+// ImportChange is what PlanImport found for a parameter.
+type ImportChange struct {
+	Name       string
+	SourceName string
+	Action     string
+	Parameter  Parameter
+}
+
+// PlanImport compares the parameters with the existing ones and returns what Import does for each
+// of them, sorted by name.
+func PlanImport(ctx context.Context, parameters []Parameter, opts ImportOptions, c ssmiface.SSMAPI) ([]ImportChange, error) {
+	var changes []ImportChange
+	var names []string
+	for _, p := range parameters {
+		source := aws.StringValue(p.ParameterMetadata.Name)
+		name := source
+		if opts.FromPrefix != "" {
+			if !strings.HasPrefix(source, opts.FromPrefix) {
+				continue
+			}
+			name = opts.ToPrefix + strings.TrimPrefix(source, opts.FromPrefix)
+		}
+		changes = append(changes, ImportChange{Name: name, SourceName: source, Parameter: p})
+		names = append(names, name)
+	}
+
+	existing, err := getParameters(ctx, names, c)
+	if err != nil {
+		return nil, err
+	}
+	for i, change := range changes {
+		e, ok := existing[change.Name]
+		value := change.Parameter.ParameterValue
+		switch {
+		case !ok:
+			changes[i].Action = ImportCreate
+		case aws.StringValue(e.Value) == aws.StringValue(value.Value) && aws.StringValue(e.Type) == aws.StringValue(value.Type):
+			changes[i].Action = ImportUnchanged
+		case opts.Overwrite:
+			changes[i].Action = ImportUpdate
+		default:
+			changes[i].Action = ImportExists
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
+
+// Import creates and updates the parameters of the changes with their type, KMS key, description,
+// tier and tags. Unchanged and existing parameters are skipped.
+func Import(ctx context.Context, changes []ImportChange, opts ImportOptions, c ssmiface.SSMAPI) error {
+	for _, change := range changes {
+		if change.Action != ImportCreate && change.Action != ImportUpdate {
+			continue
+		}
+		m := change.Parameter.ParameterMetadata
+		input := &ssm.PutParameterInput{
+			Name:           aws.String(change.Name),
+			Value:          change.Parameter.ParameterValue.Value,
+			Type:           change.Parameter.ParameterValue.Type,
+			Description:    m.Description,
+			AllowedPattern: m.AllowedPattern,
+			Tier:           m.Tier,
+			DataType:       m.DataType,
+			Overwrite:      aws.Bool(change.Action == ImportUpdate),
+		}
+		if aws.StringValue(input.Type) == ssm.ParameterTypeSecureString {
+			input.KeyId = m.KeyId
+			if opts.KeyId != "" {
+				input.KeyId = aws.String(opts.KeyId)
+			}
+		}
+		// tags can not be set with overwrite
+		if change.Action == ImportCreate && len(change.Parameter.Tags) > 0 {
+			input.Tags = change.Parameter.Tags
+		}
+		if _, err := c.PutParameterWithContext(ctx, input); err != nil {
+			return fmt.Errorf("unable to put parameter %s: %v", change.Name, err)
+		}
+		if change.Action == ImportUpdate && len(change.Parameter.Tags) > 0 {
+			_, err := c.AddTagsToResourceWithContext(ctx, &ssm.AddTagsToResourceInput{
+				ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+				ResourceId:   aws.String(change.Name),
+				Tags:         change.Parameter.Tags,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to tag parameter %s: %v", change.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteImportTable writes the changes as a table, values are not printed.
+func WriteImportTable(w io.Writer, changes []ImportChange) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tNAME\tTYPE\tSOURCE")
+	for _, c := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Action, c.Name, aws.StringValue(c.Parameter.ParameterValue.Type), c.SourceName)
+	}
+	return tw.Flush()
+}
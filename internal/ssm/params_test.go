@@ -0,0 +1,146 @@
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type ssmMock struct {
+	ssmiface.SSMAPI
+	parameters map[string]*ssm.Parameter
+	put        []*ssm.PutParameterInput
+	tagged     []string
+}
+
+func (m *ssmMock) DescribeParametersPagesWithContext(ctx aws.Context, input *ssm.DescribeParametersInput, f func(*ssm.DescribeParametersOutput, bool) bool, opts ...request.Option) error {
+	var metadata []*ssm.ParameterMetadata
+	for name, p := range m.parameters {
+		metadata = append(metadata, &ssm.ParameterMetadata{Name: aws.String(name), Type: p.Type})
+	}
+	f(&ssm.DescribeParametersOutput{Parameters: metadata}, true)
+	return nil
+}
+
+func (m *ssmMock) GetParametersWithContext(ctx aws.Context, input *ssm.GetParametersInput, opts ...request.Option) (*ssm.GetParametersOutput, error) {
+	output := &ssm.GetParametersOutput{}
+	for _, name := range input.Names {
+		if p, ok := m.parameters[*name]; ok {
+			output.Parameters = append(output.Parameters, p)
+		} else {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+		}
+	}
+	return output, nil
+}
+
+func (m *ssmMock) ListTagsForResourceWithContext(ctx aws.Context, input *ssm.ListTagsForResourceInput, opts ...request.Option) (*ssm.ListTagsForResourceOutput, error) {
+	return &ssm.ListTagsForResourceOutput{TagList: []*ssm.Tag{{Key: aws.String("team"), Value: aws.String("orders")}}}, nil
+}
+
+func (m *ssmMock) PutParameterWithContext(ctx aws.Context, input *ssm.PutParameterInput, opts ...request.Option) (*ssm.PutParameterOutput, error) {
+	m.put = append(m.put, input)
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (m *ssmMock) AddTagsToResourceWithContext(ctx aws.Context, input *ssm.AddTagsToResourceInput, opts ...request.Option) (*ssm.AddTagsToResourceOutput, error) {
+	m.tagged = append(m.tagged, *input.ResourceId)
+	return &ssm.AddTagsToResourceOutput{}, nil
+}
+
+func parameter(name, typ, value string) *ssm.Parameter {
+	return &ssm.Parameter{Name: aws.String(name), Type: aws.String(typ), Value: aws.String(value)}
+}
+
+func TestExport(t *testing.T) {
+	mock := &ssmMock{parameters: map[string]*ssm.Parameter{
+		"/staging/db/password": parameter("/staging/db/password", ssm.ParameterTypeSecureString, "secret"),
+	}}
+
+	parameters, err := Export(context.Background(), "/staging", false, mock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(parameters))
+	assert.Equal(t, "secret", *parameters[0].ParameterValue.Value)
+	assert.Equal(t, "team", *parameters[0].Tags[0].Key)
+
+	t.Run("Should read the export", func(t *testing.T) {
+		b, _ := json.Marshal(parameters)
+
+		read, err := ReadExport(bytes.NewReader(b))
+
+		assert.Nil(t, err)
+		assert.Equal(t, parameters, read)
+	})
+
+	t.Run("Should not import a redacted export", func(t *testing.T) {
+		redacted, err := Export(context.Background(), "/staging", true, mock)
+		assert.Nil(t, err)
+		assert.Nil(t, redacted[0].ParameterValue)
+		b, _ := json.Marshal(redacted)
+		assert.NotContains(t, string(b), "secret")
+
+		_, err = ReadExport(bytes.NewReader(b))
+		assert.EqualError(t, err, "parameter /staging/db/password has no value, redacted exports can not be imported")
+	})
+}
+
+func TestImport(t *testing.T) {
+	exported := func(name, typ, value string) Parameter {
+		return Parameter{
+			ParameterMetadata: &ssm.ParameterMetadata{Name: aws.String(name), Type: aws.String(typ), KeyId: aws.String("alias/staging"), Tier: aws.String("Standard")},
+			ParameterValue:    parameter(name, typ, value),
+			Tags:              []*ssm.Tag{{Key: aws.String("team"), Value: aws.String("orders")}},
+		}
+	}
+	parameters := []Parameter{
+		exported("/staging/db/password", ssm.ParameterTypeSecureString, "secret"),
+		exported("/staging/db/host", ssm.ParameterTypeString, "db.staging"),
+		exported("/staging/db/port", ssm.ParameterTypeString, "5432"),
+		exported("/prod/db/host", ssm.ParameterTypeString, "db.prod"),
+	}
+	mock := func() *ssmMock {
+		return &ssmMock{parameters: map[string]*ssm.Parameter{
+			"/dev-alice/db/host": parameter("/dev-alice/db/host", ssm.ParameterTypeString, "localhost"),
+			"/dev-alice/db/port": parameter("/dev-alice/db/port", ssm.ParameterTypeString, "5432"),
+		}}
+	}
+	opts := ImportOptions{FromPrefix: "/staging/", ToPrefix: "/dev-alice/", KeyId: "alias/dev"}
+
+	t.Run("Should plan without overwrite", func(t *testing.T) {
+		changes, err := PlanImport(context.Background(), parameters, opts, mock())
+
+		assert.Nil(t, err)
+		var actions []string
+		for _, c := range changes {
+			actions = append(actions, c.Action+" "+c.Name)
+		}
+		assert.Equal(t, []string{"exists /dev-alice/db/host", "create /dev-alice/db/password", "unchanged /dev-alice/db/port"}, actions)
+	})
+
+	t.Run("Should create and overwrite", func(t *testing.T) {
+		m := mock()
+		opts := opts
+		opts.Overwrite = true
+		changes, err := PlanImport(context.Background(), parameters, opts, m)
+		assert.Nil(t, err)
+
+		assert.Nil(t, Import(context.Background(), changes, opts, m))
+		assert.Equal(t, 2, len(m.put))
+		assert.Equal(t, "/dev-alice/db/host", *m.put[0].Name)
+		assert.True(t, *m.put[0].Overwrite)
+		assert.Nil(t, m.put[0].Tags)
+		assert.Nil(t, m.put[0].KeyId)
+		assert.Equal(t, "/dev-alice/db/password", *m.put[1].Name)
+		assert.Equal(t, "alias/dev", *m.put[1].KeyId)
+		assert.Equal(t, "orders", *m.put[1].Tags[0].Value)
+		assert.Equal(t, []string{"/dev-alice/db/host"}, m.tagged)
+	})
+}
@@ -0,0 +1,208 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// mask replaces SecureString values in output unless secrets are shown.
+const mask = "********"
+
+// ParametersByPath returns the decrypted parameters under the path sorted by name, with recursive
+// also the ones in sub paths.
+func ParametersByPath(ctx context.Context, path string, recursive bool, c ssmiface.SSMAPI) ([]*ssm.Parameter, error) {
+	var parameters []*ssm.Parameter
+	err := c.GetParametersByPathPagesWithContext(ctx, &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(recursive),
+		WithDecryption: aws.Bool(true),
+	}, func(output *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		parameters = append(parameters, output.Parameters...)
+		return lastPage == false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get parameters by path %s: %v", path, err)
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		return aws.StringValue(parameters[i].Name) < aws.StringValue(parameters[j].Name)
+	})
+	return parameters, nil
+}
+
+// displayValue returns the value of a parameter, masked for SecureString unless showSecrets.
+func displayValue(p *ssm.Parameter, showSecrets bool) string {
+	if aws.StringValue(p.Type) == ssm.ParameterTypeSecureString && !showSecrets {
+		return mask
+	}
+	return aws.StringValue(p.Value)
+}
+
+// WriteParameters writes the parameters as a table or json, SecureString values are masked
+// unless showSecrets.
+func WriteParameters(w io.Writer, parameters []*ssm.Parameter, format string, showSecrets bool) error {
+	switch format {
+	case "json":
+		type parameter struct {
+			Name    string `json:"name"`
+			Type    string `json:"type"`
+			Value   string `json:"value"`
+			Version int64  `json:"version"`
+		}
+		out := make([]parameter, len(parameters))
+		for i, p := range parameters {
+			out[i] = parameter{Name: aws.StringValue(p.Name), Type: aws.StringValue(p.Type), Value: displayValue(p, showSecrets), Version: aws.Int64Value(p.Version)}
+		}
+		return json.NewEncoder(w).Encode(out)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPE\tVERSION\tVALUE")
+		for _, p := range parameters {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", aws.StringValue(p.Name), aws.StringValue(p.Type), aws.Int64Value(p.Version), displayValue(p, showSecrets))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output %s, use table or json", format)
+	}
+}
+
+type treeNode struct {
+	children  map[string]*treeNode
+	parameter *ssm.Parameter
+}
+
+// WriteTree writes the parameters as a tree of their path segments below root. With values the
+// leaves show their value, SecureString values are masked unless showSecrets.
+func WriteTree(w io.Writer, root string, parameters []*ssm.Parameter, values, showSecrets bool) error {
+	tree := &treeNode{children: map[string]*treeNode{}}
+	for _, p := range parameters {
+		rel := strings.TrimPrefix(aws.StringValue(p.Name), strings.TrimSuffix(root, "/"))
+		node := tree
+		for _, segment := range strings.Split(strings.Trim(rel, "/"), "/") {
+			child, ok := node.children[segment]
+			if !ok {
+				child = &treeNode{children: map[string]*treeNode{}}
+				node.children[segment] = child
+			}
+			node = child
+		}
+		node.parameter = p
+	}
+
+	if _, err := fmt.Fprintln(w, root); err != nil {
+		return err
+	}
+	var write func(node *treeNode, indent string) error
+	write = func(node *treeNode, indent string) error {
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			child := node.children[name]
+			branch, next := "├── ", "│   "
+			if i == len(names)-1 {
+				branch, next = "└── ", "    "
+			}
+			line := name
+			if child.parameter != nil && values {
+				line += " = " + displayValue(child.parameter, showSecrets)
+			}
+			if _, err := fmt.Fprintf(w, "%s%s%s\n", indent, branch, line); err != nil {
+				return err
+			}
+			if err := write(child, indent+next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return write(tree, "")
+}
+
+// Diff statuses of DiffParameters.
+const (
+	// DiffMissing is a key in a that is missing in b.
+	DiffMissing = "missing"
+	// DiffExtra is a key in b that is not in a.
+	DiffExtra  = "extra"
+	DiffDiffer = "differs"
+	DiffSame   = "same"
+)
+
+// ParameterDiff compares the parameter with the same key, the name relative to the path, in two
+// paths. A or B is nil when the key is missing in the path.
+type ParameterDiff struct {
+	Key    string
+	Status string
+	A      *ssm.Parameter
+	B      *ssm.Parameter
+}
+
+// DiffParameters compares the parameters under pathA with the ones under pathB by key, sorted by
+// key. Parameters differ when their type or value differs.
+func DiffParameters(pathA string, a []*ssm.Parameter, pathB string, b []*ssm.Parameter) []ParameterDiff {
+	key := func(path string, p *ssm.Parameter) string {
+		return strings.TrimPrefix(strings.TrimPrefix(aws.StringValue(p.Name), strings.TrimSuffix(path, "/")), "/")
+	}
+	byKey := map[string]*ParameterDiff{}
+	for _, p := range a {
+		byKey[key(pathA, p)] = &ParameterDiff{Key: key(pathA, p), A: p}
+	}
+	for _, p := range b {
+		d, ok := byKey[key(pathB, p)]
+		if !ok {
+			d = &ParameterDiff{Key: key(pathB, p)}
+			byKey[d.Key] = d
+		}
+		d.B = p
+	}
+
+	var diffs []ParameterDiff
+	for _, d := range byKey {
+		switch {
+		case d.B == nil:
+			d.Status = DiffMissing
+		case d.A == nil:
+			d.Status = DiffExtra
+		case aws.StringValue(d.A.Value) != aws.StringValue(d.B.Value) || aws.StringValue(d.A.Type) != aws.StringValue(d.B.Type):
+			d.Status = DiffDiffer
+		default:
+			d.Status = DiffSame
+		}
+		diffs = append(diffs, *d)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+// WriteParameterDiff writes the differences as a table, with all also the same parameters.
+// SecureString values are masked unless showSecrets.
+func WriteParameterDiff(w io.Writer, diffs []ParameterDiff, all, showSecrets bool) error {
+	value := func(p *ssm.Parameter) string {
+		if p == nil {
+			return "-"
+		}
+		return displayValue(p, showSecrets)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tKEY\tA\tB")
+	for _, d := range diffs {
+		if d.Status == DiffSame && !all {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Status, d.Key, value(d.A), value(d.B))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,82 @@
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+func (m *ssmMock) GetParametersByPathPagesWithContext(ctx aws.Context, input *ssm.GetParametersByPathInput, f func(*ssm.GetParametersByPathOutput, bool) bool, opts ...request.Option) error {
+	var parameters []*ssm.Parameter
+	for _, p := range m.parameters {
+		parameters = append(parameters, p)
+	}
+	f(&ssm.GetParametersByPathOutput{Parameters: parameters}, true)
+	return nil
+}
+
+func TestParametersByPath(t *testing.T) {
+	mock := &ssmMock{parameters: map[string]*ssm.Parameter{
+		"/app/db/password": parameter("/app/db/password", ssm.ParameterTypeSecureString, "secret"),
+		"/app/db/host":     parameter("/app/db/host", ssm.ParameterTypeString, "localhost"),
+		"/app/name":        parameter("/app/name", ssm.ParameterTypeString, "orders"),
+	}}
+
+	parameters, err := ParametersByPath(context.Background(), "/app", true, mock)
+	assert.Nil(t, err)
+	assert.Equal(t, "/app/db/host", *parameters[0].Name)
+
+	t.Run("Should mask secrets", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, WriteParameters(&buf, parameters, "table", false))
+		assert.Contains(t, buf.String(), mask)
+		assert.NotContains(t, buf.String(), "secret")
+	})
+
+	t.Run("Should write tree", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, WriteTree(&buf, "/app/", parameters, true, false))
+		assert.Equal(t, "/app/\n├── db\n│   ├── host = localhost\n│   └── password = ********\n└── name = orders\n", buf.String())
+	})
+}
+
+func TestDiffParameters(t *testing.T) {
+	a := []*ssm.Parameter{
+		parameter("/staging/app/db/host", ssm.ParameterTypeString, "db.staging"),
+		parameter("/staging/app/db/password", ssm.ParameterTypeSecureString, "staging-secret"),
+		parameter("/staging/app/queue", ssm.ParameterTypeString, "orders"),
+		parameter("/staging/app/name", ssm.ParameterTypeString, "orders"),
+	}
+	b := []*ssm.Parameter{
+		parameter("/dev/app/db/host", ssm.ParameterTypeString, "localhost"),
+		parameter("/dev/app/db/password", ssm.ParameterTypeSecureString, "staging-secret"),
+		parameter("/dev/app/name", ssm.ParameterTypeString, "orders"),
+		parameter("/dev/app/debug", ssm.ParameterTypeString, "true"),
+	}
+
+	diffs := DiffParameters("/staging/app", a, "/dev/app/", b)
+
+	var statuses []string
+	for _, d := range diffs {
+		statuses = append(statuses, d.Status+" "+d.Key)
+	}
+	assert.Equal(t, []string{"differs db/host", "same db/password", "extra debug", "same name", "missing queue"}, statuses)
+
+	t.Run("Should mask secrets and hide same", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, WriteParameterDiff(&buf, diffs, true, false))
+		assert.NotContains(t, buf.String(), "staging-secret")
+
+		buf.Reset()
+		assert.Nil(t, WriteParameterDiff(&buf, diffs, false, false))
+		assert.NotContains(t, buf.String(), "same")
+	})
+}